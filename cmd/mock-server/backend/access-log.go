@@ -0,0 +1,61 @@
+package backend
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// accessLogMiddleware logs every request as a structured entry once it is
+// handled. Requests are logged at the configured AccessLogLevel, except
+// server errors which are always logged as errors.
+func (s *HTTPServer) accessLogMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		cfg := s.config()
+		if !cfg.AccessLog {
+			return
+		}
+		status := c.Writer.Status()
+		level := cfg.AccessLogLevel
+		if status >= http.StatusInternalServerError {
+			level = zap.ErrorLevel
+		}
+		logger := s.requestLogger(c)
+		if ce := logger.Check(level, "request"); ce != nil {
+			ce.Write(
+				zap.String("method", c.Request.Method),
+				zap.String("path", c.Request.URL.Path),
+				zap.String("route", c.FullPath()),
+				zap.String("query", redactQuery(c.Request.URL.RawQuery)),
+				zap.Int("status", status),
+				zap.Int("bytes", c.Writer.Size()),
+				zap.Duration("latency", time.Since(start)),
+				zap.String("clientIp", c.ClientIP()),
+				zap.String("userAgent", c.Request.UserAgent()),
+			)
+		}
+	}
+}
+
+// redactQuery replaces the API key in a raw query string so it is never
+// logged. Queries without one are returned unchanged.
+func redactQuery(rawQuery string) string {
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil || !query.Has(apiKeyQuery) {
+		if err != nil && strings.Contains(rawQuery, apiKeyQuery) {
+			return redactedValue
+		}
+		return rawQuery
+	}
+	for i := range query[apiKeyQuery] {
+		query[apiKeyQuery][i] = redactedValue
+	}
+	return query.Encode()
+}
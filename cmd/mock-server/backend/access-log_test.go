@@ -0,0 +1,48 @@
+package backend
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestAccessLog(t *testing.T) {
+	s := newTestServer(t)
+	core, logs := observer.New(zapcore.InfoLevel)
+	s.logger = zap.New(core)
+
+	req := httptest.NewRequest(http.MethodGet, "/users/email/alice@example.com?includeDeleted=true", nil)
+	req.Header.Set(requestIDHeader, "req-1")
+	s.router.ServeHTTP(httptest.NewRecorder(), req)
+
+	entries := logs.FilterMessage("request").All()
+	assert.Len(t, entries, 1)
+	fields := entries[0].ContextMap()
+	assert.Equal(t, "GET", fields["method"])
+	assert.Equal(t, "/users/email/alice@example.com", fields["path"])
+	assert.Equal(t, "/users/email/:email", fields["route"])
+	assert.EqualValues(t, http.StatusNotFound, fields["status"])
+	assert.Equal(t, "req-1", fields["requestId"])
+	assert.Contains(t, fields, "latency")
+	assert.Contains(t, fields, "clientIp")
+}
+
+func TestAccessLogRedactsAPIKey(t *testing.T) {
+	s := newTestServer(t)
+	core, logs := observer.New(zapcore.InfoLevel)
+	s.logger = zap.New(core)
+
+	s.router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users?limit=2&api_key=s3cret", nil))
+
+	entries := logs.FilterMessage("request").All()
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "api_key=REDACTED&limit=2", entries[0].ContextMap()["query"])
+	}
+	assert.Equal(t, "limit=2&offset=1", redactQuery("limit=2&offset=1"))
+	assert.Equal(t, "REDACTED", redactQuery("api_key=s3cret;%zz"))
+}
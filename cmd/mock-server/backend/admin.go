@@ -0,0 +1,226 @@
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// adminPrefix is where the admin routes are served. Basic Auth protects
+// them for reads as well, see BasicAuthConfig.
+const adminPrefix = "/admin"
+
+// storeSnapshot is the complete content of the user store, soft-deleted
+// users included. Uploaded avatar files are not part of it.
+type storeSnapshot struct {
+	ExportedAt time.Time `json:"exportedAt"`
+	Users      []*User   `json:"users"`
+}
+
+// handleExportSnapshot returns every user in the store as a storeSnapshot
+func (s *HTTPServer) handleExportSnapshot(c *gin.Context) {
+	users, err := s.users.List(c.Request.Context())
+	if err != nil {
+		s.respondStoreError(c, err)
+		return
+	}
+	respond(c, http.StatusOK, storeSnapshot{ExportedAt: s.clock.Now(), Users: users})
+}
+
+// handleImportSnapshot replaces the content of the store with a snapshot
+// exported by handleExportSnapshot. With ?merge=true the snapshot is laid
+// over the existing users instead, replacing those with the same email.
+// The whole snapshot is validated first and nothing is imported when any
+// user is invalid. Missing IDs, timestamps and versions are filled in.
+func (s *HTTPServer) handleImportSnapshot(c *gin.Context) {
+	var snapshot storeSnapshot
+	if err := decodeStrict(c.Request.Body, &snapshot); err != nil {
+		respondValidationError(c, err)
+		return
+	}
+	if snapshot.Users == nil {
+		respondValidationError(c, fieldErrors{"users": "users is required"})
+		return
+	}
+	merge := c.Query("merge") == "true"
+
+	var existing []*User
+	if merge {
+		var err error
+		if existing, err = s.users.List(c.Request.Context()); err != nil {
+			s.respondStoreError(c, err)
+			return
+		}
+	}
+
+	errs := fieldErrors{}
+	for i, user := range snapshot.Users {
+		s.prepareSnapshotUser(errs, fmt.Sprintf("users[%d].", i), user)
+	}
+	users := mergeSnapshot(errs, existing, snapshot.Users)
+	if err := errs.err(); err != nil {
+		respondValidationError(c, err)
+		return
+	}
+
+	if _, err := s.users.Replace(c.Request.Context(), users); err != nil {
+		s.respondStoreError(c, err)
+		return
+	}
+	respond(c, http.StatusOK, gin.H{"loaded": len(snapshot.Users), "total": len(users)})
+}
+
+// handleReset empties the user store and the caches without restarting
+// the process, removing uploaded avatars and resetting the stub scenarios
+// as well, and reports how many entries of each were cleared.
+func (s *HTTPServer) handleReset(c *gin.Context) {
+	scenarios, err := s.users.Scenarios(c.Request.Context())
+	if err != nil {
+		s.respondStoreError(c, err)
+		return
+	}
+	// The users cleared are the ones Replace removed, so a user created
+	// concurrently is either kept or counted
+	users, err := s.users.Replace(c.Request.Context(), nil)
+	if err != nil {
+		s.respondStoreError(c, err)
+		return
+	}
+	if err := s.users.ResetScenarios(c.Request.Context()); err != nil {
+		s.respondStoreError(c, err)
+		return
+	}
+
+	avatars := 0
+	for _, user := range users {
+		if user.AvatarPath == "" {
+			continue
+		}
+		if err := os.Remove(user.AvatarPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			s.requestLogger(c).Warn("failed to remove avatar", zap.String("email", user.Email), zap.Error(err))
+			continue
+		}
+		avatars++
+	}
+	s.requestLogger(c).Info("reset store", zap.Int("users", len(users)))
+
+	respond(c, http.StatusOK, gin.H{"cleared": gin.H{
+		"users":           len(users),
+		"avatars":         avatars,
+		"idempotencyKeys": s.idempotency.Clear(),
+		"weatherCache":    s.weatherCache.Clear(),
+		"scenarios":       len(scenarios),
+	}})
+}
+
+// prepareSnapshotUser validates an imported user, recording the problems
+// in errs under prefix, and fills in the fields a hand-written fixture may
+// leave out
+func (s *HTTPServer) prepareSnapshotUser(errs fieldErrors, prefix string, user *User) {
+	if user == nil {
+		errs[strings.TrimSuffix(prefix, ".")] = "user must not be null"
+		return
+	}
+	cfg := s.config()
+	user.Email = normalizeEmail(user.Email)
+	errs.add(prefix+"email", validateEmail(user.Email))
+	errs.add(prefix+"username", validateUsername(user.Username))
+	if user.AvatarURL != "" {
+		errs.add(prefix+"avatarUrl", validateAvatarURL(user.AvatarURL))
+	}
+	prefs := &user.Preferences
+	if prefs.Theme != "" {
+		errs.add(prefix+"preferences.theme", validateTheme(prefs.Theme, cfg.Themes))
+	}
+	prefs.Tags = normalizeTags(prefs.Tags)
+	errs.add(prefix+"preferences.tags", validateTags(prefs.Tags, cfg.MaxTags))
+	errs.add(prefix+"preferences.settings", validateSettings(prefs.Settings, cfg.SettingsLimits))
+	validateNotifications(errs, prefix+"preferences.", prefs.Notifications)
+	if user.Version < 0 {
+		errs[prefix+"version"] = fmt.Sprintf("must not be negative, got %d", user.Version)
+	}
+
+	if user.ID == "" {
+		user.ID = s.ids.NewID()
+	} else {
+		errs.add(prefix+"id", validateUserID(user.ID))
+	}
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = s.clock.Now()
+	}
+	if user.UpdatedAt.IsZero() {
+		user.UpdatedAt = user.CreatedAt
+	}
+	if user.Version == 0 {
+		user.Version = 1
+	}
+	if prefs.Tags == nil {
+		prefs.Tags = []string{}
+	}
+	if prefs.Settings == nil {
+		prefs.Settings = map[string]any{}
+	}
+	if prefs.Notifications == nil {
+		prefs.Notifications = []Notification{}
+	}
+}
+
+// mergeSnapshot lays imported over existing, replacing users with the
+// same email, and records emails and IDs that would end up used twice.
+// IDs are checked on the merged result, so an import may move an ID from
+// one email to another whatever the order of its entries. A replaced user
+// keeps its uploaded avatar if its ID is unchanged.
+func mergeSnapshot(errs fieldErrors, existing, imported []*User) []*User {
+	byEmail := make(map[string]*User, len(existing))
+	for _, user := range existing {
+		byEmail[user.Email] = user
+	}
+
+	importedAt := make(map[string]int, len(imported))
+	for i, user := range imported {
+		if user == nil {
+			continue
+		}
+		if _, ok := importedAt[user.Email]; ok {
+			errs[fmt.Sprintf("users[%d].email", i)] = fmt.Sprintf("duplicate email %q", user.Email)
+			continue
+		}
+		importedAt[user.Email] = i
+		if old, ok := byEmail[user.Email]; ok && old.ID == user.ID {
+			user.AvatarPath = old.AvatarPath
+		}
+		byEmail[user.Email] = user
+	}
+
+	// The existing users that were kept own their IDs; the imported ones
+	// claim theirs in input order
+	ownerOfID := make(map[string]string, len(byEmail))
+	for email, user := range byEmail {
+		if _, ok := importedAt[email]; !ok {
+			ownerOfID[user.ID] = email
+		}
+	}
+	for i, user := range imported {
+		if user == nil || importedAt[user.Email] != i {
+			continue
+		}
+		if owner, ok := ownerOfID[user.ID]; ok {
+			errs[fmt.Sprintf("users[%d].id", i)] = fmt.Sprintf("id %q is already used by %s", user.ID, owner)
+			continue
+		}
+		ownerOfID[user.ID] = user.Email
+	}
+
+	users := make([]*User, 0, len(byEmail))
+	for _, user := range byEmail {
+		users = append(users, user)
+	}
+	sortUsers(users)
+	return users
+}
@@ -0,0 +1,192 @@
+package backend
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	t.Setenv("ENABLE_ADMIN", "true")
+	s := newTestServer(t)
+	for _, body := range []string{
+		`{"username":"alice","email":"alice@example.com"}`,
+		`{"username":"bob","email":"bob@example.com"}`,
+	} {
+		assert.Equal(t, http.StatusCreated, doRequest(s, http.MethodPost, "/users", body).Code)
+	}
+	assert.Equal(t, http.StatusNoContent, doRequest(s, http.MethodDelete, "/users/bob@example.com?soft=true", "").Code)
+
+	w := doRequest(s, http.MethodGet, "/admin/snapshot", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	dump := w.Body.String()
+	var snapshot storeSnapshot
+	assert.NoError(t, json.Unmarshal([]byte(dump), &snapshot))
+	assert.Len(t, snapshot.Users, 2, "soft-deleted users are included")
+
+	other := newTestServer(t)
+	assert.Equal(t, http.StatusCreated, doRequest(other, http.MethodPost, "/users", `{"username":"carol","email":"carol@example.com"}`).Code)
+	w = doRequest(other, http.MethodPost, "/admin/snapshot", dump)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"loaded":2,"total":2}`, w.Body.String())
+
+	w = doRequest(other, http.MethodGet, "/admin/snapshot", "")
+	var restored storeSnapshot
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &restored))
+	assert.Equal(t, snapshot.Users, restored.Users)
+	assert.Equal(t, http.StatusNotFound, doRequest(other, http.MethodGet, "/users/email/carol@example.com", "").Code)
+}
+
+func TestSnapshotMerge(t *testing.T) {
+	t.Setenv("ENABLE_ADMIN", "true")
+	s := newTestServer(t)
+	assert.Equal(t, http.StatusCreated, doRequest(s, http.MethodPost, "/users", `{"username":"alice","email":"alice@example.com"}`).Code)
+
+	w := doRequest(s, http.MethodPost, "/admin/snapshot?merge=true",
+		`{"users":[{"username":"bob","email":"Bob@Example.com","preferences":{"theme":"dark"}}]}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"loaded":1,"total":2}`, w.Body.String())
+
+	w = doRequest(s, http.MethodGet, "/users/email/bob@example.com", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var bob User
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &bob))
+	assert.NotEmpty(t, bob.ID)
+	assert.Equal(t, 1, bob.Version)
+	assert.Equal(t, "dark", bob.Preferences.Theme)
+	assert.Equal(t, http.StatusOK, doRequest(s, http.MethodGet, "/users/email/alice@example.com", "").Code)
+}
+
+func TestSnapshotMergeSwapsIDs(t *testing.T) {
+	t.Setenv("ENABLE_ADMIN", "true")
+	s := newTestServer(t)
+	ids := map[string]string{}
+	for _, name := range []string{"alice", "bob"} {
+		w := doRequest(s, http.MethodPost, "/users", `{"username":"`+name+`","email":"`+name+`@example.com"}`)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		ids[name] = decodeUser(t, w).ID
+	}
+	entry := func(name, id string) string {
+		return `{"id":"` + id + `","username":"` + name + `","email":"` + name + `@example.com"}`
+	}
+
+	tests := []struct {
+		name  string
+		users []string
+		alice string
+		bob   string
+	}{
+		{"alice first", []string{entry("alice", ids["bob"]), entry("bob", ids["alice"])}, ids["bob"], ids["alice"]},
+		{"bob first", []string{entry("bob", ids["bob"]), entry("alice", ids["alice"])}, ids["alice"], ids["bob"]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := doRequest(s, http.MethodPost, "/admin/snapshot?merge=true", `{"users":[`+strings.Join(tt.users, ",")+`]}`)
+			assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+			assert.JSONEq(t, `{"loaded":2,"total":2}`, w.Body.String())
+			assert.Equal(t, tt.alice, decodeUser(t, doRequest(s, http.MethodGet, "/users/email/alice@example.com", "")).ID)
+			assert.Equal(t, tt.bob, decodeUser(t, doRequest(s, http.MethodGet, "/users/email/bob@example.com", "")).ID)
+		})
+	}
+
+	w := doRequest(s, http.MethodPost, "/admin/snapshot?merge=true", `{"users":[`+entry("carol", ids["alice"])+`]}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code, "an ID kept by an existing user is still taken")
+	var resp APIError
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Contains(t, resp.Details, "users[0].id")
+}
+
+func TestSnapshotImportValidation(t *testing.T) {
+	t.Setenv("ENABLE_ADMIN", "true")
+	s := newTestServer(t)
+	assert.Equal(t, http.StatusCreated, doRequest(s, http.MethodPost, "/users", `{"username":"alice","email":"alice@example.com"}`).Code)
+
+	tests := []struct {
+		name   string
+		body   string
+		fields []string
+	}{
+		{"missing users", `{}`, []string{"users"}},
+		{"invalid email", `{"users":[{"username":"bob","email":"bob"}]}`, []string{"users[0].email"}},
+		{"invalid theme", `{"users":[{"username":"bob","email":"bob@example.com","preferences":{"theme":"neon"}}]}`,
+			[]string{"users[0].preferences.theme"}},
+		{"duplicate email", `{"users":[{"username":"bob","email":"bob@example.com"},{"username":"bob2","email":"BOB@example.com"}]}`,
+			[]string{"users[1].email"}},
+		{"duplicate id", `{"users":[{"id":"00000000-0000-0000-0000-000000000001","username":"bob","email":"bob@example.com"},` +
+			`{"id":"00000000-0000-0000-0000-000000000001","username":"carol","email":"carol@example.com"}]}`,
+			[]string{"users[1].id"}},
+		{"path in id", `{"users":[{"id":"../../home/x/.bashrc","username":"bob","email":"bob@example.com"}]}`,
+			[]string{"users[0].id"}},
+		{"invalid id", `{"users":[{"id":"1","username":"bob","email":"bob@example.com"}]}`,
+			[]string{"users[0].id"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := doRequest(s, http.MethodPost, "/admin/snapshot", tt.body)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			var resp APIError
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			for _, field := range tt.fields {
+				assert.Contains(t, resp.Details, field)
+			}
+		})
+	}
+	assert.Equal(t, http.StatusOK, doRequest(s, http.MethodGet, "/users/email/alice@example.com", "").Code,
+		"a rejected snapshot leaves the store alone")
+}
+
+func TestSnapshotRequiresBasicAuth(t *testing.T) {
+	t.Setenv("ENABLE_ADMIN", "true")
+	t.Setenv("BASIC_AUTH_USER", "gateway")
+	t.Setenv("BASIC_AUTH_PASS", "s3cret")
+	s := newTestServer(t)
+
+	assert.Equal(t, http.StatusOK, doRequest(s, http.MethodGet, "/users", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, doRequest(s, http.MethodGet, "/admin/snapshot", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, doRequest(s, http.MethodPost, "/admin/snapshot", `{"users":[]}`).Code)
+}
+
+func TestReset(t *testing.T) {
+	t.Setenv("ENABLE_ADMIN", "true")
+	s := newTestServer(t)
+	assert.Equal(t, http.StatusCreated, doRequest(s, http.MethodPost, "/users", `{"username":"alice","email":"alice@example.com"}`).Code)
+	assert.Equal(t, http.StatusCreated, doRequest(s, http.MethodPost, "/users", `{"username":"bob","email":"bob@example.com"}`).Code)
+	s.idempotency.Store("key", nil, http.StatusCreated, nil)
+	s.weatherCache.Put("110000", Weather{})
+
+	w := doRequest(s, http.MethodPost, "/admin/reset", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"cleared":{"users":2,"avatars":0,"idempotencyKeys":1,"weatherCache":1,"scenarios":0}}`, w.Body.String())
+
+	w = doRequest(s, http.MethodGet, "/users/count", "")
+	assert.JSONEq(t, `{"count":0,"deleted":0}`, w.Body.String())
+	_, cached := s.weatherCache.Get("110000")
+	assert.False(t, cached)
+}
+
+func TestAdminRequiresEnableAdmin(t *testing.T) {
+	s := newTestServer(t)
+	assert.Equal(t, http.StatusCreated, doRequest(s, http.MethodPost, "/users", `{"username":"alice","email":"alice@example.com"}`).Code)
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{http.MethodGet, "/admin/snapshot", ""},
+		{http.MethodPost, "/admin/snapshot", `{"users":[]}`},
+		{http.MethodGet, "/admin/scenarios", ""},
+		{http.MethodPost, "/admin/scenarios/reset", ""},
+		{http.MethodPost, "/admin/reset", ""},
+		{http.MethodPut, "/debug/loglevel", `{"level":"debug"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			assert.Equal(t, http.StatusNotFound, doRequest(s, tt.method, tt.path, tt.body).Code)
+		})
+	}
+	assert.Equal(t, http.StatusOK, doRequest(s, http.MethodGet, "/users/email/alice@example.com", "").Code)
+}
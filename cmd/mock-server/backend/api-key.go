@@ -0,0 +1,130 @@
+package backend
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	apiKeyHeader = "X-API-Key"
+	apiKeyQuery  = "api_key"
+	// apiKeyIdentityKey is the context key holding the identity of the
+	// matched API key
+	apiKeyIdentityKey = "apiKeyIdentity"
+)
+
+// APIKeyConfig holds the accepted API keys. Authentication is enabled when
+// at least one key is configured.
+type APIKeyConfig struct {
+	// Keys maps each accepted key to the identity it is logged and rate
+	// limited as
+	Keys map[string]string
+	// File is read by load and adds its keys to Keys
+	File string
+	// ExemptPaths never require a key, e.g. health checks
+	ExemptPaths []string
+}
+
+// Enabled reports whether requests must carry an API key
+func (c APIKeyConfig) Enabled() bool {
+	return len(c.Keys) > 0
+}
+
+// identify returns the identity of key, comparing against every accepted
+// key in constant time
+func (c APIKeyConfig) identify(key string) (string, bool) {
+	identity, found := "", false
+	for accepted, id := range c.Keys {
+		if constantTimeEqual(key, accepted) {
+			identity, found = id, true
+		}
+	}
+	return identity, found
+}
+
+// load adds the keys listed in File, if set
+func (c *APIKeyConfig) load() error {
+	if c.File == "" {
+		return nil
+	}
+	f, err := os.Open(c.File)
+	if err != nil {
+		return fmt.Errorf("read API keys: %w", err)
+	}
+	defer f.Close()
+
+	keys := map[string]string{}
+	lines := bufio.NewScanner(f)
+	for lines.Scan() {
+		line := strings.TrimSpace(lines.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		addAPIKey(keys, line)
+	}
+	if err := lines.Err(); err != nil {
+		return fmt.Errorf("read API keys: %w", err)
+	}
+	for key, identity := range c.Keys {
+		keys[key] = identity
+	}
+	c.Keys = keys
+	return nil
+}
+
+// parseAPIKeys parses a comma separated list of identity:key pairs or bare
+// keys
+func parseAPIKeys(v string) map[string]string {
+	keys := map[string]string{}
+	for _, item := range splitList(v) {
+		addAPIKey(keys, item)
+	}
+	return keys
+}
+
+// addAPIKey adds an identity:key pair or a bare key to keys. Bare keys are
+// identified by a short fingerprint so logs don't reveal them.
+func addAPIKey(keys map[string]string, item string) {
+	identity, key, ok := strings.Cut(item, ":")
+	if !ok {
+		sum := sha256.Sum256([]byte(item))
+		identity, key = "key-"+hex.EncodeToString(sum[:4]), item
+	}
+	if key = strings.TrimSpace(key); key != "" {
+		keys[key] = strings.TrimSpace(identity)
+	}
+}
+
+// apiKeyMiddleware requires one of the configured API keys in the
+// X-API-Key header or the api_key query parameter, answering 401
+// otherwise. The identity of the matched key is stored in the context.
+// Preflight requests and exempt paths pass without a key.
+func (s *HTTPServer) apiKeyMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		keys := s.config().APIKeys
+		if !keys.Enabled() || c.Request.Method == http.MethodOptions || slices.Contains(keys.ExemptPaths, c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+
+		key := c.GetHeader(apiKeyHeader)
+		if key == "" {
+			key = c.Query(apiKeyQuery)
+		}
+		identity, ok := keys.identify(key)
+		if !ok {
+			respondError(c, http.StatusUnauthorized, codeUnauthorized, "invalid or missing API key")
+			return
+		}
+		c.Set(apiKeyIdentityKey, identity)
+		c.Next()
+	}
+}
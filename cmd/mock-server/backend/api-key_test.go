@@ -0,0 +1,61 @@
+package backend
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestAPIKey(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "keys")
+	assert.NoError(t, os.WriteFile(file, []byte("# CI keys\nci:file-key\n"), 0o600))
+	t.Setenv("API_KEYS", "gateway:env-key")
+	t.Setenv("API_KEYS_FILE", file)
+	s := newTestServer(t)
+
+	tests := []struct {
+		name         string
+		target       string
+		header       string
+		wantStatus   int
+		wantIdentity string
+	}{
+		{"missing key", "/users/count", "", http.StatusUnauthorized, ""},
+		{"wrong key", "/users/count", "nope", http.StatusUnauthorized, ""},
+		{"header key", "/users/count", "env-key", http.StatusOK, "gateway"},
+		{"query key", "/users/count?api_key=file-key", "", http.StatusOK, "ci"},
+		{"exempt path", "/healthz", "", http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			core, logs := observer.New(zapcore.InfoLevel)
+			s.logger = zap.New(core)
+
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.header != "" {
+				req.Header.Set(apiKeyHeader, tt.header)
+			}
+			w := httptest.NewRecorder()
+			s.router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			entries := logs.FilterMessage("request").All()
+			if assert.Len(t, entries, 1) && tt.wantIdentity != "" {
+				assert.Equal(t, tt.wantIdentity, entries[0].ContextMap()["apiKey"])
+			}
+		})
+	}
+}
+
+func TestParseAPIKeys(t *testing.T) {
+	keys := parseAPIKeys("gateway:abc, bare")
+	assert.Equal(t, "gateway", keys["abc"])
+	assert.Regexp(t, `^key-[0-9a-f]{8}$`, keys["bare"])
+}
@@ -0,0 +1,112 @@
+package backend
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultAuditLogSize = 1000
+
+	// auditEmailKey is the context key handlers use to name the affected
+	// user when it isn't part of the route, e.g. on creation
+	auditEmailKey = "auditEmail"
+)
+
+// auditEntry records a single mutating request
+type auditEntry struct {
+	Time     time.Time `json:"time"`
+	Method   string    `json:"method"`
+	Path     string    `json:"path"`
+	Status   int       `json:"status"`
+	Email    string    `json:"email,omitempty"`
+	ClientIP string    `json:"clientIp"`
+}
+
+// auditLog is a fixed-size ring buffer of audit entries. Once full, the
+// oldest entries are overwritten.
+type auditLog struct {
+	mu      sync.Mutex
+	entries []auditEntry
+	next    int
+	full    bool
+}
+
+func newAuditLog(size int) *auditLog {
+	if size < 1 {
+		size = defaultAuditLogSize
+	}
+	return &auditLog{entries: make([]auditEntry, size)}
+}
+
+// Append adds an entry, evicting the oldest one when the buffer is full
+func (l *auditLog) Append(entry auditEntry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.entries[l.next] = entry
+	l.next = (l.next + 1) % len(l.entries)
+	if l.next == 0 {
+		l.full = true
+	}
+}
+
+// List returns the retained entries from oldest to newest
+func (l *auditLog) List() []auditEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.full {
+		return append([]auditEntry(nil), l.entries[:l.next]...)
+	}
+	list := make([]auditEntry, 0, len(l.entries))
+	list = append(list, l.entries[l.next:]...)
+	return append(list, l.entries[:l.next]...)
+}
+
+// auditMiddleware records every POST, PUT, PATCH and DELETE request once it
+// has been handled
+func (s *HTTPServer) auditMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		default:
+			c.Next()
+			return
+		}
+
+		start := s.clock.Now()
+		c.Next()
+
+		email := c.GetString(auditEmailKey)
+		if email == "" {
+			email = normalizeEmail(c.Param("email"))
+		}
+		s.audit.Append(auditEntry{
+			Time:     start,
+			Method:   c.Request.Method,
+			Path:     c.Request.URL.Path,
+			Status:   c.Writer.Status(),
+			Email:    email,
+			ClientIP: c.ClientIP(),
+		})
+	}
+}
+
+// handleListAudit returns a page of audit entries from oldest to newest.
+// The number of retained entries is reported in the X-Total-Count header.
+func (s *HTTPServer) handleListAudit(c *gin.Context) {
+	limit, offset, err := parsePagination(c)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+
+	entries := s.audit.List()
+	c.Header("X-Total-Count", strconv.Itoa(len(entries)))
+	respond(c, http.StatusOK, paginate(entries, limit, offset))
+}
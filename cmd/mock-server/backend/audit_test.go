@@ -0,0 +1,20 @@
+package backend
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditLogWrapsAround(t *testing.T) {
+	l := newAuditLog(3)
+	for _, path := range []string{"/a", "/b", "/c", "/d", "/e"} {
+		l.Append(auditEntry{Path: path})
+	}
+
+	var paths []string
+	for _, e := range l.List() {
+		paths = append(paths, e.Path)
+	}
+	assert.Equal(t, []string{"/c", "/d", "/e"}, paths)
+}
@@ -0,0 +1,235 @@
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// avatarFormOverhead is the room left for the multipart envelope on top of
+// MaxAvatarSize when limiting the request body
+const avatarFormOverhead = 64 << 10
+
+// handleUpdateAvatar sets the avatar of a user. A multipart upload in the
+// "file" field is checked to be an image and stored under AvatarDir,
+// keyed by user ID. Without a file the "url" form field is validated and
+// stored as the user's avatarUrl.
+func (s *HTTPServer) handleUpdateAvatar(c *gin.Context) {
+	email := c.Param("email")
+	user, err := s.getUser(c.Request.Context(), email)
+	if err != nil {
+		s.respondStoreError(c, err)
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, s.config().MaxAvatarSize+avatarFormOverhead)
+	file, _, err := c.Request.FormFile("file")
+	_, tooLarge := asMaxBytesError(err)
+	switch {
+	case err == nil:
+		defer file.Close()
+		s.uploadAvatar(c, user, file)
+		return
+	case tooLarge:
+		respondError(c, http.StatusRequestEntityTooLarge, codeBodyTooLarge, s.avatarTooLarge().Error())
+		return
+	case !errors.Is(err, http.ErrMissingFile) && !errors.Is(err, http.ErrNotMultipart):
+		respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+
+	avatarURL := c.PostForm("url")
+	if avatarURL == "" {
+		respondError(c, http.StatusBadRequest, codeInvalidRequest, "missing url in form")
+		return
+	}
+	if err := validateAvatarURL(avatarURL); err != nil {
+		respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+
+	updated, err := s.updateUser(c.Request.Context(), email, func(u *User) error {
+		u.AvatarURL = avatarURL
+		u.touch(s.clock.Now())
+		return nil
+	})
+	if err != nil {
+		s.respondStoreError(c, err)
+		return
+	}
+	s.publish(eventUserUpdated, updated)
+
+	respond(c, http.StatusOK, gin.H{
+		"message":   "avatar updated",
+		"avatarUrl": avatarURL,
+	})
+}
+
+// handleGetAvatar serves the uploaded avatar of a user. Range and
+// conditional requests are handled by http.ServeContent using an ETag
+// derived from the file's modification time and size. Users without an
+// uploaded avatar get a 404, or with ?default=identicon a generated PNG
+// that is stable for their email.
+func (s *HTTPServer) handleGetAvatar(c *gin.Context) {
+	user, err := s.getUser(c.Request.Context(), c.Param("email"))
+	if err != nil {
+		s.respondStoreError(c, err)
+		return
+	}
+	if user.AvatarPath == "" && c.Query("default") == "identicon" {
+		data, err := identiconPNG(user.Email)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, codeInternal, err.Error())
+			return
+		}
+		c.Data(http.StatusOK, "image/png", data)
+		return
+	}
+	if user.AvatarPath == "" {
+		respondError(c, http.StatusNotFound, codeNotFound, "user has no avatar")
+		return
+	}
+
+	f, err := os.Open(user.AvatarPath)
+	if err != nil {
+		s.requestLogger(c).Error("failed to open avatar", zap.String("email", user.Email), zap.Error(err))
+		respondError(c, http.StatusNotFound, codeNotFound, "user has no avatar")
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		s.requestLogger(c).Error("failed to stat avatar", zap.String("email", user.Email), zap.Error(err))
+		respondError(c, http.StatusInternalServerError, codeInternal, "failed to read avatar")
+		return
+	}
+
+	c.Header("ETag", fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size()))
+	http.ServeContent(c.Writer, c.Request, "", info.ModTime(), f)
+}
+
+// handleDeleteAvatar clears the avatar URL of a user and removes the
+// uploaded avatar file, if any. Deleting a missing avatar is a no-op that
+// still returns 204.
+func (s *HTTPServer) handleDeleteAvatar(c *gin.Context) {
+	var path string
+	changed := false
+	user, err := s.updateUser(c.Request.Context(), c.Param("email"), func(u *User) error {
+		if u.AvatarURL == "" && u.AvatarPath == "" {
+			return nil
+		}
+		path, changed = u.AvatarPath, true
+		u.AvatarURL, u.AvatarPath = "", ""
+		u.touch(s.clock.Now())
+		return nil
+	})
+	if err != nil {
+		s.respondStoreError(c, err)
+		return
+	}
+
+	if err := removeAvatarFile(path); err != nil {
+		s.requestLogger(c).Error("failed to remove avatar", zap.String("email", user.Email), zap.Error(err))
+	}
+	if changed {
+		s.publish(eventUserUpdated, user)
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// removeAvatarFile removes the uploaded avatar at path, if any. A file that
+// is already gone is not an error.
+func removeAvatarFile(path string) error {
+	if path == "" {
+		return nil
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// uploadAvatar stores the uploaded image and records its path on the user
+func (s *HTTPServer) uploadAvatar(c *gin.Context, user *User, file io.Reader) {
+	data, err := io.ReadAll(io.LimitReader(file, s.config().MaxAvatarSize+1))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+	if int64(len(data)) > s.config().MaxAvatarSize {
+		respondError(c, http.StatusRequestEntityTooLarge, codeBodyTooLarge, s.avatarTooLarge().Error())
+		return
+	}
+	contentType := http.DetectContentType(data)
+	if !strings.HasPrefix(contentType, "image/") {
+		respondError(c, http.StatusBadRequest, codeInvalidRequest, fmt.Sprintf("avatar must be an image, got %s", contentType))
+		return
+	}
+
+	path, err := s.writeAvatar(user.ID, data)
+	if err != nil {
+		s.requestLogger(c).Error("failed to store avatar", zap.String("email", user.Email), zap.Error(err))
+		respondError(c, http.StatusInternalServerError, codeInternal, "failed to store avatar")
+		return
+	}
+
+	updated, err := s.updateUser(c.Request.Context(), user.Email, func(u *User) error {
+		u.AvatarPath = path
+		u.touch(s.clock.Now())
+		return nil
+	})
+	if err != nil {
+		s.respondStoreError(c, err)
+		return
+	}
+	s.publish(eventUserUpdated, updated)
+
+	respond(c, http.StatusOK, gin.H{
+		"message":     "avatar uploaded",
+		"contentType": contentType,
+		"size":        len(data),
+	})
+}
+
+// writeAvatar atomically replaces the avatar file of the user with the
+// given ID and returns its path
+func (s *HTTPServer) writeAvatar(id string, data []byte) (string, error) {
+	if err := validateUserID(id); err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(s.config().AvatarDir, 0o755); err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(s.config().AvatarDir, ".upload-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(s.config().AvatarDir, id)
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// avatarTooLarge returns the error reported for oversized uploads
+func (s *HTTPServer) avatarTooLarge() error {
+	return fmt.Errorf("avatar must be at most %d bytes", s.config().MaxAvatarSize)
+}
@@ -0,0 +1,155 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/amoylab/unla/cmd/mock-server/backend/userpb"
+)
+
+// pngHeader is enough of a PNG file for content sniffing
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func uploadAvatar(s *HTTPServer, email string, data []byte) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, _ := mw.CreateFormFile("file", "avatar.png")
+	fw.Write(data)
+	mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/users/"+email+"/avatar", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+func TestUploadAvatar(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("AVATAR_DIR", dir)
+	t.Setenv("MAX_AVATAR_SIZE", "1024")
+	s := newTestServer(t)
+	doRequest(s, http.MethodPost, "/users", `{"username":"alice","email":"alice@example.com"}`)
+	user, _ := s.users.Get(context.Background(), "alice@example.com")
+
+	w := uploadAvatar(s, "alice@example.com", pngHeader)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"contentType":"image/png"`)
+	stored, err := os.ReadFile(filepath.Join(dir, user.ID))
+	assert.NoError(t, err)
+	assert.Equal(t, pngHeader, stored)
+
+	w = doRequest(s, http.MethodGet, "/users/alice@example.com/avatar", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "image/png", w.Header().Get("Content-Type"))
+	assert.Equal(t, pngHeader, w.Body.Bytes())
+	assert.NotEmpty(t, w.Header().Get("ETag"))
+
+	req := httptest.NewRequest(http.MethodGet, "/users/alice@example.com/avatar", nil)
+	req.Header.Set("Range", "bytes=0-3")
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, pngHeader[:4], w.Body.Bytes())
+
+	w = uploadAvatar(s, "alice@example.com", []byte("plain text"))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = uploadAvatar(s, "alice@example.com", append(pngHeader, make([]byte, 1024)...))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+
+	for range 2 {
+		w = doRequest(s, http.MethodDelete, "/users/alice@example.com/avatar", "")
+		assert.Equal(t, http.StatusNoContent, w.Code)
+	}
+	assert.NoFileExists(t, filepath.Join(dir, user.ID))
+	w = doRequest(s, http.MethodGet, "/users/alice@example.com/avatar", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestDeleteUserRemovesAvatar(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("AVATAR_DIR", dir)
+	s := newTestServer(t)
+	client := newTestGRPCClient(t, s)
+
+	tests := []struct {
+		name   string
+		delete func(email string)
+		kept   bool
+	}{
+		{"hard", func(email string) { doRequest(s, http.MethodDelete, "/users/"+email, "") }, false},
+		{"soft", func(email string) { doRequest(s, http.MethodDelete, "/users/"+email+"?soft=true", "") }, true},
+		{"grpc", func(email string) {
+			_, err := client.DeleteUser(context.Background(), &userpb.DeleteUserRequest{Email: email})
+			assert.NoError(t, err)
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			email := tt.name + "@example.com"
+			w := doRequest(s, http.MethodPost, "/users", `{"username":"`+tt.name+`","email":"`+email+`"}`)
+			assert.Equal(t, http.StatusCreated, w.Code)
+			path := filepath.Join(dir, decodeUser(t, w).ID)
+			assert.Equal(t, http.StatusOK, uploadAvatar(s, email, pngHeader).Code)
+
+			tt.delete(email)
+			if tt.kept {
+				assert.FileExists(t, path, "a soft-deleted user can be restored with its avatar")
+				return
+			}
+			assert.NoFileExists(t, path)
+			assert.Equal(t, http.StatusCreated, doRequest(s, http.MethodPost, "/users", `{"username":"`+tt.name+`","email":"`+email+`"}`).Code)
+			assert.Equal(t, http.StatusNotFound, doRequest(s, http.MethodGet, "/users/"+email+"/avatar", "").Code)
+		})
+	}
+}
+
+func TestUpdateAvatarURL(t *testing.T) {
+	s := newTestServer(t)
+	doRequest(s, http.MethodPost, "/users", `{"username":"alice","email":"alice@example.com"}`)
+
+	post := func(avatarURL string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/users/alice@example.com/avatar",
+			strings.NewReader(url.Values{"url": {avatarURL}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, post("https://example.com/alice.png").Code)
+	w := doRequest(s, http.MethodGet, "/users/email/alice@example.com", "")
+	assert.Contains(t, w.Body.String(), `"avatarUrl":"https://example.com/alice.png"`)
+
+	assert.Equal(t, http.StatusBadRequest, post("ftp://example.com/alice.png").Code)
+	assert.Equal(t, http.StatusBadRequest, post("not a url").Code)
+}
+
+func TestIdenticonAvatar(t *testing.T) {
+	s := newTestServer(t)
+	doRequest(s, http.MethodPost, "/users", `{"username":"alice","email":"alice@example.com"}`)
+
+	w := doRequest(s, http.MethodGet, "/users/alice@example.com/avatar?default=identicon", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "image/png", w.Header().Get("Content-Type"))
+	_, err := png.Decode(bytes.NewReader(w.Body.Bytes()))
+	assert.NoError(t, err)
+
+	again := doRequest(s, http.MethodGet, "/users/ALICE@example.com/avatar?default=identicon", "")
+	assert.Equal(t, w.Body.Bytes(), again.Body.Bytes())
+
+	w = doRequest(s, http.MethodGet, "/users/alice@example.com/avatar", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
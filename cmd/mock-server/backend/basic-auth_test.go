@@ -0,0 +1,48 @@
+package backend
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBasicAuth(t *testing.T) {
+	t.Setenv("BASIC_AUTH_USER", "gateway")
+	t.Setenv("BASIC_AUTH_PASS", "s3cret")
+	s := newTestServer(t)
+
+	tests := []struct {
+		name       string
+		method     string
+		user, pass string
+		wantStatus int
+	}{
+		{"read is open", http.MethodGet, "", "", http.StatusOK},
+		{"missing credentials", http.MethodPost, "", "", http.StatusUnauthorized},
+		{"wrong password", http.MethodPost, "gateway", "wrong", http.StatusUnauthorized},
+		{"valid credentials", http.MethodPost, "gateway", "s3cret", http.StatusCreated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, body := "/users/count", ""
+			if tt.method == http.MethodPost {
+				path, body = "/users", `{"username":"alice","email":"alice@example.com"}`
+			}
+			req := httptest.NewRequest(tt.method, path, strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.user != "" {
+				req.SetBasicAuth(tt.user, tt.pass)
+			}
+			w := httptest.NewRecorder()
+			s.router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantStatus == http.StatusUnauthorized {
+				assert.Equal(t, `Basic realm="mock-server", charset="UTF-8"`, w.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const defaultMaxBodySize = 1 << 20
+
+// ownBodyLimitRoutes lists the routes that cap their request body
+// themselves, with a limit matching what they accept, and are therefore
+// left alone by bodyLimitMiddleware
+var ownBodyLimitRoutes = map[string]bool{
+	"POST /users/import":        true, // maxCSVImportSize
+	"POST /users/:email/avatar": true, // MaxAvatarSize
+}
+
+// bodyLimitMiddleware caps request bodies at MaxBodySize. Requests
+// announcing a larger Content-Length are rejected with 413 up front;
+// other bodies fail to read past the limit, which the handlers report as
+// 413 as well. A non-positive MaxBodySize disables the limit.
+func (s *HTTPServer) bodyLimitMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit := s.config().MaxBodySize
+		if limit <= 0 || ownBodyLimitRoutes[routeKey(c)] {
+			c.Next()
+			return
+		}
+		if c.Request.ContentLength > limit {
+			respondError(c, http.StatusRequestEntityTooLarge, codeBodyTooLarge, bodyTooLarge(limit).Error())
+			return
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		c.Next()
+	}
+}
+
+// bodyTooLarge returns the error reported for bodies over limit bytes
+func bodyTooLarge(limit int64) error {
+	return fmt.Errorf("request body must be at most %d bytes", limit)
+}
+
+// asMaxBytesError reports whether err comes from reading past an
+// http.MaxBytesReader limit
+func asMaxBytesError(err error) (*http.MaxBytesError, bool) {
+	var maxBytesErr *http.MaxBytesError
+	ok := errors.As(err, &maxBytesErr)
+	return maxBytesErr, ok
+}
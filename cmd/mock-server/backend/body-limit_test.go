@@ -0,0 +1,41 @@
+package backend
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBodyLimit(t *testing.T) {
+	t.Setenv("MAX_BODY_SIZE", "64")
+	s := newTestServer(t)
+	oversized := `{"username":"alice","email":"alice@example.com","preferences":{"theme":"dark"}}`
+
+	tests := []struct {
+		name       string
+		body       io.Reader
+		wantStatus int
+	}{
+		{"within limit", strings.NewReader(`{"username":"alice","email":"alice@example.com"}`), http.StatusCreated},
+		{"content length over limit", strings.NewReader(oversized), http.StatusRequestEntityTooLarge},
+		// A body of unknown length is only cut off while it is read
+		{"chunked over limit", io.MultiReader(strings.NewReader(oversized)), http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/users", tt.body)
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			s.router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantStatus == http.StatusRequestEntityTooLarge {
+				assert.Contains(t, w.Body.String(), "at most 64 bytes")
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package backend
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var errCircuitOpen = errors.New("circuit open")
+
+// Circuit breaker states
+const (
+	breakerClosed   = "closed"
+	breakerOpen     = "open"
+	breakerHalfOpen = "half-open"
+)
+
+// circuitBreaker fails calls fast after repeated failures. It opens after
+// threshold consecutive failures and rejects calls for cooldown. Then it
+// becomes half-open and lets a single probe through: success closes the
+// circuit again, failure reopens it for another cooldown. Calls allowed
+// before the circuit opened don't affect the probe. A non-positive
+// threshold disables the breaker.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+
+	state    string
+	failures int
+	openedAt time.Time
+	// probe is the token of the outstanding half-open probe, 0 if none
+	probe  uint64
+	tokens uint64
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown, state: breakerClosed}
+}
+
+// Allow reports whether a call may proceed, returning errCircuitOpen when
+// it must fail fast. Every allowed call must be followed by Done with the
+// returned token.
+func (b *circuitBreaker) Allow() (uint64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tokens++
+	switch b.state {
+	case breakerOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return 0, errCircuitOpen
+		}
+		b.state = breakerHalfOpen
+	case breakerHalfOpen:
+		if b.probe != 0 {
+			return 0, errCircuitOpen
+		}
+	default:
+		return b.tokens, nil
+	}
+	b.probe = b.tokens
+	return b.tokens, nil
+}
+
+// Done records the outcome of the call Allow returned token for. ignored
+// outcomes, such as calls cancelled by the client, count as neither
+// success nor failure. Outcomes of calls allowed before the circuit opened
+// are ignored until it closes again.
+func (b *circuitBreaker) Done(token uint64, failed, ignored bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	probe := token == b.probe
+	if probe {
+		b.probe = 0
+	}
+	switch {
+	case ignored, b.threshold <= 0:
+	case b.state != breakerClosed && !probe:
+	case !failed:
+		b.state = breakerClosed
+		b.failures = 0
+	case probe:
+		b.open()
+	default:
+		b.failures++
+		if b.failures >= b.threshold {
+			b.open()
+		}
+	}
+}
+
+// State returns the current state: closed, open or half-open
+func (b *circuitBreaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == breakerOpen && time.Since(b.openedAt) >= b.cooldown {
+		return breakerHalfOpen
+	}
+	return b.state
+}
+
+// Configure replaces the threshold and cooldown and closes the circuit
+func (b *circuitBreaker) Configure(threshold int, cooldown time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.threshold = threshold
+	b.cooldown = cooldown
+	b.state = breakerClosed
+	b.failures = 0
+	b.probe = 0
+}
+
+// open opens the circuit. The caller must hold the lock.
+func (b *circuitBreaker) open() {
+	b.state = breakerOpen
+	b.openedAt = time.Now()
+	b.failures = 0
+}
@@ -0,0 +1,58 @@
+package backend
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// allow calls b.Allow and fails the test unless the call is allowed
+func allow(t *testing.T, b *circuitBreaker) uint64 {
+	t.Helper()
+	token, err := b.Allow()
+	assert.NoError(t, err)
+	return token
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	b := newCircuitBreaker(2, 20*time.Millisecond)
+
+	for range 2 {
+		b.Done(allow(t, b), true, false)
+	}
+	assert.Equal(t, breakerOpen, b.State())
+	_, err := b.Allow()
+	assert.ErrorIs(t, err, errCircuitOpen)
+
+	time.Sleep(30 * time.Millisecond)
+	probe := allow(t, b)
+	_, err = b.Allow()
+	assert.ErrorIs(t, err, errCircuitOpen, "only one probe at a time")
+	b.Done(probe, true, false)
+	assert.Equal(t, breakerOpen, b.State())
+
+	time.Sleep(30 * time.Millisecond)
+	b.Done(allow(t, b), false, false)
+	assert.Equal(t, breakerClosed, b.State())
+	allow(t, b)
+}
+
+func TestCircuitBreakerLateCallDuringProbe(t *testing.T) {
+	b := newCircuitBreaker(2, 20*time.Millisecond)
+
+	slow := allow(t, b)
+	for range 2 {
+		b.Done(allow(t, b), true, false)
+	}
+	time.Sleep(30 * time.Millisecond)
+	probe := allow(t, b)
+
+	b.Done(slow, false, false)
+	assert.Equal(t, breakerHalfOpen, b.State(), "a call allowed before the circuit opened doesn't close it")
+	_, err := b.Allow()
+	assert.ErrorIs(t, err, errCircuitOpen, "nor does it end the probe")
+
+	b.Done(probe, true, false)
+	assert.Equal(t, breakerOpen, b.State())
+}
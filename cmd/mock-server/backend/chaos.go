@@ -0,0 +1,94 @@
+package backend
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// chaosStatuses are the errors chaos mode picks from
+var chaosStatuses = []int{
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// ChaosConfig fails a share of the requests with a random 5xx error to
+// exercise client resilience
+type ChaosConfig struct {
+	// ErrorRate is the probability from 0 to 1 that a request fails; 0
+	// disables chaos unless a request sets X-Chaos-Error-Rate
+	ErrorRate float64
+	// Seed makes the failures reproducible; 0 picks a random seed
+	Seed uint64
+	// ExemptPaths never fail, e.g. health checks
+	ExemptPaths []string
+}
+
+// chaos draws the failures of chaos mode from a single source so that a
+// seeded run fails the same requests every time
+type chaos struct {
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+func newChaos(seed uint64) *chaos {
+	return &chaos{rng: seededRand(seed)}
+}
+
+// seededRand returns a source of random numbers that repeats for the same
+// seed; 0 picks a random seed
+func seededRand(seed uint64) *rand.Rand {
+	if seed == 0 {
+		seed = rand.Uint64()
+	}
+	return rand.New(rand.NewPCG(seed, seed))
+}
+
+// fail reports whether a request fails at rate, and with which status
+func (c *chaos) fail(rate float64) (int, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if rate <= 0 || c.rng.Float64() >= rate {
+		return 0, false
+	}
+	return chaosStatuses[c.rng.IntN(len(chaosStatuses))], true
+}
+
+// chaosMiddleware fails requests at the configured error rate, or the
+// rate in the X-Chaos-Error-Rate header which takes precedence, with a
+// random 5xx status from chaosStatuses. Failed requests are marked with
+// X-Chaos-Injected and never reach their handler.
+func (s *HTTPServer) chaosMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg := s.config().Chaos
+		if slices.Contains(cfg.ExemptPaths, c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+		rate := cfg.ErrorRate
+		if v := c.GetHeader("X-Chaos-Error-Rate"); v != "" {
+			r, err := strconv.ParseFloat(v, 64)
+			if err != nil || r < 0 || r > 1 {
+				respondError(c, http.StatusBadRequest, codeInvalidRequest, fmt.Sprintf("invalid X-Chaos-Error-Rate %q, must be a number from 0 to 1", v))
+				return
+			}
+			rate = r
+		}
+
+		status, fail := s.chaos.fail(rate)
+		if !fail {
+			c.Next()
+			return
+		}
+		c.Header("X-Chaos-Injected", "true")
+		respondError(c, status, codeInjected, fmt.Sprintf("chaos: injected %d %s", status, http.StatusText(status)))
+	}
+}
@@ -0,0 +1,62 @@
+package backend
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChaosErrorRate(t *testing.T) {
+	t.Setenv("CHAOS_ERROR_RATE", "1")
+	s := newTestServer(t)
+
+	w := doRequest(s, http.MethodGet, "/users", "")
+	assert.Contains(t, chaosStatuses, w.Code)
+	assert.Equal(t, "true", w.Header().Get("X-Chaos-Injected"))
+	assert.Contains(t, w.Body.String(), `"error":"chaos: injected `)
+
+	assert.Equal(t, http.StatusOK, doRequest(s, http.MethodGet, "/healthz", "").Code, "exempt paths never fail")
+
+	req := httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.Header.Set("X-Chaos-Error-Rate", "0")
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, "the header overrides the configured rate")
+}
+
+func TestChaosHeader(t *testing.T) {
+	s := newTestServer(t)
+	chaosRequest := func(rate string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/users", nil)
+		req.Header.Set("X-Chaos-Error-Rate", rate)
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, doRequest(s, http.MethodGet, "/users", "").Code, "chaos is off by default")
+	assert.Contains(t, chaosStatuses, chaosRequest("1").Code)
+	assert.Equal(t, http.StatusBadRequest, chaosRequest("1.5").Code)
+	assert.Equal(t, http.StatusBadRequest, chaosRequest("often").Code)
+}
+
+func TestChaosSeedIsDeterministic(t *testing.T) {
+	t.Setenv("CHAOS_ERROR_RATE", "0.5")
+	t.Setenv("CHAOS_SEED", "42")
+	run := func() []int {
+		s := newTestServer(t)
+		codes := make([]int, 20)
+		for i := range codes {
+			codes[i] = doRequest(s, http.MethodGet, "/users", "").Code
+		}
+		return codes
+	}
+
+	first := run()
+	assert.Equal(t, first, run())
+	assert.True(t, slices.Contains(first, http.StatusOK))
+	assert.True(t, slices.ContainsFunc(first, func(code int) bool { return code >= 500 }))
+}
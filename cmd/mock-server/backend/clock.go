@@ -0,0 +1,73 @@
+package backend
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// IDGenerator generates identifiers for new users
+type IDGenerator interface {
+	NewID() string
+}
+
+// Clock reports the current time
+type Clock interface {
+	Now() time.Time
+}
+
+// uuidGenerator generates random UUIDs
+type uuidGenerator struct{}
+
+func (uuidGenerator) NewID() string {
+	return uuid.New().String()
+}
+
+// systemClock reports the wall clock time
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// counterIDGenerator generates UUID-shaped identifiers from a counter,
+// starting at 00000000-0000-0000-0000-000000000001
+type counterIDGenerator struct {
+	n atomic.Uint64
+}
+
+func (g *counterIDGenerator) NewID() string {
+	return fmt.Sprintf("00000000-0000-0000-0000-%012d", g.n.Add(1))
+}
+
+// fixedClock always reports the same time
+type fixedClock struct {
+	t time.Time
+}
+
+func (c fixedClock) Now() time.Time {
+	return c.t
+}
+
+// deterministicTime is the time reported by the clock used in
+// deterministic mode
+var deterministicTime = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// SetIDGenerator replaces the generator used for new user IDs
+func (s *HTTPServer) SetIDGenerator(ids IDGenerator) {
+	s.ids = ids
+}
+
+// SetClock replaces the clock used for timestamps
+func (s *HTTPServer) SetClock(clock Clock) {
+	s.clock = clock
+}
+
+// SetWeatherProvider replaces the source of the weather endpoints
+func (s *HTTPServer) SetWeatherProvider(weather WeatherProvider) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.weather = weather
+}
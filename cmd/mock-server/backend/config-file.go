@@ -0,0 +1,317 @@
+package backend
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// fileConfig is the YAML configuration file. Every field is optional;
+// unset fields keep their built-in defaults. For example:
+//
+//	listen:
+//	  addrs: [":5236", "127.0.0.1:8080"]
+//	  network: tcp
+//	timeouts:
+//	  read: 30s
+//	  write: 30s
+//	  shutdown: 5s
+//	tls:
+//	  cert: /etc/mock/tls.crt
+//	  key: /etc/mock/tls.key
+//	  minVersion: "1.3"
+//	weather:
+//	  provider: static
+//	  cacheTTL: 1m
+//	cors:
+//	  allowOrigins: ["https://app.example.com"]
+//	  allowCredentials: true
+//	defaultPreferences:
+//	  theme: dark
+//	  tags: [beta]
+//	  notifications:
+//	    - {type: email, channel: security}
+//	delay:
+//	  routes: {"GET /users": 500ms, "POST /users": 200ms-1s, "GET /weather": 300ms~50ms}
+//	  max: 10s
+//	themes: [light, dark]
+//	store:
+//	  backend: sqlite
+//	  sqlitePath: /var/lib/mock/users.db
+//	  redisURL: redis://localhost:6379/0
+//	  redisKeyPrefix: "mock-server:"
+//	  file: /var/lib/mock/users.json
+//	  fileInterval: 30s
+type fileConfig struct {
+	Listen             *fileListen      `yaml:"listen"`
+	Timeouts           *fileTimeouts    `yaml:"timeouts"`
+	TLS                *fileTLS         `yaml:"tls"`
+	Weather            *fileWeather     `yaml:"weather"`
+	CORS               *fileCORS        `yaml:"cors"`
+	Delay              *fileDelay       `yaml:"delay"`
+	DefaultPreferences *filePreferences `yaml:"defaultPreferences"`
+	Themes             []string         `yaml:"themes"`
+	Store              *fileStore       `yaml:"store"`
+}
+
+// fileListen holds the addresses and network to listen on
+type fileListen struct {
+	Addrs   []string `yaml:"addrs"`
+	Network *string  `yaml:"network"`
+}
+
+// fileTimeouts holds the server timeouts
+type fileTimeouts struct {
+	Read               *time.Duration `yaml:"read"`
+	ReadHeader         *time.Duration `yaml:"readHeader"`
+	Write              *time.Duration `yaml:"write"`
+	Idle               *time.Duration `yaml:"idle"`
+	Shutdown           *time.Duration `yaml:"shutdown"`
+	ShutdownDrainDelay *time.Duration `yaml:"shutdownDrainDelay"`
+}
+
+// fileTLS holds the HTTPS settings
+type fileTLS struct {
+	Cert         *string  `yaml:"cert"`
+	Key          *string  `yaml:"key"`
+	MinVersion   *string  `yaml:"minVersion"`
+	CipherSuites []string `yaml:"cipherSuites"`
+}
+
+// fileWeather holds the weather provider settings
+type fileWeather struct {
+	Provider         *string        `yaml:"provider"`
+	RequireKey       *bool          `yaml:"requireKey"`
+	Timeout          *time.Duration `yaml:"timeout"`
+	Retries          *int           `yaml:"retries"`
+	CacheTTL         *time.Duration `yaml:"cacheTTL"`
+	BreakerThreshold *int           `yaml:"breakerThreshold"`
+	BreakerCooldown  *time.Duration `yaml:"breakerCooldown"`
+}
+
+// fileCORS holds the CORS settings
+type fileCORS struct {
+	AllowOrigins     []string `yaml:"allowOrigins"`
+	AllowMethods     []string `yaml:"allowMethods"`
+	AllowHeaders     []string `yaml:"allowHeaders"`
+	ExposeHeaders    []string `yaml:"exposeHeaders"`
+	AllowCredentials *bool    `yaml:"allowCredentials"`
+	MaxAge           *int     `yaml:"maxAge"`
+}
+
+// fileStore holds the user store settings
+type fileStore struct {
+	Backend        *string        `yaml:"backend"`
+	SQLitePath     *string        `yaml:"sqlitePath"`
+	RedisURL       *string        `yaml:"redisURL"`
+	RedisKeyPrefix *string        `yaml:"redisKeyPrefix"`
+	File           *string        `yaml:"file"`
+	FileInterval   *time.Duration `yaml:"fileInterval"`
+}
+
+// fileDelay holds the latency injection settings
+type fileDelay struct {
+	Routes map[string]Latency `yaml:"routes"`
+	Max    *time.Duration     `yaml:"max"`
+	Seed   *uint64            `yaml:"seed"`
+}
+
+// filePreferences holds the preferences assigned to new users
+type filePreferences struct {
+	IsPublic      *bool              `yaml:"isPublic"`
+	ShowEmail     *bool              `yaml:"showEmail"`
+	Theme         *string            `yaml:"theme"`
+	Tags          []string           `yaml:"tags"`
+	Settings      map[string]any     `yaml:"settings"`
+	Notifications []fileNotification `yaml:"notifications"`
+}
+
+// fileNotification is a default notification in the configuration file.
+// Enabled defaults to true and Frequency to realtime.
+type fileNotification struct {
+	Type      string   `yaml:"type"`
+	Channel   string   `yaml:"channel"`
+	Enabled   *bool    `yaml:"enabled"`
+	Frequency *float64 `yaml:"frequency"`
+}
+
+// LoadConfig loads the .env file, if any, and returns the built-in
+// configuration overridden by the YAML file at path and then by the
+// environment variables listed in ConfigFromEnv. An empty path falls back
+// to CONFIG_FILE; without either only the environment is applied. All
+// problems found in the file are reported together.
+func LoadConfig(path string) (Config, error) {
+	if err := loadDotEnv(); err != nil {
+		log.Println("No .env file found, using environment variables directly")
+	}
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+	return loadConfig(path)
+}
+
+// dotEnv tracks the variables set from the .env file
+var dotEnv struct {
+	sync.Mutex
+	keys map[string]bool
+}
+
+// loadDotEnv sets the variables of the .env file that aren't in the real
+// environment, like godotenv.Load. Variables it set earlier are updated, so
+// edits to the file apply while real environment variables still win.
+func loadDotEnv() error {
+	values, err := godotenv.Read()
+	if err != nil {
+		return err
+	}
+	dotEnv.Lock()
+	defer dotEnv.Unlock()
+	if dotEnv.keys == nil {
+		dotEnv.keys = make(map[string]bool)
+	}
+	for key, value := range values {
+		if _, set := os.LookupEnv(key); set && !dotEnv.keys[key] {
+			continue
+		}
+		if err := os.Setenv(key, value); err != nil {
+			return err
+		}
+		dotEnv.keys[key] = true
+	}
+	return nil
+}
+
+// loadConfig is LoadConfig without loading the .env file
+func loadConfig(path string) (Config, error) {
+	cfg := DefaultConfig()
+	if path != "" {
+		if err := readConfigFile(path, &cfg); err != nil {
+			return Config{}, err
+		}
+		cfg.ConfigFile = path
+	}
+	return applyEnv(cfg), nil
+}
+
+// readConfigFile applies the YAML file at path to cfg. Unknown keys and
+// values of the wrong type are errors.
+func readConfigFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+
+	var file fileConfig
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+		var typeErr *yaml.TypeError
+		if !errors.As(err, &typeErr) {
+			return fmt.Errorf("invalid config file %s: %w", path, err)
+		}
+		errs := make([]error, 0, len(typeErr.Errors))
+		for _, msg := range typeErr.Errors {
+			errs = append(errs, errors.New(msg))
+		}
+		return fmt.Errorf("invalid config file %s:\n%w", path, errors.Join(errs...))
+	}
+	file.apply(cfg)
+	return nil
+}
+
+// apply copies the values set in the file to cfg
+func (f *fileConfig) apply(cfg *Config) {
+	if l := f.Listen; l != nil {
+		overrideSlice(&cfg.Addrs, l.Addrs)
+		override(&cfg.ListenNetwork, l.Network)
+	}
+	if t := f.Timeouts; t != nil {
+		override(&cfg.ReadTimeout, t.Read)
+		override(&cfg.ReadHeaderTimeout, t.ReadHeader)
+		override(&cfg.WriteTimeout, t.Write)
+		override(&cfg.IdleTimeout, t.Idle)
+		override(&cfg.ShutdownTimeout, t.Shutdown)
+		override(&cfg.ShutdownDrainDelay, t.ShutdownDrainDelay)
+	}
+	if t := f.TLS; t != nil {
+		override(&cfg.TLS.CertFile, t.Cert)
+		override(&cfg.TLS.KeyFile, t.Key)
+		override(&cfg.TLS.MinVersion, t.MinVersion)
+		overrideSlice(&cfg.TLS.CipherSuites, t.CipherSuites)
+	}
+	if w := f.Weather; w != nil {
+		override(&cfg.WeatherProvider, w.Provider)
+		override(&cfg.WeatherRequireKey, w.RequireKey)
+		override(&cfg.WeatherTimeout, w.Timeout)
+		override(&cfg.WeatherRetries, w.Retries)
+		override(&cfg.WeatherCacheTTL, w.CacheTTL)
+		override(&cfg.WeatherBreakerThreshold, w.BreakerThreshold)
+		override(&cfg.WeatherBreakerCooldown, w.BreakerCooldown)
+	}
+	if c := f.CORS; c != nil {
+		overrideSlice(&cfg.CORS.AllowOrigins, c.AllowOrigins)
+		overrideSlice(&cfg.CORS.AllowMethods, c.AllowMethods)
+		overrideSlice(&cfg.CORS.AllowHeaders, c.AllowHeaders)
+		overrideSlice(&cfg.CORS.ExposeHeaders, c.ExposeHeaders)
+		override(&cfg.CORS.AllowCredentials, c.AllowCredentials)
+		override(&cfg.CORS.MaxAge, c.MaxAge)
+	}
+	if d := f.Delay; d != nil {
+		if d.Routes != nil {
+			cfg.Delay.Routes = d.Routes
+		}
+		override(&cfg.Delay.Max, d.Max)
+		override(&cfg.Delay.Seed, d.Seed)
+	}
+	if p := f.DefaultPreferences; p != nil {
+		prefs := &cfg.DefaultPreferences
+		override(&prefs.IsPublic, p.IsPublic)
+		override(&prefs.ShowEmail, p.ShowEmail)
+		override(&prefs.Theme, p.Theme)
+		overrideSlice(&prefs.Tags, p.Tags)
+		if p.Settings != nil {
+			prefs.Settings = p.Settings
+		}
+		if p.Notifications != nil {
+			prefs.Notifications = make([]Notification, 0, len(p.Notifications))
+			for _, n := range p.Notifications {
+				notification := Notification{Type: n.Type, Channel: n.Channel, Enabled: true, Frequency: FrequencyRealtime}
+				override(&notification.Enabled, n.Enabled)
+				override(&notification.Frequency, n.Frequency)
+				prefs.Notifications = append(prefs.Notifications, notification)
+			}
+		}
+	}
+	overrideSlice(&cfg.Themes, f.Themes)
+	if st := f.Store; st != nil {
+		override(&cfg.StoreBackend, st.Backend)
+		override(&cfg.SQLitePath, st.SQLitePath)
+		override(&cfg.RedisURL, st.RedisURL)
+		override(&cfg.RedisKeyPrefix, st.RedisKeyPrefix)
+		override(&cfg.StoreFile, st.File)
+		override(&cfg.StoreFileInterval, st.FileInterval)
+	}
+}
+
+// override assigns *v to *dst when v is set
+func override[T any](dst *T, v *T) {
+	if v != nil {
+		*dst = *v
+	}
+}
+
+// overrideSlice assigns v to *dst when v is set. An empty list in the file is
+// set, e.g. allowOrigins: [] disables CORS.
+func overrideSlice[T any](dst *[]T, v []T) {
+	if v != nil {
+		*dst = v
+	}
+}
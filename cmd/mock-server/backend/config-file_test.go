@@ -0,0 +1,116 @@
+package backend
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	path := writeConfigFile(t, `
+listen:
+  addrs: [":9000", "127.0.0.1:9001"]
+timeouts:
+  read: 5s
+  shutdown: 1s
+tls:
+  minVersion: "1.3"
+weather:
+  provider: static
+  cacheTTL: 1m
+cors:
+  allowOrigins: []
+defaultPreferences:
+  theme: dark
+  tags: [beta]
+  notifications:
+    - {type: email, channel: security}
+    - {type: push, channel: system, enabled: false, frequency: 1}
+themes: [light, dark]
+delay:
+  routes: {"GET /users": 200ms-1s}
+  seed: 7
+`)
+	t.Setenv("WEATHER_CACHE_TTL", "2m")
+
+	cfg, err := LoadConfig(path)
+	assert.NoError(t, err)
+	assert.Equal(t, path, cfg.ConfigFile)
+	assert.Equal(t, []string{":9000", "127.0.0.1:9001"}, cfg.Addrs)
+	assert.Equal(t, 5*time.Second, cfg.ReadTimeout)
+	assert.Equal(t, defaultWriteTimeout, cfg.WriteTimeout)
+	assert.Equal(t, time.Second, cfg.ShutdownTimeout)
+	assert.Equal(t, "1.3", cfg.TLS.MinVersion)
+	assert.Equal(t, "static", cfg.WeatherProvider)
+	assert.Equal(t, 2*time.Minute, cfg.WeatherCacheTTL, "env overrides the file")
+	assert.Empty(t, cfg.CORS.AllowOrigins)
+	assert.Equal(t, map[string]Latency{"GET /users": {Min: 200 * time.Millisecond, Max: time.Second}}, cfg.Delay.Routes)
+	assert.Equal(t, uint64(7), cfg.Delay.Seed)
+	assert.Equal(t, "dark", cfg.DefaultPreferences.Theme)
+	assert.True(t, cfg.DefaultPreferences.ShowEmail)
+	assert.Equal(t, []string{"beta"}, cfg.DefaultPreferences.Tags)
+	assert.Equal(t, []Notification{
+		{Type: "email", Channel: "security", Enabled: true, Frequency: FrequencyRealtime},
+		{Type: "push", Channel: "system", Enabled: false, Frequency: FrequencyDaily},
+	}, cfg.DefaultPreferences.Notifications)
+	assert.Equal(t, []string{"light", "dark"}, cfg.Themes)
+}
+
+func TestLoadConfigFromEnvFile(t *testing.T) {
+	t.Setenv("CONFIG_FILE", writeConfigFile(t, "weather:\n  provider: static\n"))
+	cfg, err := LoadConfig("")
+	assert.NoError(t, err)
+	assert.Equal(t, "static", cfg.WeatherProvider)
+
+	t.Setenv("CONFIG_FILE", "")
+	cfg, err = LoadConfig("")
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultConfig().WeatherProvider, cfg.WeatherProvider)
+}
+
+func TestLoadConfigFileErrors(t *testing.T) {
+	path := writeConfigFile(t, `
+timeouts:
+  read: soon
+weather:
+  retries: many
+  colour: blue
+`)
+	_, err := LoadConfig(path)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "invalid config file "+path)
+		assert.Contains(t, err.Error(), "line 3")
+		assert.Contains(t, err.Error(), "line 5")
+		assert.Contains(t, err.Error(), "field colour not found")
+	}
+
+	_, err = LoadConfig(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+}
+
+func TestReloadConfigFile(t *testing.T) {
+	path := writeConfigFile(t, "themes: [light]\n")
+	t.Setenv("CONFIG_FILE", path)
+	s := newTestServer(t)
+	assert.Equal(t, []string{"light"}, s.config().Themes)
+
+	assert.NoError(t, os.WriteFile(path, []byte("themes: [light, neon]\n"), 0o600))
+	assert.NoError(t, s.Reload())
+	assert.Equal(t, []string{"light", "neon"}, s.config().Themes)
+
+	assert.NoError(t, os.WriteFile(path, []byte("themes: neon: bad\n"), 0o600))
+	assert.Error(t, s.Reload())
+	assert.Equal(t, []string{"light", "neon"}, s.config().Themes)
+}
@@ -0,0 +1,201 @@
+package backend
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap/zapcore"
+)
+
+var (
+	weatherProviders = []string{"amap", "static"}
+	listenNetworks   = []string{"tcp", "unix"}
+	logFormats       = []string{logFormatJSON, logFormatConsole}
+	rateLimitKeys    = []string{rateLimitByIP, rateLimitByAPIKey}
+	storeBackends    = []string{storeMemory, storeSQLite, storeRedis, storeFile}
+	modes            = []string{modeLive, modeRecord, modeReplay}
+)
+
+// Validate checks the settings that would otherwise only fail once the
+// server listens or handles a request: the listen addresses, the TLS
+// certificate and key, timeouts, the weather provider, log levels, the
+// default preferences, the store backend and the proxy mode, as well as
+// the numeric, duration and boolean environment variables that could not
+// be parsed. All problems are returned together as fieldErrors keyed by the
+// environment variable of the setting.
+func (c Config) Validate() error {
+	errs := fieldErrors{}
+
+	if !slices.Contains(listenNetworks, c.ListenNetwork) {
+		errs["LISTEN_NETWORK"] = oneOf(c.ListenNetwork, listenNetworks)
+	}
+	for i, addr := range c.Addrs {
+		errs.add(fmt.Sprintf("addrs[%d]", i), validateListenAddr(c.ListenNetwork, addr))
+	}
+
+	for name, d := range map[string]time.Duration{
+		"READ_TIMEOUT":        c.ReadTimeout,
+		"READ_HEADER_TIMEOUT": c.ReadHeaderTimeout,
+		"WRITE_TIMEOUT":       c.WriteTimeout,
+		"IDLE_TIMEOUT":        c.IdleTimeout,
+		"SHUTDOWN_TIMEOUT":    c.ShutdownTimeout,
+		"WEATHER_TIMEOUT":     c.WeatherTimeout,
+		"RATE_LIMIT_IDLE_TTL": c.RateLimit.IdleTTL,
+	} {
+		if d <= 0 {
+			errs[name] = fmt.Sprintf("must be positive, got %s", d)
+		}
+	}
+	for name, d := range map[string]time.Duration{
+		"SHUTDOWN_DRAIN_DELAY":     c.ShutdownDrainDelay,
+		"WEATHER_CACHE_TTL":        c.WeatherCacheTTL,
+		"WEATHER_BREAKER_COOLDOWN": c.WeatherBreakerCooldown,
+		"JWT_JWKS_REFRESH":         c.JWT.JWKSRefresh,
+		"STORE_FILE_INTERVAL":      c.StoreFileInterval,
+		"MAX_DELAY":                c.Delay.Max,
+		"STUB_RELOAD_INTERVAL":     c.StubReloadInterval,
+	} {
+		if d < 0 {
+			errs[name] = fmt.Sprintf("must not be negative, got %s", d)
+		}
+	}
+
+	if _, ok := tlsVersions[c.TLS.MinVersion]; !ok && c.TLS.MinVersion != "" {
+		errs["TLS_MIN_VERSION"] = oneOf(c.TLS.MinVersion, slices.Sorted(maps.Keys(tlsVersions)))
+	}
+	for _, name := range c.TLS.CipherSuites {
+		if _, ok := cipherSuiteID(name); !ok {
+			errs["TLS_CIPHER_SUITES"] = fmt.Sprintf("unknown cipher suite %q", name)
+		}
+	}
+	switch {
+	case c.TLS.Enabled():
+		if _, err := tls.LoadX509KeyPair(c.TLS.CertFile, c.TLS.KeyFile); err != nil {
+			errs["TLS_CERT"] = fmt.Sprintf("load certificate: %v", err)
+		}
+	case c.TLS.CertFile != "" || c.TLS.KeyFile != "":
+		errs["TLS_CERT"] = "TLS_CERT and TLS_KEY must be set together"
+	}
+
+	if !slices.Contains(weatherProviders, c.WeatherProvider) {
+		errs["WEATHER_PROVIDER"] = oneOf(c.WeatherProvider, weatherProviders)
+	} else if c.WeatherProvider == "amap" && c.WeatherRequireKey && os.Getenv("WEATHER_API_KEY") == "" {
+		errs["WEATHER_API_KEY"] = "required by WEATHER_REQUIRE_KEY"
+	}
+	if c.WeatherRetries < 0 {
+		errs["WEATHER_RETRIES"] = fmt.Sprintf("must not be negative, got %d", c.WeatherRetries)
+	}
+
+	errs.add("LOG_LEVEL", validateLevel(c.LogLevel))
+	errs.add("ACCESS_LOG_LEVEL", validateLevel(c.AccessLogLevel))
+	if !slices.Contains(logFormats, c.LogFormat) {
+		errs["LOG_FORMAT"] = oneOf(c.LogFormat, logFormats)
+	}
+	for _, route := range slices.Sorted(maps.Keys(c.Delay.Routes)) {
+		switch l := c.Delay.Routes[route]; {
+		case l.Min < 0:
+			errs["DELAY_ROUTES"] = fmt.Sprintf("delay of %s must not be negative, got %s", route, l)
+		case l.Max < l.Min:
+			errs["DELAY_ROUTES"] = fmt.Sprintf("delay range of %s must not end before it starts, got %s", route, l)
+		}
+	}
+	if c.Chaos.ErrorRate < 0 || c.Chaos.ErrorRate > 1 {
+		errs["CHAOS_ERROR_RATE"] = fmt.Sprintf("must be from 0 to 1, got %v", c.Chaos.ErrorRate)
+	}
+	if c.StubDir != "" {
+		if info, err := os.Stat(c.StubDir); err != nil {
+			errs["STUB_DIR"] = err.Error()
+		} else if !info.IsDir() {
+			errs["STUB_DIR"] = fmt.Sprintf("%s is not a directory", c.StubDir)
+		}
+	}
+	switch {
+	case !slices.Contains(modes, c.Mode):
+		errs["MODE"] = oneOf(c.Mode, modes)
+	case c.Mode == modeRecord && c.UpstreamURL == "":
+		errs["UPSTREAM_URL"] = "required by MODE=record"
+	case c.Mode != modeLive && c.CassetteDir == "":
+		errs["CASSETTE_DIR"] = "required by MODE=" + c.Mode
+	}
+	if c.UpstreamURL != "" {
+		if u, err := url.Parse(c.UpstreamURL); err != nil || u.Scheme == "" || u.Host == "" {
+			errs["UPSTREAM_URL"] = fmt.Sprintf("invalid URL %q, must be absolute such as https://api.example.com", c.UpstreamURL)
+		}
+	}
+	if !slices.Contains(rateLimitKeys, c.RateLimit.KeyBy) {
+		errs["RATE_LIMIT_KEY"] = oneOf(c.RateLimit.KeyBy, rateLimitKeys)
+	}
+	if c.MaxBodySize <= 0 {
+		errs["MAX_BODY_SIZE"] = fmt.Sprintf("must be positive, got %d", c.MaxBodySize)
+	}
+
+	switch {
+	case !slices.Contains(storeBackends, c.StoreBackend):
+		errs["STORE_BACKEND"] = oneOf(c.StoreBackend, storeBackends)
+	case c.StoreBackend == storeSQLite && c.SQLitePath == "":
+		errs["SQLITE_PATH"] = "required by STORE_BACKEND=sqlite"
+	case c.StoreBackend == storeFile && c.StoreFile == "":
+		errs["STORE_FILE"] = "required by STORE_BACKEND=file"
+	case c.StoreBackend == storeRedis:
+		if _, err := redis.ParseURL(c.RedisURL); err != nil {
+			errs["REDIS_URL"] = err.Error()
+		}
+	}
+
+	if len(c.Themes) == 0 {
+		errs["THEMES"] = "at least one theme is required"
+	} else {
+		errs.add("DEFAULT_THEME", validateTheme(c.DefaultPreferences.Theme, c.Themes))
+	}
+	errs.add("DEFAULT_TAGS", validateTags(c.DefaultPreferences.Tags, c.MaxTags))
+	notifications := fieldErrors{}
+	validateNotifications(notifications, "", c.DefaultPreferences.Notifications)
+	errs.add("DEFAULT_NOTIFICATIONS", notifications.err())
+
+	maps.Copy(errs, c.envErrors)
+	return errs.err()
+}
+
+// validateListenAddr checks that addr can be listened on over network: a
+// host:port pair with a numeric port for tcp, or a socket path for unix and
+// addresses prefixed with "unix:"
+func validateListenAddr(network, addr string) error {
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok || network == "unix" {
+		if path == "" {
+			return errors.New("missing socket path")
+		}
+		return nil
+	}
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid listen address %q: %w", addr, err)
+	}
+	if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+		return fmt.Errorf("invalid port %q in listen address %q", port, addr)
+	}
+	return nil
+}
+
+// validateLevel checks that level is a zap level, rejecting values left
+// unparsable in the environment
+func validateLevel(level zapcore.Level) error {
+	if level < zapcore.DebugLevel || level > zapcore.FatalLevel {
+		return errors.New("must be one of debug, info, warn, error, dpanic, panic, fatal")
+	}
+	return nil
+}
+
+// oneOf describes a value that is not one of allowed
+func oneOf(value string, allowed []string) string {
+	return fmt.Sprintf("invalid value %q, must be one of %s", value, strings.Join(allowed, ", "))
+}
@@ -0,0 +1,169 @@
+package backend
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zapcore"
+)
+
+// writeTestCert writes a self-signed certificate and its key to a
+// temporary directory and returns their paths
+func writeTestCert(t *testing.T) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestConfigValidate(t *testing.T) {
+	certFile, keyFile := writeTestCert(t)
+
+	tests := []struct {
+		name       string
+		modify     func(*Config)
+		wantFields []string
+	}{
+		{"defaults", func(*Config) {}, nil},
+		{"valid listen addresses", func(c *Config) { c.Addrs = []string{":5236", "127.0.0.1:0", "[::1]:8080", "unix:/tmp/mock.sock"} }, nil},
+		{"valid TLS", func(c *Config) { c.TLS.CertFile, c.TLS.KeyFile = certFile, keyFile }, nil},
+		{"missing port", func(c *Config) { c.Addrs = []string{"localhost"} }, []string{"addrs[0]"}},
+		{"port out of range", func(c *Config) { c.Addrs = []string{":5236", ":65536"} }, []string{"addrs[1]"}},
+		{"named port", func(c *Config) { c.Addrs = []string{"localhost:http"} }, []string{"addrs[0]"}},
+		{"empty socket path", func(c *Config) { c.Addrs = []string{"unix:"} }, []string{"addrs[0]"}},
+		{"unix network", func(c *Config) { c.ListenNetwork, c.Addrs = "unix", []string{"/tmp/mock.sock"} }, nil},
+		{"unknown network", func(c *Config) { c.ListenNetwork = "udp" }, []string{"LISTEN_NETWORK"}},
+		{"zero read timeout", func(c *Config) { c.ReadTimeout = 0 }, []string{"READ_TIMEOUT"}},
+		{"negative timeouts", func(c *Config) {
+			c.WriteTimeout, c.ShutdownTimeout, c.WeatherTimeout = -time.Second, -time.Second, 0
+		}, []string{"SHUTDOWN_TIMEOUT", "WEATHER_TIMEOUT", "WRITE_TIMEOUT"}},
+		{"negative drain delay", func(c *Config) { c.ShutdownDrainDelay = -time.Second }, []string{"SHUTDOWN_DRAIN_DELAY"}},
+		{"TLS files missing", func(c *Config) { c.TLS.CertFile, c.TLS.KeyFile = "/nonexistent.crt", "/nonexistent.key" }, []string{"TLS_CERT"}},
+		{"TLS key swapped", func(c *Config) { c.TLS.CertFile, c.TLS.KeyFile = keyFile, certFile }, []string{"TLS_CERT"}},
+		{"TLS key missing", func(c *Config) { c.TLS.CertFile = certFile }, []string{"TLS_CERT"}},
+		{"TLS min version", func(c *Config) { c.TLS.MinVersion = "1.4" }, []string{"TLS_MIN_VERSION"}},
+		{"TLS cipher suite", func(c *Config) { c.TLS.CipherSuites = []string{"TLS_NOPE"} }, []string{"TLS_CIPHER_SUITES"}},
+		{"weather provider", func(c *Config) { c.WeatherProvider = "darksky" }, []string{"WEATHER_PROVIDER"}},
+		{"weather retries", func(c *Config) { c.WeatherRetries = -1 }, []string{"WEATHER_RETRIES"}},
+		{"log level", func(c *Config) { c.LogLevel = zapcore.InvalidLevel }, []string{"LOG_LEVEL"}},
+		{"access log level", func(c *Config) { c.AccessLogLevel = parseLevel("loud") }, []string{"ACCESS_LOG_LEVEL"}},
+		{"log format", func(c *Config) { c.LogFormat = "xml" }, []string{"LOG_FORMAT"}},
+		{"rate limit key", func(c *Config) { c.RateLimit.KeyBy = "user" }, []string{"RATE_LIMIT_KEY"}},
+		{"max body size", func(c *Config) { c.MaxBodySize = 0 }, []string{"MAX_BODY_SIZE"}},
+		{"store backend", func(c *Config) { c.StoreBackend = "mongo" }, []string{"STORE_BACKEND"}},
+		{"sqlite path", func(c *Config) { c.StoreBackend, c.SQLitePath = storeSQLite, "" }, []string{"SQLITE_PATH"}},
+		{"redis url", func(c *Config) { c.StoreBackend, c.RedisURL = storeRedis, "localhost:6379" }, []string{"REDIS_URL"}},
+		{"mode", func(c *Config) { c.Mode = "mirror" }, []string{"MODE"}},
+		{"record without upstream", func(c *Config) { c.Mode = modeRecord }, []string{"UPSTREAM_URL"}},
+		{"relative upstream", func(c *Config) { c.UpstreamURL = "api.example.com" }, []string{"UPSTREAM_URL"}},
+		{"default theme", func(c *Config) { c.DefaultPreferences.Theme = "neon" }, []string{"DEFAULT_THEME"}},
+		{"no themes", func(c *Config) { c.Themes = nil }, []string{"THEMES"}},
+		{"too many default tags", func(c *Config) {
+			c.MaxTags = 1
+			c.DefaultPreferences.Tags = []string{"a", "b"}
+		}, []string{"DEFAULT_TAGS"}},
+		{"default notification", func(c *Config) {
+			c.DefaultPreferences.Notifications = []Notification{{Type: "fax", Channel: "system"}}
+		}, []string{"DEFAULT_NOTIFICATIONS"}},
+		{"several problems", func(c *Config) {
+			c.Addrs = []string{"nope"}
+			c.IdleTimeout = 0
+			c.LogFormat = "xml"
+		}, []string{"IDLE_TIMEOUT", "LOG_FORMAT", "addrs[0]"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			tt.modify(&cfg)
+			err := cfg.Validate()
+			if tt.wantFields == nil {
+				assert.NoError(t, err)
+				return
+			}
+
+			fields, ok := err.(fieldErrors)
+			if !assert.True(t, ok, "got %v", err) {
+				return
+			}
+			got := make([]string, 0, len(fields))
+			for field := range fields {
+				got = append(got, field)
+			}
+			assert.ElementsMatch(t, tt.wantFields, got)
+		})
+	}
+}
+
+func TestConfigValidateRequiredWeatherKey(t *testing.T) {
+	t.Setenv("WEATHER_API_KEY", "")
+	cfg := DefaultConfig()
+	cfg.WeatherRequireKey = true
+	assert.ErrorContains(t, cfg.Validate(), "WEATHER_API_KEY")
+
+	t.Setenv("WEATHER_API_KEY", "key")
+	assert.NoError(t, cfg.Validate())
+}
+
+func TestConfigValidateMalformedEnv(t *testing.T) {
+	t.Setenv("MAX_TAGS", "ten")
+	t.Setenv("READ_TIMEOUT", "30")
+	t.Setenv("ACCESS_LOG", "yes")
+	t.Setenv("CHAOS_ERROR_RATE", "5%")
+	t.Setenv("IDLE_TIMEOUT", "0s")
+	cfg := ConfigFromEnv()
+	assert.Equal(t, DefaultConfig().MaxTags, cfg.MaxTags)
+
+	fields, ok := cfg.Validate().(fieldErrors)
+	if assert.True(t, ok) {
+		assert.Equal(t, fieldErrors{
+			"MAX_TAGS":         `invalid value "ten", must be an integer`,
+			"READ_TIMEOUT":     `invalid value "30", must be a duration such as 5s`,
+			"ACCESS_LOG":       `invalid value "yes", must be true or false`,
+			"CHAOS_ERROR_RATE": `invalid value "5%", must be a number`,
+			"IDLE_TIMEOUT":     "must be positive, got 0s",
+		}, fields)
+	}
+}
+
+func TestReloadRejectsInvalidConfig(t *testing.T) {
+	s := newTestServer(t)
+	t.Setenv("WRITE_TIMEOUT", "-1s")
+	t.Setenv("MAX_TAGS", "7")
+	assert.ErrorContains(t, s.Reload(), "WRITE_TIMEOUT")
+	assert.Equal(t, defaultMaxTags, s.config().MaxTags)
+}
@@ -0,0 +1,642 @@
+package backend
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	defaultMaxTags = 50
+
+	defaultMaxSettingsKeys  = 100
+	defaultMaxSettingsBytes = 64 << 10
+	defaultMaxSettingsDepth = 8
+
+	defaultMaxAvatarSize = 2 << 20
+
+	defaultWeatherTimeout  = 10 * time.Second
+	defaultWeatherRetries  = 3
+	defaultWeatherCacheTTL = 5 * time.Minute
+
+	defaultWeatherBreakerThreshold = 5
+	defaultWeatherBreakerCooldown  = 30 * time.Second
+
+	defaultReadTimeout       = 30 * time.Second
+	defaultReadHeaderTimeout = 10 * time.Second
+	defaultWriteTimeout      = 30 * time.Second
+	defaultIdleTimeout       = 2 * time.Minute
+	defaultShutdownTimeout   = 5 * time.Second
+
+	defaultRateLimitIdleTTL = 10 * time.Minute
+)
+
+// Config holds the settings of the mock HTTP server
+type Config struct {
+	// ConfigFile is the YAML file the configuration was loaded from, read
+	// again by Reload
+	ConfigFile string
+	// Addrs are the addresses to listen on when none are given on the
+	// command line
+	Addrs []string
+	// DefaultPreferences are assigned to every new user. Notifications
+	// sent when creating a user take precedence over the default ones.
+	DefaultPreferences Preferences
+	// Themes lists the allowed values of preferences.theme
+	Themes []string
+	// MaxTags is the maximum number of preferences.tags per user
+	MaxTags int
+	// SettingsLimits bounds the size of preferences.settings
+	SettingsLimits SettingsLimits
+	// AvatarDir is the directory uploaded avatars are stored in
+	AvatarDir string
+	// MaxAvatarSize is the maximum size of an uploaded avatar in bytes
+	MaxAvatarSize int64
+	// WeatherProvider selects the weather source: "amap" or "static"
+	WeatherProvider string
+	// WeatherRequireKey makes a missing WEATHER_API_KEY fatal for the amap
+	// provider instead of falling back to static weather data
+	WeatherRequireKey bool
+	// WeatherTimeout bounds each call to the weather upstream
+	WeatherTimeout time.Duration
+	// WeatherRetries is the number of retries of failed weather upstream calls
+	WeatherRetries int
+	// WeatherBreakerThreshold is the number of consecutive weather upstream
+	// failures that open the circuit breaker
+	WeatherBreakerThreshold int
+	// WeatherBreakerCooldown is how long the open circuit fails fast before
+	// letting a probe through
+	WeatherBreakerCooldown time.Duration
+	// WeatherCacheTTL is how long weather responses are served from cache
+	WeatherCacheTTL time.Duration
+	// ListenNetwork is the network Start listens on, "tcp" or "unix"
+	ListenNetwork string
+	// ReadTimeout bounds reading a whole request including the body
+	ReadTimeout time.Duration
+	// ReadHeaderTimeout bounds reading the request headers
+	ReadHeaderTimeout time.Duration
+	// WriteTimeout bounds writing a response. Event streams are exempt.
+	WriteTimeout time.Duration
+	// IdleTimeout bounds how long keep-alive connections wait for the next
+	// request
+	IdleTimeout time.Duration
+	// ShutdownTimeout is how long Stop waits for in-flight requests
+	ShutdownTimeout time.Duration
+	// ShutdownDrainDelay is how long Stop keeps serving with /readyz
+	// failing, so load balancers stop routing traffic before the
+	// listeners close
+	ShutdownDrainDelay time.Duration
+	// TLS enables HTTPS when a certificate and key are configured
+	TLS TLSConfig
+	// AuditLogSize is the number of audit entries retained
+	AuditLogSize int
+	// CORS controls which browser origins may call the server
+	CORS CORSConfig
+	// RateLimit throttles clients, disabled by default
+	RateLimit RateLimitConfig
+	// Delay injects artificial latency
+	Delay DelayConfig
+	// Chaos fails random requests, disabled by default
+	Chaos ChaosConfig
+	// MockHeaders lets requests force their status with X-Mock-Status and
+	// their latency with X-Mock-Delay, disabled by default
+	MockHeaders bool
+	// StubDir holds the YAML and JSON stub definitions answering matching
+	// requests instead of the built-in handlers; empty disables stubs
+	StubDir string
+	// StubReloadInterval is how often StubDir is checked for changes; 0
+	// only loads it on startup and Reload
+	StubReloadInterval time.Duration
+	// Mode is live, record or replay and decides how requests to routes
+	// the server doesn't have are answered, see handleProxy
+	Mode string
+	// UpstreamURL is the backend those requests are proxied to in live and
+	// record mode
+	UpstreamURL string
+	// CassetteDir holds the responses recorded in record mode and served in
+	// replay mode
+	CassetteDir string
+	// RedactHeaders are the request and response headers whose values are
+	// replaced before a recording is written
+	RedactHeaders []string
+	// LogLevel is the minimum level logged, adjustable at runtime through
+	// /debug/loglevel with EnableAdmin
+	LogLevel zapcore.Level
+	// LogFormat is json or console
+	LogFormat string
+	// AccessLog enables logging every request
+	AccessLog bool
+	// AccessLogLevel is the level requests are logged at; server errors
+	// are always logged as errors
+	AccessLogLevel zapcore.Level
+	// BasicAuth protects the mutating routes when credentials are set
+	BasicAuth BasicAuthConfig
+	// APIKeys protects every route when keys are configured
+	APIKeys APIKeyConfig
+	// JWT protects every route when a secret or JWKS URL is configured
+	JWT JWTConfig
+	// MaxBodySize caps request bodies in bytes, except on the avatar and
+	// CSV import uploads which have their own limits
+	MaxBodySize int64
+	// OTLPEndpoint enables tracing, exporting spans over OTLP/HTTP
+	OTLPEndpoint string
+	// EnablePprof serves runtime profiles under /debug/pprof
+	EnablePprof bool
+	// EnableAdmin serves the admin routes under /admin, which can export,
+	// replace and reset the store, and PUT /debug/loglevel
+	EnableAdmin bool
+	// EnableDocs serves the API explorer under /docs
+	EnableDocs bool
+	// StoreBackend selects where users are stored: memory, sqlite, redis or
+	// file
+	StoreBackend string
+	// SQLitePath is the database file of the sqlite store backend
+	SQLitePath string
+	// RedisURL is the server of the redis store backend
+	RedisURL string
+	// RedisKeyPrefix is prepended to every key of the redis store backend,
+	// so several deployments can share a server
+	RedisKeyPrefix string
+	// StoreFile is the JSON snapshot of the file store backend
+	StoreFile string
+	// StoreFileInterval is how often the file store backend snapshots
+	// changes; 0 only snapshots on shutdown
+	StoreFileInterval time.Duration
+	// Gzip compresses responses for clients accepting gzip
+	Gzip bool
+	// GzipMinSize is the smallest response body in bytes that is compressed
+	GzipMinSize int
+
+	// envErrors are the environment variables applyEnv could not parse,
+	// reported by Validate
+	envErrors fieldErrors
+}
+
+// SettingsLimits bounds the size of a user's preferences.settings
+type SettingsLimits struct {
+	// MaxKeys is the maximum number of top-level keys
+	MaxKeys int
+	// MaxBytes is the maximum size of the settings encoded as JSON
+	MaxBytes int
+	// MaxDepth is the maximum nesting depth, where a flat object has depth 1
+	MaxDepth int
+}
+
+// DefaultConfig returns the built-in configuration
+func DefaultConfig() Config {
+	return Config{
+		DefaultPreferences: Preferences{
+			IsPublic:      false,
+			ShowEmail:     true,
+			Theme:         "light",
+			Tags:          []string{},
+			Settings:      map[string]any{},
+			Notifications: []Notification{},
+		},
+		Themes:  []string{"light", "dark", "system"},
+		MaxTags: defaultMaxTags,
+		SettingsLimits: SettingsLimits{
+			MaxKeys:  defaultMaxSettingsKeys,
+			MaxBytes: defaultMaxSettingsBytes,
+			MaxDepth: defaultMaxSettingsDepth,
+		},
+		AvatarDir:               filepath.Join(os.TempDir(), "mock-server-avatars"),
+		MaxAvatarSize:           defaultMaxAvatarSize,
+		WeatherProvider:         "amap",
+		WeatherTimeout:          defaultWeatherTimeout,
+		WeatherRetries:          defaultWeatherRetries,
+		WeatherCacheTTL:         defaultWeatherCacheTTL,
+		WeatherBreakerThreshold: defaultWeatherBreakerThreshold,
+		WeatherBreakerCooldown:  defaultWeatherBreakerCooldown,
+		ListenNetwork:           "tcp",
+		ReadTimeout:             defaultReadTimeout,
+		ReadHeaderTimeout:       defaultReadHeaderTimeout,
+		WriteTimeout:            defaultWriteTimeout,
+		IdleTimeout:             defaultIdleTimeout,
+		ShutdownTimeout:         defaultShutdownTimeout,
+		TLS:                     TLSConfig{MinVersion: "1.2"},
+		AuditLogSize:            defaultAuditLogSize,
+		CORS: CORSConfig{
+			AllowOrigins:  []string{"*"},
+			AllowMethods:  []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+			ExposeHeaders: []string{"ETag", "X-Total-Count", "Idempotent-Replayed", "X-Request-ID"},
+		},
+		RateLimit: RateLimitConfig{
+			KeyBy:   rateLimitByIP,
+			IdleTTL: defaultRateLimitIdleTTL,
+		},
+		Delay: DelayConfig{Max: defaultMaxDelay},
+		Chaos: ChaosConfig{
+			ExemptPaths: []string{"/healthz", "/readyz", "/metrics"},
+		},
+		AccessLog:      true,
+		LogLevel:       zapcore.InfoLevel,
+		LogFormat:      logFormatJSON,
+		AccessLogLevel: zapcore.InfoLevel,
+		BasicAuth: BasicAuthConfig{
+			Realm:       "mock-server",
+			ExemptPaths: []string{"/healthz", "/readyz", "/metrics"},
+		},
+		APIKeys: APIKeyConfig{
+			ExemptPaths: []string{"/healthz", "/readyz"},
+		},
+		JWT: JWTConfig{
+			JWKSRefresh: defaultJWKSRefresh,
+			ExemptPaths: []string{"/healthz", "/readyz"},
+		},
+		MaxBodySize:        defaultMaxBodySize,
+		StoreBackend:       storeMemory,
+		SQLitePath:         defaultSQLitePath,
+		RedisURL:           defaultRedisURL,
+		RedisKeyPrefix:     defaultRedisKeyPrefix,
+		StoreFile:          defaultStoreFile,
+		StoreFileInterval:  defaultStoreFileInterval,
+		Gzip:               true,
+		GzipMinSize:        defaultGzipMinSize,
+		StubReloadInterval: defaultStubReloadInterval,
+		Mode:               modeLive,
+		CassetteDir:        defaultCassetteDir,
+		RedactHeaders:      []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-API-Key"},
+	}
+}
+
+// ConfigFromEnv returns the built-in configuration overridden by
+// environment variables:
+//
+//	DEFAULT_IS_PUBLIC          default preferences.isPublic (true/false)
+//	DEFAULT_SHOW_EMAIL         default preferences.showEmail (true/false)
+//	DEFAULT_THEME              default preferences.theme
+//	DEFAULT_TAGS               default preferences.tags, comma separated
+//	DEFAULT_NOTIFICATIONS      default preferences.notifications as comma
+//	                           separated type:channel pairs, e.g. email:security,
+//	                           each enabled with realtime frequency
+//	THEMES                     allowed preferences.theme values, comma separated
+//	MAX_TAGS                   maximum number of preferences.tags per user
+//	MAX_SETTINGS_KEYS          maximum number of top-level preferences.settings keys
+//	MAX_SETTINGS_BYTES         maximum JSON size of preferences.settings in bytes
+//	MAX_SETTINGS_DEPTH         maximum nesting depth of preferences.settings
+//	AVATAR_DIR                 directory uploaded avatars are stored in
+//	MAX_AVATAR_SIZE            maximum size of an uploaded avatar in bytes
+//	WEATHER_PROVIDER           weather source, amap (default) or static
+//	WEATHER_REQUIRE_KEY        exit instead of serving static weather data when
+//	                           WEATHER_API_KEY is unset (true/false)
+//	WEATHER_TIMEOUT            timeout of weather upstream calls, e.g. 10s
+//	WEATHER_RETRIES            retries of failed weather upstream calls
+//	WEATHER_CACHE_TTL          how long weather responses are cached, e.g. 5m
+//	WEATHER_BREAKER_THRESHOLD  consecutive weather failures opening the circuit
+//	WEATHER_BREAKER_COOLDOWN   how long the open circuit fails fast, e.g. 30s
+//	LISTEN_NETWORK             tcp (default) or unix to treat the address as a
+//	                           socket path
+//	READ_TIMEOUT               time allowed to read a request, e.g. 30s
+//	READ_HEADER_TIMEOUT        time allowed to read the request headers
+//	WRITE_TIMEOUT              time allowed to write a response
+//	IDLE_TIMEOUT               keep-alive timeout between requests
+//	SHUTDOWN_TIMEOUT           time Stop waits for in-flight requests, e.g. 5s
+//	SHUTDOWN_DRAIN_DELAY       time /readyz fails before Stop closes listeners
+//	TLS_CERT                   certificate file; serves HTTPS together with TLS_KEY
+//	TLS_KEY                    private key file of TLS_CERT
+//	TLS_MIN_VERSION            minimum TLS version, 1.0 to 1.3 (default 1.2)
+//	TLS_CIPHER_SUITES          allowed cipher suite names, comma separated
+//	AUDIT_LOG_SIZE             number of audit entries retained
+//	CORS_ALLOW_ORIGINS         allowed origins, comma separated, * for any
+//	                           (default *), none to disable CORS
+//	CORS_ALLOW_METHODS         methods allowed in preflights, comma separated
+//	CORS_ALLOW_HEADERS         headers allowed in preflights, comma separated;
+//	                           by default the requested headers are allowed
+//	CORS_EXPOSE_HEADERS        response headers exposed to scripts
+//	CORS_ALLOW_CREDENTIALS     allow cookies and auth headers (true/false)
+//	CORS_MAX_AGE               preflight cache lifetime in seconds
+//	RATE_LIMIT_RPS             requests per second allowed per client, 0 (the
+//	                           default) disables rate limiting
+//	RATE_LIMIT_BURST           requests a client may send at once; defaults to
+//	                           RATE_LIMIT_RPS rounded up
+//	RATE_LIMIT_ROUTES          per-route limits as comma separated
+//	                           METHOD /route=rps[:burst] items, e.g.
+//	                           POST /users=5:10
+//	RATE_LIMIT_KEY             count requests by ip (default) or api-key, the
+//	                           X-API-Key header
+//	RATE_LIMIT_IDLE_TTL        how long idle clients are remembered, e.g. 10m
+//	DELAY_ROUTES               latency added per route as comma separated
+//	                           METHOD /route=latency items, where latency is
+//	                           a duration, a min-max range or mean~jitter,
+//	                           e.g. GET /users=500ms,POST /users=200ms-1s
+//	MAX_DELAY                  longest delay a request may ask for with
+//	                           ?delay=, e.g. 30s (default); 0 disables it
+//	DELAY_SEED                 seed making the delays drawn from latency
+//	                           ranges reproducible
+//	CHAOS_ERROR_RATE           share of requests failed with a random 5xx
+//	                           error, from 0 (default) to 1; requests may set
+//	                           their own with X-Chaos-Error-Rate
+//	CHAOS_SEED                 seed making the chaos failures reproducible
+//	CHAOS_EXEMPT               paths never failed, comma separated
+//	                           (default /healthz,/readyz,/metrics)
+//	MOCK_HEADERS               honor the X-Mock-Status and X-Mock-Delay request
+//	                           headers (true/false, default false)
+//	STUB_DIR                   directory of YAML and JSON stubs answering
+//	                           matching requests
+//	STUB_RELOAD_INTERVAL       how often STUB_DIR is checked for changes, e.g.
+//	                           2s (default); 0 disables watching
+//	MODE                       how requests to unknown routes are answered:
+//	                           live (default) proxies them to UPSTREAM_URL if
+//	                           set, record proxies and records them to
+//	                           CASSETTE_DIR, replay serves the recordings
+//	UPSTREAM_URL               backend unknown routes are proxied to, e.g.
+//	                           https://api.example.com
+//	CASSETTE_DIR               directory of the recordings (default cassettes)
+//	REDACT_HEADERS             headers redacted in recordings, comma separated
+//	                           (default Authorization,Proxy-Authorization,
+//	                           Cookie,Set-Cookie,X-API-Key)
+//	LOG_LEVEL                  minimum level logged: debug, info (default), warn
+//	                           or error
+//	LOG_FORMAT                 json (default) or console
+//	ACCESS_LOG                 log every request (true/false, default true)
+//	ACCESS_LOG_LEVEL           level of access log entries: debug, info (default),
+//	                           warn or error
+//	BASIC_AUTH_USER            Basic Auth user name; together with
+//	                           BASIC_AUTH_PASS protects mutating requests
+//	BASIC_AUTH_PASS            Basic Auth password
+//	BASIC_AUTH_REALM           realm sent in the WWW-Authenticate challenge
+//	BASIC_AUTH_PROTECT_READS   require credentials for GET requests too
+//	                           (true/false)
+//	BASIC_AUTH_EXEMPT          paths never protected, comma separated
+//	                           (default /healthz,/readyz,/metrics)
+//	API_KEYS                   accepted API keys as comma separated
+//	                           identity:key pairs or bare keys; requires
+//	                           X-API-Key or ?api_key= on every request
+//	API_KEYS_FILE              file with one identity:key pair or bare key per
+//	                           line, added to API_KEYS
+//	API_KEYS_EXEMPT            paths not requiring a key, comma separated
+//	                           (default /healthz,/readyz)
+//	JWT_SECRET                 HMAC secret verifying HS256/384/512 bearer tokens;
+//	                           requires a valid token on every request
+//	JWT_JWKS_URL               JWKS URL with the keys verifying RS, PS and ES
+//	                           bearer tokens
+//	JWT_JWKS_REFRESH           how often the JWKS is fetched again, e.g. 10m
+//	JWT_AUDIENCE               audience tokens must be issued for
+//	JWT_ISSUER                 issuer tokens must come from
+//	JWT_EXEMPT                 paths not requiring a token, comma separated
+//	                           (default /healthz,/readyz)
+//	MAX_BODY_SIZE              maximum request body size in bytes (default 1MB)
+//	STORE_BACKEND              where users are stored: memory (default), sqlite
+//	                           or file to keep them across restarts, or redis
+//	                           to share them between replicas
+//	SQLITE_PATH                database file of the sqlite store backend
+//	                           (default mock-server.db)
+//	REDIS_URL                  server of the redis store backend
+//	                           (default redis://localhost:6379/0)
+//	REDIS_KEY_PREFIX           prefix of the redis keys (default mock-server:)
+//	STORE_FILE                 JSON snapshot of the file store backend
+//	                           (default mock-server-users.json)
+//	STORE_FILE_INTERVAL        how often the file store snapshots changes, e.g.
+//	                           30s (default); 0 only snapshots on shutdown
+//	GZIP                       compress responses (true/false, default true)
+//	GZIP_MIN_SIZE              smallest response body compressed, in bytes
+//	ENABLE_PPROF               serve runtime profiles under /debug/pprof
+//	                           (true/false, default false)
+//	ENABLE_ADMIN               serve the /admin routes exporting, replacing and
+//	                           resetting the store, and PUT /debug/loglevel
+//	                           (true/false, default false)
+//	ENABLE_DOCS                serve the Swagger UI explorer of
+//	                           /openapi.json under /docs (true/false, default
+//	                           false)
+//	OTEL_EXPORTER_OTLP_ENDPOINT
+//	                           OTLP/HTTP collector enabling tracing, e.g.
+//	                           http://localhost:4318
+func ConfigFromEnv() Config {
+	return applyEnv(DefaultConfig())
+}
+
+// applyEnv overrides cfg with the environment variables that are set, see
+// ConfigFromEnv
+func applyEnv(cfg Config) Config {
+	errs := fieldErrors{}
+	prefs := &cfg.DefaultPreferences
+	prefs.IsPublic = envBool(errs, "DEFAULT_IS_PUBLIC", prefs.IsPublic)
+	prefs.ShowEmail = envBool(errs, "DEFAULT_SHOW_EMAIL", prefs.ShowEmail)
+	if v := os.Getenv("DEFAULT_THEME"); v != "" {
+		prefs.Theme = v
+	}
+	if v := os.Getenv("DEFAULT_TAGS"); v != "" {
+		prefs.Tags = splitList(v)
+	}
+	if v := os.Getenv("DEFAULT_NOTIFICATIONS"); v != "" {
+		prefs.Notifications = parseNotificationList(v)
+	}
+	if v := os.Getenv("THEMES"); v != "" {
+		cfg.Themes = splitList(v)
+	}
+
+	cfg.MaxTags = envInt(errs, "MAX_TAGS", cfg.MaxTags)
+	limits := &cfg.SettingsLimits
+	limits.MaxKeys = envInt(errs, "MAX_SETTINGS_KEYS", limits.MaxKeys)
+	limits.MaxBytes = envInt(errs, "MAX_SETTINGS_BYTES", limits.MaxBytes)
+	limits.MaxDepth = envInt(errs, "MAX_SETTINGS_DEPTH", limits.MaxDepth)
+	if v := os.Getenv("AVATAR_DIR"); v != "" {
+		cfg.AvatarDir = v
+	}
+	cfg.MaxAvatarSize = int64(envInt(errs, "MAX_AVATAR_SIZE", int(cfg.MaxAvatarSize)))
+	if v := os.Getenv("WEATHER_PROVIDER"); v != "" {
+		cfg.WeatherProvider = v
+	}
+	cfg.WeatherRequireKey = envBool(errs, "WEATHER_REQUIRE_KEY", cfg.WeatherRequireKey)
+	cfg.WeatherTimeout = envDuration(errs, "WEATHER_TIMEOUT", cfg.WeatherTimeout)
+	cfg.WeatherRetries = envInt(errs, "WEATHER_RETRIES", cfg.WeatherRetries)
+	cfg.WeatherCacheTTL = envDuration(errs, "WEATHER_CACHE_TTL", cfg.WeatherCacheTTL)
+	cfg.WeatherBreakerThreshold = envInt(errs, "WEATHER_BREAKER_THRESHOLD", cfg.WeatherBreakerThreshold)
+	cfg.WeatherBreakerCooldown = envDuration(errs, "WEATHER_BREAKER_COOLDOWN", cfg.WeatherBreakerCooldown)
+	if v := os.Getenv("LISTEN_NETWORK"); v != "" {
+		cfg.ListenNetwork = v
+	}
+	cfg.ReadTimeout = envDuration(errs, "READ_TIMEOUT", cfg.ReadTimeout)
+	cfg.ReadHeaderTimeout = envDuration(errs, "READ_HEADER_TIMEOUT", cfg.ReadHeaderTimeout)
+	cfg.WriteTimeout = envDuration(errs, "WRITE_TIMEOUT", cfg.WriteTimeout)
+	cfg.IdleTimeout = envDuration(errs, "IDLE_TIMEOUT", cfg.IdleTimeout)
+	cfg.ShutdownTimeout = envDuration(errs, "SHUTDOWN_TIMEOUT", cfg.ShutdownTimeout)
+	cfg.ShutdownDrainDelay = envDuration(errs, "SHUTDOWN_DRAIN_DELAY", cfg.ShutdownDrainDelay)
+	if v := os.Getenv("TLS_CERT"); v != "" {
+		cfg.TLS.CertFile = v
+	}
+	if v := os.Getenv("TLS_KEY"); v != "" {
+		cfg.TLS.KeyFile = v
+	}
+	if v := os.Getenv("TLS_MIN_VERSION"); v != "" {
+		cfg.TLS.MinVersion = v
+	}
+	if v := os.Getenv("TLS_CIPHER_SUITES"); v != "" {
+		cfg.TLS.CipherSuites = splitList(v)
+	}
+	cfg.AuditLogSize = envInt(errs, "AUDIT_LOG_SIZE", cfg.AuditLogSize)
+
+	cors := &cfg.CORS
+	if v := os.Getenv("CORS_ALLOW_ORIGINS"); v == "none" {
+		cors.AllowOrigins = nil
+	} else if v != "" {
+		cors.AllowOrigins = splitList(v)
+	}
+	if v := os.Getenv("CORS_ALLOW_METHODS"); v != "" {
+		cors.AllowMethods = splitList(v)
+	}
+	if v := os.Getenv("CORS_ALLOW_HEADERS"); v != "" {
+		cors.AllowHeaders = splitList(v)
+	}
+	if v := os.Getenv("CORS_EXPOSE_HEADERS"); v != "" {
+		cors.ExposeHeaders = splitList(v)
+	}
+	cors.AllowCredentials = envBool(errs, "CORS_ALLOW_CREDENTIALS", cors.AllowCredentials)
+	cors.MaxAge = envInt(errs, "CORS_MAX_AGE", cors.MaxAge)
+
+	rateLimit := &cfg.RateLimit
+	rateLimit.Default.RPS = envFloat(errs, "RATE_LIMIT_RPS", rateLimit.Default.RPS)
+	rateLimit.Default.Burst = envInt(errs, "RATE_LIMIT_BURST", defaultBurst(rateLimit.Default.RPS))
+	if v := os.Getenv("RATE_LIMIT_ROUTES"); v != "" {
+		rateLimit.Routes = parseRateLimitRoutes(v)
+	}
+	if v := os.Getenv("RATE_LIMIT_KEY"); v != "" {
+		rateLimit.KeyBy = v
+	}
+	rateLimit.IdleTTL = envDuration(errs, "RATE_LIMIT_IDLE_TTL", rateLimit.IdleTTL)
+
+	if v := os.Getenv("DELAY_ROUTES"); v != "" {
+		cfg.Delay.Routes = parseDelayRoutes(v)
+	}
+	cfg.Delay.Max = envDuration(errs, "MAX_DELAY", cfg.Delay.Max)
+	cfg.Delay.Seed = uint64(envInt(errs, "DELAY_SEED", int(cfg.Delay.Seed)))
+
+	chaos := &cfg.Chaos
+	chaos.ErrorRate = envFloat(errs, "CHAOS_ERROR_RATE", chaos.ErrorRate)
+	chaos.Seed = uint64(envInt(errs, "CHAOS_SEED", int(chaos.Seed)))
+	if v, ok := os.LookupEnv("CHAOS_EXEMPT"); ok {
+		chaos.ExemptPaths = splitList(v)
+	}
+	cfg.MockHeaders = envBool(errs, "MOCK_HEADERS", cfg.MockHeaders)
+	if v := os.Getenv("STUB_DIR"); v != "" {
+		cfg.StubDir = v
+	}
+	cfg.StubReloadInterval = envDuration(errs, "STUB_RELOAD_INTERVAL", cfg.StubReloadInterval)
+	if v := os.Getenv("MODE"); v != "" {
+		cfg.Mode = v
+	}
+	if v := os.Getenv("UPSTREAM_URL"); v != "" {
+		cfg.UpstreamURL = v
+	}
+	if v := os.Getenv("CASSETTE_DIR"); v != "" {
+		cfg.CassetteDir = v
+	}
+	if v, ok := os.LookupEnv("REDACT_HEADERS"); ok {
+		cfg.RedactHeaders = splitList(v)
+	}
+
+	cfg.AccessLog = envBool(errs, "ACCESS_LOG", cfg.AccessLog)
+	if v := os.Getenv("LOG_LEVEL"); v != "" {
+		cfg.LogLevel = parseLevel(v)
+	}
+	if v := os.Getenv("LOG_FORMAT"); v != "" {
+		cfg.LogFormat = v
+	}
+	if v := os.Getenv("ACCESS_LOG_LEVEL"); v != "" {
+		cfg.AccessLogLevel = parseLevel(v)
+	}
+
+	basicAuth := &cfg.BasicAuth
+	basicAuth.User = os.Getenv("BASIC_AUTH_USER")
+	basicAuth.Pass = os.Getenv("BASIC_AUTH_PASS")
+	if v := os.Getenv("BASIC_AUTH_REALM"); v != "" {
+		basicAuth.Realm = v
+	}
+	basicAuth.ProtectReads = envBool(errs, "BASIC_AUTH_PROTECT_READS", basicAuth.ProtectReads)
+	if v, ok := os.LookupEnv("BASIC_AUTH_EXEMPT"); ok {
+		basicAuth.ExemptPaths = splitList(v)
+	}
+
+	apiKeys := &cfg.APIKeys
+	if v := os.Getenv("API_KEYS"); v != "" {
+		apiKeys.Keys = parseAPIKeys(v)
+	}
+	apiKeys.File = os.Getenv("API_KEYS_FILE")
+	if v, ok := os.LookupEnv("API_KEYS_EXEMPT"); ok {
+		apiKeys.ExemptPaths = splitList(v)
+	}
+
+	jwtCfg := &cfg.JWT
+	jwtCfg.Secret = os.Getenv("JWT_SECRET")
+	jwtCfg.JWKSURL = os.Getenv("JWT_JWKS_URL")
+	jwtCfg.JWKSRefresh = envDuration(errs, "JWT_JWKS_REFRESH", jwtCfg.JWKSRefresh)
+	jwtCfg.Audience = os.Getenv("JWT_AUDIENCE")
+	jwtCfg.Issuer = os.Getenv("JWT_ISSUER")
+	if v, ok := os.LookupEnv("JWT_EXEMPT"); ok {
+		jwtCfg.ExemptPaths = splitList(v)
+	}
+
+	cfg.MaxBodySize = int64(envInt(errs, "MAX_BODY_SIZE", int(cfg.MaxBodySize)))
+	cfg.OTLPEndpoint = os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+	cfg.EnablePprof = envBool(errs, "ENABLE_PPROF", cfg.EnablePprof)
+	cfg.EnableAdmin = envBool(errs, "ENABLE_ADMIN", cfg.EnableAdmin)
+	cfg.EnableDocs = envBool(errs, "ENABLE_DOCS", cfg.EnableDocs)
+	if v := os.Getenv("STORE_BACKEND"); v != "" {
+		cfg.StoreBackend = v
+	}
+	if v := os.Getenv("SQLITE_PATH"); v != "" {
+		cfg.SQLitePath = v
+	}
+	if v := os.Getenv("REDIS_URL"); v != "" {
+		cfg.RedisURL = v
+	}
+	if v, ok := os.LookupEnv("REDIS_KEY_PREFIX"); ok {
+		cfg.RedisKeyPrefix = v
+	}
+	if v := os.Getenv("STORE_FILE"); v != "" {
+		cfg.StoreFile = v
+	}
+	cfg.StoreFileInterval = envDuration(errs, "STORE_FILE_INTERVAL", cfg.StoreFileInterval)
+	cfg.Gzip = envBool(errs, "GZIP", cfg.Gzip)
+	cfg.GzipMinSize = envInt(errs, "GZIP_MIN_SIZE", cfg.GzipMinSize)
+	if len(errs) > 0 {
+		cfg.envErrors = errs
+	}
+	return cfg
+}
+
+// parseLevel parses a log level, returning zapcore.InvalidLevel for
+// Validate to report when v is not a level
+func parseLevel(v string) zapcore.Level {
+	level, err := zapcore.ParseLevel(v)
+	if err != nil {
+		return zapcore.InvalidLevel
+	}
+	return level
+}
+
+// splitList splits a comma separated list, trimming spaces and dropping
+// empty items
+func splitList(v string) []string {
+	items := []string{}
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseNotificationList parses a comma separated list of type:channel
+// pairs into enabled realtime notifications. Items without a channel are
+// dropped.
+func parseNotificationList(v string) []Notification {
+	notifications := []Notification{}
+	for _, item := range splitList(v) {
+		typ, channel, ok := strings.Cut(item, ":")
+		if !ok {
+			continue
+		}
+		notifications = append(notifications, Notification{
+			Type:      strings.TrimSpace(typ),
+			Channel:   strings.TrimSpace(channel),
+			Enabled:   true,
+			Frequency: FrequencyRealtime,
+		})
+	}
+	return notifications
+}
@@ -0,0 +1,140 @@
+package backend
+
+import (
+	"cmp"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// negotiatedFormats are the formats respond offers, the first being the
+// default when the Accept header is absent or accepts anything
+var negotiatedFormats = []string{binding.MIMEJSON, binding.MIMEXML, binding.MIMEXML2}
+
+// ownFormatRoutes are the API routes that respond in a format of their own
+// rather than one of negotiatedFormats
+var ownFormatRoutes = map[string]bool{
+	"GET /users.csv":           true,
+	"GET /users/:email/avatar": true,
+	"GET /events":              true,
+	"GET /ws":                  true,
+}
+
+// respond writes v with status as XML when the Accept header prefers
+// application/xml or text/xml, and as JSON otherwise
+func respond(c *gin.Context, status int, v any) {
+	switch c.NegotiateFormat(negotiatedFormats...) {
+	case binding.MIMEXML, binding.MIMEXML2:
+		c.XML(status, xmlBody{v})
+	default:
+		c.JSON(status, v)
+	}
+}
+
+// strictAcceptMiddleware rejects requests with ?strict=true whose Accept
+// header allows none of negotiatedFormats with 406. Without strict they are
+// answered with JSON.
+func strictAcceptMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Query("strict") == "true" && !ownFormatRoutes[routeKey(c)] && c.NegotiateFormat(negotiatedFormats...) == "" {
+			respondError(c, http.StatusNotAcceptable, codeNotAcceptable,
+				fmt.Sprintf("unsupported Accept %q, must allow one of %s", c.GetHeader("Accept"), strings.Join(negotiatedFormats, ", ")))
+			return
+		}
+		c.Next()
+	}
+}
+
+// xmlBody is a response body encoded by c.XML. Structs are encoded as
+// usual, named by their XMLName field or type; maps and slices, which
+// encoding/xml can't encode on their own, in a response element, see
+// encodeXMLValue.
+type xmlBody struct {
+	v any
+}
+
+func (b xmlBody) MarshalXML(e *xml.Encoder, _ xml.StartElement) error {
+	if isXMLStruct(b.v) {
+		return e.Encode(b.v)
+	}
+	return encodeXMLValue(e, xml.StartElement{Name: xml.Name{Local: "response"}}, b.v)
+}
+
+// encodeXMLValue encodes v as the element start. The keys of a gin.H become
+// child elements, the entries of other maps entry elements with a key
+// attribute, sorted by key, and the items of a slice item elements, or the
+// item's own element for structs. Everything else is left to encoding/xml.
+func encodeXMLValue(e *xml.Encoder, start xml.StartElement, v any) error {
+	_, fields := v.(gin.H)
+	if _, ok := v.(xml.Marshaler); ok && !fields {
+		return e.EncodeElement(v, start)
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Invalid:
+		if err := e.EncodeToken(start); err != nil {
+			return err
+		}
+		return e.EncodeToken(start.End())
+	case reflect.Map:
+		if err := e.EncodeToken(start); err != nil {
+			return err
+		}
+		keys := rv.MapKeys()
+		slices.SortFunc(keys, func(a, b reflect.Value) int {
+			return cmp.Compare(fmt.Sprint(a), fmt.Sprint(b))
+		})
+		for _, key := range keys {
+			child := xml.StartElement{
+				Name: xml.Name{Local: "entry"},
+				Attr: []xml.Attr{{Name: xml.Name{Local: "key"}, Value: fmt.Sprint(key)}},
+			}
+			if fields {
+				child = xml.StartElement{Name: xml.Name{Local: key.String()}}
+			}
+			if err := encodeXMLValue(e, child, rv.MapIndex(key).Interface()); err != nil {
+				return err
+			}
+		}
+		return e.EncodeToken(start.End())
+	case reflect.Slice, reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return e.EncodeElement(v, start)
+		}
+		if err := e.EncodeToken(start); err != nil {
+			return err
+		}
+		for i := range rv.Len() {
+			item := rv.Index(i).Interface()
+			var err error
+			if isXMLStruct(item) {
+				err = e.Encode(item)
+			} else {
+				err = encodeXMLValue(e, xml.StartElement{Name: xml.Name{Local: "item"}}, item)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return e.EncodeToken(start.End())
+	default:
+		return e.EncodeElement(v, start)
+	}
+}
+
+// isXMLStruct reports whether v is a struct, or a pointer to one, that
+// encoding/xml encodes as an element of its own
+func isXMLStruct(v any) bool {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t != nil && t.Kind() == reflect.Struct && t != reflect.TypeFor[time.Time]()
+}
@@ -0,0 +1,73 @@
+package backend
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContentNegotiation(t *testing.T) {
+	s := newTestServer(t)
+	assert.Equal(t, http.StatusCreated, doRequest(s, http.MethodPost, "/users", `{"username":"alice","email":"alice@example.com"}`).Code)
+	assert.Equal(t, http.StatusOK, doRequest(s, http.MethodPut, "/users/alice@example.com/preferences",
+		`{"theme":"dark","tags":["beta"],"settings":{"layout":{"columns":2}}}`).Code)
+
+	request := func(path, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("xml", func(t *testing.T) {
+		w := request("/v1/users/email/alice@example.com", "application/xml")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/xml; charset=utf-8", w.Header().Get("Content-Type"))
+		var user struct {
+			Email string   `xml:"email"`
+			Tags  []string `xml:"preferences>tags>tag"`
+		}
+		assert.NoError(t, xml.Unmarshal(w.Body.Bytes(), &user))
+		assert.Equal(t, "alice@example.com", user.Email)
+		assert.Equal(t, []string{"beta"}, user.Tags)
+		assert.Contains(t, w.Body.String(), `<settings><entry key="layout"><entry key="columns">2</entry></entry></settings>`)
+	})
+
+	t.Run("xml list", func(t *testing.T) {
+		w := request("/v1/users", "text/xml")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Regexp(t, `^<response><user><id>[^<]+</id><username>alice</username>`, w.Body.String())
+	})
+
+	t.Run("xml error", func(t *testing.T) {
+		w := request("/v1/users/email/bob@example.com", "application/xml")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Equal(t, `<error><message>user not found</message><code>not_found</code></error>`, w.Body.String())
+	})
+
+	t.Run("json by default", func(t *testing.T) {
+		for _, accept := range []string{"", "*/*", "application/json", "text/html"} {
+			w := request("/v1/users/count", accept)
+			assert.Equal(t, http.StatusOK, w.Code, accept)
+			assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"), accept)
+		}
+	})
+
+	t.Run("strict", func(t *testing.T) {
+		w := request("/v1/users/count?strict=true", "text/html")
+		assert.Equal(t, http.StatusNotAcceptable, w.Code)
+		var body APIError
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Equal(t, codeNotAcceptable, body.Code)
+
+		assert.Equal(t, http.StatusOK, request("/v1/users/count?strict=true", "application/xml").Code)
+		assert.Equal(t, http.StatusOK, request("/v1/users.csv?strict=true", "text/csv").Code, "routes with a format of their own are exempt")
+	})
+}
@@ -0,0 +1,74 @@
+package backend
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CORSConfig holds the cross-origin resource sharing settings. CORS
+// headers are only sent for requests whose Origin is allowed.
+type CORSConfig struct {
+	// AllowOrigins lists the allowed origins, "*" allows any. Empty
+	// disables CORS.
+	AllowOrigins []string
+	AllowMethods []string
+	// AllowHeaders lists the request headers a preflight may ask for.
+	// Empty allows whatever the preflight requests.
+	AllowHeaders  []string
+	ExposeHeaders []string
+	// AllowCredentials lets browsers send cookies and auth headers
+	AllowCredentials bool
+	// MaxAge is how long in seconds browsers may cache a preflight, 0
+	// leaves it to the browser
+	MaxAge int
+}
+
+// allowsOrigin reports whether origin may access the server
+func (c CORSConfig) allowsOrigin(origin string) bool {
+	return slices.Contains(c.AllowOrigins, "*") || slices.Contains(c.AllowOrigins, origin)
+}
+
+// corsMiddleware sets the CORS headers for allowed origins and answers
+// preflight requests with 204 without passing them on. The request origin
+// is echoed back rather than "*" so credentials work with any origin.
+func (s *HTTPServer) corsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cors := s.config().CORS
+		origin := c.GetHeader("Origin")
+		if origin == "" || !cors.allowsOrigin(origin) {
+			c.Next()
+			return
+		}
+
+		c.Header("Access-Control-Allow-Origin", origin)
+		c.Writer.Header().Add("Vary", "Origin")
+		if cors.AllowCredentials {
+			c.Header("Access-Control-Allow-Credentials", "true")
+		}
+		if len(cors.ExposeHeaders) > 0 {
+			c.Header("Access-Control-Expose-Headers", strings.Join(cors.ExposeHeaders, ", "))
+		}
+
+		if c.Request.Method != http.MethodOptions || c.GetHeader("Access-Control-Request-Method") == "" {
+			c.Next()
+			return
+		}
+
+		if len(cors.AllowMethods) > 0 {
+			c.Header("Access-Control-Allow-Methods", strings.Join(cors.AllowMethods, ", "))
+		}
+		if len(cors.AllowHeaders) > 0 {
+			c.Header("Access-Control-Allow-Headers", strings.Join(cors.AllowHeaders, ", "))
+		} else if requested := c.GetHeader("Access-Control-Request-Headers"); requested != "" {
+			c.Header("Access-Control-Allow-Headers", requested)
+		}
+		if cors.MaxAge > 0 {
+			c.Header("Access-Control-Max-Age", strconv.Itoa(cors.MaxAge))
+		}
+		c.AbortWithStatus(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,48 @@
+package backend
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCORS(t *testing.T) {
+	t.Setenv("CORS_ALLOW_ORIGINS", "http://harness.test")
+	t.Setenv("CORS_ALLOW_CREDENTIALS", "true")
+	s := newTestServer(t)
+
+	tests := []struct {
+		name        string
+		method      string
+		origin      string
+		wantStatus  int
+		wantOrigin  string
+		wantMethods bool
+	}{
+		{"allowed origin", http.MethodGet, "http://harness.test", http.StatusOK, "http://harness.test", false},
+		{"other origin", http.MethodGet, "http://evil.test", http.StatusOK, "", false},
+		{"preflight", http.MethodOptions, "http://harness.test", http.StatusNoContent, "http://harness.test", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/users/count", nil)
+			req.Header.Set("Origin", tt.origin)
+			if tt.method == http.MethodOptions {
+				req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+				req.Header.Set("Access-Control-Request-Headers", "Content-Type")
+			}
+			w := httptest.NewRecorder()
+			s.router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			assert.Equal(t, tt.wantOrigin, w.Header().Get("Access-Control-Allow-Origin"))
+			if tt.wantMethods {
+				assert.Contains(t, w.Header().Get("Access-Control-Allow-Methods"), http.MethodPost)
+				assert.Equal(t, "Content-Type", w.Header().Get("Access-Control-Allow-Headers"))
+				assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+			}
+		})
+	}
+}
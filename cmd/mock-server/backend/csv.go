@@ -0,0 +1,185 @@
+package backend
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	// maxCSVImportSize caps the size of an uploaded CSV import
+	maxCSVImportSize = 10 << 20
+	// csvExportPageSize is the number of users read from the store and
+	// flushed to the client at a time
+	csvExportPageSize = 500
+)
+
+// csvHeader lists the columns of the user CSV export. Only the theme is
+// taken from the preferences; tags, settings and notifications are not
+// exported.
+var csvHeader = []string{"id", "username", "email", "createdAt", "theme"}
+
+// handleExportUsersCSV streams all active users as CSV. The users are
+// read from the store csvExportPageSize at a time and each page is flushed
+// before the next is read. A store error before the first page fails the
+// request; a later one ends the truncated export.
+func (s *HTTPServer) handleExportUsersCSV(c *gin.Context) {
+	w := csv.NewWriter(c.Writer)
+	started := false
+	start := func() error {
+		started = true
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", `attachment; filename="users.csv"`)
+		c.Status(http.StatusOK)
+		return w.Write(csvHeader)
+	}
+
+	err := listPages(c.Request.Context(), s.users, csvExportPageSize, func(page []*User) error {
+		if !started {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		for _, user := range page {
+			if user.DeletedAt != nil {
+				continue
+			}
+			row := []string{
+				user.ID,
+				user.Username,
+				user.Email,
+				user.CreatedAt.Format(time.RFC3339),
+				user.Preferences.Theme,
+			}
+			if err := w.Write(row); err != nil {
+				return err
+			}
+		}
+		w.Flush()
+		c.Writer.Flush()
+		return w.Error()
+	})
+	if err == nil && !started {
+		err = start()
+	}
+	switch {
+	case err != nil && !started:
+		s.respondStoreError(c, err)
+		return
+	case err != nil:
+		s.requestLogger(c).Warn("csv export aborted", zap.Error(err))
+		return
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		s.requestLogger(c).Warn("failed to flush csv export", zap.Error(err))
+	}
+}
+
+// csvImportError reports why a row of a CSV import was skipped
+type csvImportError struct {
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+// handleImportUsersCSV creates users from an uploaded CSV file in the
+// multipart field "file". The header row uses the export column names;
+// username and email are required while theme is optional. The id and
+// createdAt columns are ignored and regenerated. Rows that fail validation
+// or collide with an existing email are skipped and reported by their line
+// in the file without aborting the import. A store failure aborts it with
+// the rows imported so far kept.
+func (s *HTTPServer) handleImportUsersCSV(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxCSVImportSize)
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		if _, ok := asMaxBytesError(err); ok {
+			respondError(c, http.StatusRequestEntityTooLarge, codeBodyTooLarge, fmt.Sprintf("file exceeds %d bytes", maxCSVImportSize))
+			return
+		}
+		respondError(c, http.StatusBadRequest, codeInvalidRequest, "missing file in form")
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+	defer file.Close()
+
+	r := csv.NewReader(file)
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		respondError(c, http.StatusBadRequest, codeInvalidRequest, "missing csv header")
+		return
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+	for _, required := range []string{"username", "email"} {
+		if _, ok := columns[required]; !ok {
+			respondError(c, http.StatusBadRequest, codeInvalidRequest, fmt.Sprintf("missing csv column %q", required))
+			return
+		}
+	}
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	imported := 0
+	importErrors := []csvImportError{}
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				s.requestLogger(c).Warn("failed to read csv import", zap.Error(err))
+				respondError(c, http.StatusInternalServerError, codeInternal, "failed to read csv file")
+				return
+			}
+			importErrors = append(importErrors, csvImportError{Row: parseErr.StartLine, Error: err.Error()})
+			continue
+		}
+		row, _ := r.FieldPos(0)
+
+		user := User{
+			Username:    field(record, "username"),
+			Email:       field(record, "email"),
+			Preferences: Preferences{Theme: field(record, "theme")},
+		}
+		if err := s.prepareUser(&user); err != nil {
+			importErrors = append(importErrors, csvImportError{Row: row, Error: err.Error()})
+			continue
+		}
+		if err := s.users.Create(c.Request.Context(), &user); errors.Is(err, errUserExists) {
+			importErrors = append(importErrors, csvImportError{Row: row, Error: err.Error()})
+			continue
+		} else if err != nil {
+			s.respondStoreError(c, err)
+			return
+		}
+		s.publish(eventUserCreated, &user)
+		imported++
+	}
+
+	respond(c, http.StatusOK, gin.H{
+		"imported": imported,
+		"skipped":  len(importErrors),
+		"errors":   importErrors,
+	})
+}
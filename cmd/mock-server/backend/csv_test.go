@@ -0,0 +1,153 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// unavailableStore fails Create with errStoreUnavailable
+type unavailableStore struct {
+	Store
+}
+
+func (unavailableStore) Create(context.Context, *User) error {
+	return fmt.Errorf("%w: connection refused", errStoreUnavailable)
+}
+
+// importCSV uploads content to the CSV import
+func importCSV(s *HTTPServer, content string) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("file", "users.csv")
+	part.Write([]byte(content))
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/users/import", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+func TestExportUsersCSV(t *testing.T) {
+	s := newTestServer(t)
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.SetClock(fixedClock{t: created})
+	for _, body := range []string{
+		`{"username":"alice","email":"alice@example.com","preferences":{"theme":"dark"}}`,
+		`{"username":"bobby","email":"bobby@example.com"}`,
+		`{"username":"carol","email":"carol@example.com"}`,
+	} {
+		assert.Equal(t, http.StatusCreated, doRequest(s, http.MethodPost, "/users", body).Code)
+	}
+	doRequest(s, http.MethodDelete, "/users/carol@example.com?soft=true", "")
+
+	w := doRequest(s, http.MethodGet, "/users.csv", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="users.csv"`, w.Header().Get("Content-Disposition"))
+
+	records, err := csv.NewReader(strings.NewReader(w.Body.String())).ReadAll()
+	assert.NoError(t, err)
+	if !assert.Len(t, records, 3, "soft-deleted users are left out") {
+		return
+	}
+	assert.Equal(t, csvHeader, records[0])
+	rows := map[string][]string{}
+	for _, record := range records[1:] {
+		assert.NotEmpty(t, record[0])
+		rows[record[1]] = record[2:]
+	}
+	assert.Equal(t, map[string][]string{
+		"alice": {"alice@example.com", "2024-01-01T00:00:00Z", "dark"},
+		"bobby": {"bobby@example.com", "2024-01-01T00:00:00Z", "light"},
+	}, rows)
+
+	s.SetStore(brokenStore{Store: newMemoryStore()})
+	w = doRequest(s, http.MethodGet, "/users.csv", "")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+func TestListPages(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryStore()
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var want []string
+	for i := range 5 {
+		id := fmt.Sprintf("id-%d", i)
+		want = append(want, id)
+		// Two users share each creation time, ordered by ID
+		assert.NoError(t, store.Put(ctx, &User{ID: id, Email: id + "@example.com", CreatedAt: created.Add(time.Duration(i/2) * time.Second)}))
+	}
+
+	for name, s := range map[string]Store{"pager": store, "list": struct{ Store }{store}} {
+		t.Run(name, func(t *testing.T) {
+			var got []string
+			var sizes []int
+			assert.NoError(t, listPages(ctx, s, 2, func(page []*User) error {
+				sizes = append(sizes, len(page))
+				for _, user := range page {
+					got = append(got, user.ID)
+				}
+				return nil
+			}))
+			assert.Equal(t, want, got)
+			assert.Equal(t, []int{2, 2, 1}, sizes)
+		})
+	}
+}
+
+func TestImportUsersCSV(t *testing.T) {
+	s := newTestServer(t)
+	doRequest(s, http.MethodPost, "/users", `{"username":"carol","email":"carol@example.com"}`)
+
+	w := importCSV(s, strings.Join([]string{
+		"username,email,theme",
+		"alice,alice@example.com,dark",
+		`"bo` + "\n" + `bby",bobby@example.com,`,
+		"dave1,not-an-email,",
+		"carol2,CAROL@example.com,",
+		"erin1,erin@example.com,",
+		"ally,alice@example.com,",
+	}, "\n"))
+	assert.Equal(t, http.StatusOK, w.Code)
+	var result struct {
+		Imported int              `json:"imported"`
+		Skipped  int              `json:"skipped"`
+		Errors   []csvImportError `json:"errors"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	assert.Equal(t, 2, result.Imported)
+	assert.Equal(t, 4, result.Skipped)
+	rows := make([]int, 0, len(result.Errors))
+	for _, e := range result.Errors {
+		rows = append(rows, e.Row)
+	}
+	// The quoted username spans lines 3 and 4
+	assert.Equal(t, []int{3, 5, 6, 8}, rows)
+	assert.Contains(t, result.Errors[1].Error, "invalid email")
+	assert.Equal(t, errUserExists.Error(), result.Errors[2].Error)
+	assert.Equal(t, errUserExists.Error(), result.Errors[3].Error)
+
+	w = doRequest(s, http.MethodGet, "/users/email/alice@example.com", "")
+	assert.Equal(t, "dark", decodeUser(t, w).Preferences.Theme)
+	assert.Equal(t, http.StatusOK, doRequest(s, http.MethodGet, "/users/email/erin@example.com", "").Code)
+
+	w = importCSV(s, "email\nfrank@example.com\n")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	s.SetStore(unavailableStore{Store: newMemoryStore()})
+	w = importCSV(s, "username,email\nfrank,frank@example.com\n")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
@@ -0,0 +1,189 @@
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+)
+
+const defaultMaxDelay = 30 * time.Second
+
+// DelayConfig injects artificial latency to exercise client timeouts and
+// retries
+type DelayConfig struct {
+	// Routes delays every request of a route, keyed by method and gin
+	// route pattern like RateLimitConfig.Routes
+	Routes map[string]Latency
+	// Max caps the delay a request may ask for with ?delay=; 0 disables
+	// the query parameter
+	Max time.Duration
+	// Seed makes the delays drawn from a Latency range reproducible; 0
+	// picks a random seed
+	Seed uint64
+}
+
+// Latency is a delay drawn uniformly from Min to Max, a fixed delay when
+// both are equal
+type Latency struct {
+	Min time.Duration
+	Max time.Duration
+}
+
+// parseLatency parses a fixed delay such as 500ms, a range such as
+// 200ms-800ms or a mean and jitter such as 500ms~100ms, which is the range
+// 400ms-600ms
+func parseLatency(v string) (Latency, error) {
+	v = strings.TrimSpace(v)
+	if lo, hi, ok := strings.Cut(v, "-"); ok && lo != "" {
+		from, err := time.ParseDuration(strings.TrimSpace(lo))
+		if err != nil {
+			return Latency{}, err
+		}
+		to, err := time.ParseDuration(strings.TrimSpace(hi))
+		if err != nil {
+			return Latency{}, err
+		}
+		return Latency{Min: from, Max: to}, nil
+	}
+	if mean, jitter, ok := strings.Cut(v, "~"); ok {
+		m, err := time.ParseDuration(strings.TrimSpace(mean))
+		if err != nil {
+			return Latency{}, err
+		}
+		j, err := time.ParseDuration(strings.TrimSpace(jitter))
+		if err != nil {
+			return Latency{}, err
+		}
+		return Latency{Min: max(m-j, 0), Max: m + j}, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return Latency{}, err
+	}
+	return Latency{Min: d, Max: d}, nil
+}
+
+// UnmarshalYAML reads a Latency in the notation of parseLatency
+func (l *Latency) UnmarshalYAML(node *yaml.Node) error {
+	var v string
+	if err := node.Decode(&v); err != nil {
+		return err
+	}
+	latency, err := parseLatency(v)
+	if err != nil {
+		return fmt.Errorf("line %d: invalid latency %q: %w", node.Line, v, err)
+	}
+	*l = latency
+	return nil
+}
+
+func (l Latency) String() string {
+	if l.Min == l.Max {
+		return l.Min.String()
+	}
+	return l.Min.String() + "-" + l.Max.String()
+}
+
+// parseDelayRoutes parses a comma separated list of
+// "METHOD /route=latency" items, see parseLatency for the latency.
+// Malformed items are dropped.
+func parseDelayRoutes(v string) map[string]Latency {
+	routes := map[string]Latency{}
+	for _, item := range splitList(v) {
+		route, spec, ok := strings.Cut(item, "=")
+		if !ok {
+			continue
+		}
+		latency, err := parseLatency(spec)
+		if err != nil {
+			continue
+		}
+		routes[strings.Join(strings.Fields(route), " ")] = latency
+	}
+	return routes
+}
+
+// jitter draws delays from Latency ranges. A seeded jitter draws the same
+// delays every run.
+type jitter struct {
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+func newJitter(seed uint64) *jitter {
+	return &jitter{rng: seededRand(seed)}
+}
+
+// draw returns a delay from l
+func (j *jitter) draw(l Latency) time.Duration {
+	if l.Max <= l.Min {
+		return l.Min
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return l.Min + time.Duration(j.rng.Int64N(int64(l.Max-l.Min)+1))
+}
+
+// delayMiddleware sleeps before handling a request for a delay drawn from
+// the latency configured for its route, or for the ?delay= query
+// parameter which takes precedence. The parameter is a Go duration such
+// as 500ms, 2s or 1m30s and may not exceed DelayConfig.Max. A client that
+// goes away while the request is delayed cancels it.
+func (s *HTTPServer) delayMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg := s.config().Delay
+		delay := s.jitter.draw(cfg.Routes[routeKey(c)])
+		if v := c.Query("delay"); v != "" {
+			d, err := parseRequestedDelay(v, cfg.Max)
+			if err != nil {
+				respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+				return
+			}
+			delay = d
+		}
+		if !sleepContext(c, delay) {
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// sleepContext sleeps for d, returning false early if the client of c
+// goes away
+func sleepContext(c *gin.Context, d time.Duration) bool {
+	if d <= 0 {
+		return true
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-c.Request.Context().Done():
+		return false
+	}
+}
+
+// parseRequestedDelay parses the ?delay= query parameter, allowing at most
+// limit
+func parseRequestedDelay(v string, limit time.Duration) (time.Duration, error) {
+	if limit <= 0 {
+		return 0, errors.New("delay injection is disabled")
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid delay %q, must be a duration such as 500ms or 2s", v)
+	}
+	if d > limit {
+		return 0, fmt.Errorf("delay %s exceeds the maximum of %s", d, limit)
+	}
+	return d, nil
+}
@@ -0,0 +1,121 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDelayQueryParam(t *testing.T) {
+	t.Setenv("MAX_DELAY", "1s")
+	s := newTestServer(t)
+
+	start := time.Now()
+	w := doRequest(s, http.MethodGet, "/users/count?delay=50ms", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+
+	tests := []struct {
+		delay   string
+		wantErr string
+	}{
+		{"soon", `invalid delay "soon", must be a duration such as 500ms or 2s`},
+		{"-1s", `invalid delay "-1s", must be a duration such as 500ms or 2s`},
+		{"2s", "delay 2s exceeds the maximum of 1s"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.delay, func(t *testing.T) {
+			w := doRequest(s, http.MethodGet, "/users/count?delay="+tt.delay, "")
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			var body map[string]string
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			assert.Equal(t, tt.wantErr, body["error"])
+		})
+	}
+}
+
+func TestDelayRoutes(t *testing.T) {
+	t.Setenv("DELAY_ROUTES", "GET /users/count=50ms")
+	s := newTestServer(t)
+
+	start := time.Now()
+	assert.Equal(t, http.StatusOK, doRequest(s, http.MethodGet, "/users/count", "").Code)
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+
+	start = time.Now()
+	assert.Equal(t, http.StatusOK, doRequest(s, http.MethodGet, "/users", "").Code)
+	assert.Less(t, time.Since(start), 50*time.Millisecond)
+}
+
+func TestDelayHonorsCancellation(t *testing.T) {
+	s := newTestServer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	req := httptest.NewRequest(http.MethodPost, "/users?delay=10s", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+
+	start := time.Now()
+	s.router.ServeHTTP(w, req)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Empty(t, w.Body.String(), "the handler does not run")
+}
+
+func TestParseDelayRoutes(t *testing.T) {
+	assert.Equal(t, map[string]Latency{
+		"GET /users":                 {Min: 500 * time.Millisecond, Max: 500 * time.Millisecond},
+		"POST /users/:email/restore": {Min: 2 * time.Second, Max: 2 * time.Second},
+		"GET /weather":               {Min: 200 * time.Millisecond, Max: time.Second},
+		"GET /events":                {Min: 400 * time.Millisecond, Max: 600 * time.Millisecond},
+	}, parseDelayRoutes("GET  /users = 500ms, POST /users/:email/restore=2s, GET /weather=200ms-1s, GET /events=500ms~100ms, broken, GET /x=soon, GET /y=1s-soon"))
+}
+
+func TestParseLatency(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Latency
+		wantErr bool
+	}{
+		{in: "250ms", want: Latency{250 * time.Millisecond, 250 * time.Millisecond}},
+		{in: "100ms-2s", want: Latency{100 * time.Millisecond, 2 * time.Second}},
+		{in: "500ms~100ms", want: Latency{400 * time.Millisecond, 600 * time.Millisecond}},
+		{in: "100ms~1s", want: Latency{0, 1100 * time.Millisecond}},
+		{in: "-1s", want: Latency{-time.Second, -time.Second}},
+		{in: "1s-", wantErr: true},
+		{in: "1s~x", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseLatency(tt.in)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestJitterIsSeeded(t *testing.T) {
+	latency := Latency{Min: 10 * time.Millisecond, Max: 20 * time.Millisecond}
+	draw := func() []time.Duration {
+		j := newJitter(7)
+		delays := make([]time.Duration, 10)
+		for i := range delays {
+			delays[i] = j.draw(latency)
+			assert.GreaterOrEqual(t, delays[i], latency.Min)
+			assert.LessOrEqual(t, delays[i], latency.Max)
+		}
+		return delays
+	}
+
+	first := draw()
+	assert.Equal(t, first, draw())
+	assert.NotEqual(t, first[0], first[1])
+	assert.Equal(t, time.Second, newJitter(0).draw(Latency{Min: time.Second, Max: time.Second}))
+}
@@ -0,0 +1,33 @@
+package backend
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+	"strings"
+)
+
+// docsPrefix is where the API explorer is served when EnableDocs is set
+const docsPrefix = "/docs"
+
+// swaggerUI holds the explorer page and the Swagger UI assets vendored from
+// swagger-ui-dist
+//
+//go:embed swagger-ui
+var swaggerUI embed.FS
+
+// registerDocs serves the embedded explorer of the OpenAPI document under
+// docsPrefix, loading nothing from outside the binary
+func (s *HTTPServer) registerDocs() {
+	assets, err := fs.Sub(swaggerUI, "swagger-ui")
+	if err != nil {
+		panic(err)
+	}
+	s.router.StaticFS(docsPrefix, http.FS(assets))
+}
+
+// undocumentedRoute reports whether the route pattern is left out of the
+// OpenAPI document: the runtime profiles and the explorer itself
+func undocumentedRoute(path string) bool {
+	return strings.HasPrefix(path, pprofPrefix) || strings.HasPrefix(path, docsPrefix+"/")
+}
@@ -0,0 +1,35 @@
+package backend
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDocs(t *testing.T) {
+	s := newTestServer(t)
+	assert.Equal(t, http.StatusNotFound, doRequest(s, http.MethodGet, "/docs/", "").Code)
+
+	t.Setenv("ENABLE_DOCS", "true")
+	s = newTestServer(t)
+
+	w := doRequest(s, http.MethodGet, "/docs", "")
+	assert.Equal(t, http.StatusMovedPermanently, w.Code)
+	assert.Equal(t, "/docs/", w.Header().Get("Location"))
+
+	w = doRequest(s, http.MethodGet, "/docs/", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/html")
+	assert.Contains(t, w.Body.String(), `"../openapi.json"`)
+	assert.NotContains(t, w.Body.String(), "https://", "no assets are loaded from outside the binary")
+	assert.Contains(t, w.Body.String(), `src="swagger-ui-bundle.js"`)
+	assert.NotContains(t, w.Body.String(), "onerror", "missing assets are not hidden")
+
+	for _, asset := range []string{"swagger-ui.css", "swagger-ui-bundle.js", "swagger-ui-standalone-preset.js"} {
+		w = doRequest(s, http.MethodGet, "/docs/"+asset, "")
+		assert.Equal(t, http.StatusOK, w.Code, asset)
+		assert.Positive(t, w.Body.Len(), asset)
+	}
+	assert.Contains(t, doRequest(s, http.MethodGet, "/docs/swagger-ui-bundle.js", "").Body.String(), "SwaggerUIBundle")
+}
@@ -0,0 +1,52 @@
+package backend
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// envInt returns the integer value of the environment variable key, or def
+// when it is unset. A value that is not an integer is recorded in errs and
+// def is returned.
+func envInt(errs fieldErrors, key string, def int) int {
+	return envValue(errs, key, def, "an integer", strconv.Atoi)
+}
+
+// envDuration returns the duration value of the environment variable key,
+// e.g. "5m", or def when it is unset. A value that is not a duration is
+// recorded in errs and def is returned.
+func envDuration(errs fieldErrors, key string, def time.Duration) time.Duration {
+	return envValue(errs, key, def, "a duration such as 5s", time.ParseDuration)
+}
+
+// envBool returns the boolean value of the environment variable key, or
+// def when it is unset. A value that is not a boolean is recorded in errs
+// and def is returned.
+func envBool(errs fieldErrors, key string, def bool) bool {
+	return envValue(errs, key, def, "true or false", strconv.ParseBool)
+}
+
+// envFloat returns the floating point value of the environment variable
+// key, or def when it is unset. A value that is not a number is recorded
+// in errs and def is returned.
+func envFloat(errs fieldErrors, key string, def float64) float64 {
+	return envValue(errs, key, def, "a number", func(v string) (float64, error) {
+		return strconv.ParseFloat(v, 64)
+	})
+}
+
+// envValue parses the environment variable key with parse, see envInt
+func envValue[T any](errs fieldErrors, key string, def T, want string, parse func(string) (T, error)) T {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return def
+	}
+	v, err := parse(raw)
+	if err != nil {
+		errs[key] = fmt.Sprintf("invalid value %q, must be %s", raw, want)
+		return def
+	}
+	return v
+}
@@ -0,0 +1,139 @@
+package backend
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIError is the body of every error response. Error describes the
+// problem for people and Code, one of the code constants, identifies it for
+// programs. Details adds context specific to the code, e.g. the problem
+// with each field of a request that failed validation.
+type APIError struct {
+	XMLName xml.Name     `json:"-" xml:"error"`
+	Error   string       `json:"error" xml:"message"`
+	Code    string       `json:"code" xml:"code"`
+	Details ErrorDetails `json:"details,omitempty" xml:"details,omitempty"`
+}
+
+// ErrorDetails maps a subject of an APIError, such as a field, to details
+// about it
+type ErrorDetails map[string]string
+
+// MarshalXML encodes the details as entry elements, see encodeXMLValue
+func (d ErrorDetails) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return encodeXMLValue(e, start, map[string]string(d))
+}
+
+// Error codes of APIError. They are part of the API and don't change once
+// published, unlike the messages.
+const (
+	codeInvalidRequest      = "invalid_request"
+	codeValidationFailed    = "validation_failed"
+	codeUnauthorized        = "unauthorized"
+	codeNotFound            = "not_found"
+	codeConflict            = "conflict"
+	codeVersionMismatch     = "version_mismatch"
+	codeIdempotencyConflict = "idempotency_conflict"
+	codeNotAcceptable       = "not_acceptable"
+	codeBodyTooLarge        = "body_too_large"
+	codeRateLimited         = "rate_limited"
+	codeInjected            = "injected"
+	codeInternal            = "internal"
+	codeUnavailable         = "unavailable"
+	codeUpstream            = "upstream_error"
+	codeUpstreamTimeout     = "upstream_timeout"
+)
+
+// respondError aborts the request with status and an APIError body
+func respondError(c *gin.Context, status int, code, msg string) {
+	c.Abort()
+	respond(c, status, APIError{Error: msg, Code: code})
+}
+
+// fieldErrors collects validation problems keyed by JSON field path such
+// as "email" or "preferences.theme"
+type fieldErrors map[string]string
+
+// Error lists the problems ordered by field
+func (e fieldErrors) Error() string {
+	fields := make([]string, 0, len(e))
+	for field := range e {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	msgs := make([]string, 0, len(fields))
+	for _, field := range fields {
+		msgs = append(msgs, field+": "+e[field])
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// add records err for field if it is not nil
+func (e fieldErrors) add(field string, err error) {
+	if err != nil {
+		e[field] = err.Error()
+	}
+}
+
+// err returns e as an error, or nil when no problems were recorded
+func (e fieldErrors) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// handleNoRoute answers requests matching no route with the JSON error
+// body instead of gin's plain text
+func handleNoRoute(c *gin.Context) {
+	respondError(c, http.StatusNotFound, codeNotFound, fmt.Sprintf("no route for %s %s", c.Request.Method, c.Request.URL.Path))
+}
+
+// toValidationResponse converts a validation or JSON decoding error into a
+// response body, extracting per-field details where the error has them
+func toValidationResponse(err error) APIError {
+	var fields fieldErrors
+	if errors.As(err, &fields) {
+		return APIError{Error: "validation failed", Code: codeValidationFailed, Details: ErrorDetails(fields)}
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return APIError{
+			Error:   "invalid request body",
+			Code:    codeInvalidRequest,
+			Details: map[string]string{typeErr.Field: "must be of type " + typeErr.Type.String()},
+		}
+	}
+
+	// encoding/json does not export a type for unknown field errors
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		return APIError{
+			Error:   "invalid request body",
+			Code:    codeInvalidRequest,
+			Details: map[string]string{strings.Trim(field, `"`): "unknown field"},
+		}
+	}
+
+	return APIError{Error: err.Error(), Code: codeInvalidRequest}
+}
+
+// respondValidationError writes a 400 response describing err, or a 413
+// response when err is caused by an oversized request body
+func respondValidationError(c *gin.Context, err error) {
+	if maxBytesErr, ok := asMaxBytesError(err); ok {
+		respondError(c, http.StatusRequestEntityTooLarge, codeBodyTooLarge, bodyTooLarge(maxBytesErr.Limit).Error())
+		return
+	}
+	c.Abort()
+	respond(c, http.StatusBadRequest, toValidationResponse(err))
+}
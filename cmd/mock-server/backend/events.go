@@ -0,0 +1,109 @@
+package backend
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	eventUserCreated = "user.created"
+	eventUserUpdated = "user.updated"
+	eventUserDeleted = "user.deleted"
+
+	eventHeartbeatInterval = 15 * time.Second
+	eventBufferSize        = 16
+)
+
+// userEvent describes a change to a user
+type userEvent struct {
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+	User *User     `json:"user"`
+}
+
+// eventBroker fans out user events to all subscribers. Each subscriber has
+// its own buffered channel; events are dropped for subscribers that fall
+// behind rather than blocking the publisher.
+type eventBroker struct {
+	mu          sync.RWMutex
+	subscribers map[chan userEvent]struct{}
+}
+
+func newEventBroker() *eventBroker {
+	return &eventBroker{
+		subscribers: make(map[chan userEvent]struct{}),
+	}
+}
+
+// Subscribe registers a new subscriber. The returned function must be
+// called to unregister it once the subscriber is done.
+func (b *eventBroker) Subscribe() (<-chan userEvent, func()) {
+	ch := make(chan userEvent, eventBufferSize)
+
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	return ch, func() {
+		b.mu.Lock()
+		delete(b.subscribers, ch)
+		b.mu.Unlock()
+	}
+}
+
+// Publish sends the event to every subscriber without blocking
+func (b *eventBroker) Publish(evt userEvent) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- evt:
+		default:
+		}
+	}
+}
+
+// publish emits an event of the given type for user
+func (s *HTTPServer) publish(eventType string, user *User) {
+	s.events.Publish(userEvent{Type: eventType, Time: s.clock.Now(), User: user})
+}
+
+// handleEvents streams user events as Server-Sent Events until the client
+// disconnects. A comment line is sent every eventHeartbeatInterval so idle
+// connections are not closed by proxies. The server's WriteTimeout is
+// lifted for the stream since it is meant to stay open.
+func (s *HTTPServer) handleEvents(c *gin.Context) {
+	events, unsubscribe := s.events.Subscribe()
+	defer unsubscribe()
+
+	_ = http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(eventHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(c.Writer, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		case evt := <-events:
+			c.SSEvent(evt.Type, evt)
+		}
+		c.Writer.Flush()
+	}
+}
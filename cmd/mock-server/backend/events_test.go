@@ -0,0 +1,43 @@
+package backend
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventsStreamsUserCreated(t *testing.T) {
+	s := newTestServer(t)
+	ts := httptest.NewServer(s.router)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/events")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	// The headers are flushed once the stream is subscribed
+	w := doRequest(s, http.MethodPost, "/users", `{"username":"alice","email":"alice@example.com"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	frame := map[string]string{}
+	lines := bufio.NewScanner(resp.Body)
+	for lines.Scan() && lines.Text() != "" {
+		field, value, _ := strings.Cut(lines.Text(), ":")
+		frame[field] = value
+	}
+	assert.Equal(t, eventUserCreated, frame["event"])
+	var evt userEvent
+	assert.NoError(t, json.Unmarshal([]byte(frame["data"]), &evt))
+	assert.Equal(t, eventUserCreated, evt.Type)
+	assert.Equal(t, "alice@example.com", evt.User.Email)
+	assert.False(t, evt.Time.IsZero())
+}
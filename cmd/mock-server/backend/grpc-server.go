@@ -0,0 +1,325 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/amoylab/unla/cmd/mock-server/backend/userpb"
+)
+
+//go:generate protoc -I userpb --go_out=userpb --go_opt=paths=source_relative --go-grpc_out=userpb --go-grpc_opt=paths=source_relative userpb/user.proto
+
+// GRPCServer serves the user CRUD operations of userpb.UserService. It
+// shares the user store, configuration and event stream of the HTTPServer
+// it was created from, so both transports see the same data. Server
+// reflection is enabled for clients such as grpcurl.
+type GRPCServer struct {
+	userpb.UnimplementedUserServiceServer
+
+	api      *HTTPServer
+	server   *grpc.Server
+	listener net.Listener
+}
+
+// NewGRPCServer creates a gRPC server backed by api
+func NewGRPCServer(api *HTTPServer) *GRPCServer {
+	s := &GRPCServer{api: api, server: grpc.NewServer()}
+	userpb.RegisterUserServiceServer(s.server, s)
+	reflection.Register(s.server)
+	return s
+}
+
+// Start binds addr and begins serving in the background. Bind failures are
+// returned directly; errors that stop the listener later are logged.
+func (s *GRPCServer) Start(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	s.listener = ln
+
+	go func() {
+		s.api.logger.Info("gRPC server is running on " + ln.Addr().String())
+		if err := s.server.Serve(ln); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+			s.api.logger.Error("gRPC server stopped", zap.Error(err))
+		}
+	}()
+	return nil
+}
+
+// Addr returns the address the server listens on, or nil before Start
+func (s *GRPCServer) Addr() net.Addr {
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
+// Stop waits up to the configured ShutdownTimeout for in-flight calls to
+// finish, then closes the remaining connections
+func (s *GRPCServer) Stop() error {
+	done := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-time.After(s.api.config().ShutdownTimeout):
+		s.server.Stop()
+		return errors.New("gRPC server shutdown timed out")
+	}
+}
+
+// CreateUser creates a user, applying the same validation and defaults as
+// POST /users
+func (s *GRPCServer) CreateUser(ctx context.Context, req *userpb.CreateUserRequest) (*userpb.User, error) {
+	user := fromProtoUser(req.GetUser())
+	if err := s.api.prepareUser(user); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err := s.api.users.Create(ctx, user); err != nil {
+		return nil, storeStatus(err)
+	}
+	s.api.publish(eventUserCreated, user)
+	return toProtoUser(user)
+}
+
+// GetUser returns the user with the given email
+func (s *GRPCServer) GetUser(ctx context.Context, req *userpb.GetUserRequest) (*userpb.User, error) {
+	user, err := s.api.users.Get(ctx, req.GetEmail())
+	if err == nil && user.DeletedAt != nil && !req.GetIncludeDeleted() {
+		err = errUserNotFound
+	}
+	if err != nil {
+		return nil, storeStatus(err)
+	}
+	return toProtoUser(user)
+}
+
+// ListUsers returns a page of users ordered by creation time, with the
+// same page size limits as GET /users
+func (s *GRPCServer) ListUsers(ctx context.Context, req *userpb.ListUsersRequest) (*userpb.ListUsersResponse, error) {
+	limit, offset := int(req.GetLimit()), int(req.GetOffset())
+	if limit < 0 || offset < 0 {
+		return nil, status.Error(codes.InvalidArgument, "limit and offset must not be negative")
+	}
+	if limit == 0 {
+		limit = defaultPageLimit
+	}
+	limit = min(limit, maxPageLimit)
+
+	list, err := s.api.listUsers(ctx, req.GetIncludeDeleted())
+	if err != nil {
+		return nil, storeStatus(err)
+	}
+	resp := &userpb.ListUsersResponse{Total: int32(len(list))}
+	for _, user := range paginate(list, limit, offset) {
+		pb, err := toProtoUser(user)
+		if err != nil {
+			return nil, err
+		}
+		resp.Users = append(resp.Users, pb)
+	}
+	return resp, nil
+}
+
+// UpdateUser updates the fields named by the update mask, as with
+// PATCH /users/:email
+func (s *GRPCServer) UpdateUser(ctx context.Context, req *userpb.UpdateUserRequest) (*userpb.User, error) {
+	patch, err := patchFromMask(req.GetUser(), req.GetUpdateMask().GetPaths())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	patch.normalize()
+	if err := patch.validate(s.api.config()); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	user, err := s.api.updateUser(ctx, req.GetEmail(), func(u *User) error {
+		patch.apply(u)
+		u.touch(s.api.clock.Now())
+		return nil
+	})
+	if err != nil {
+		return nil, storeStatus(err)
+	}
+	s.api.publish(eventUserUpdated, user)
+	return toProtoUser(user)
+}
+
+// DeleteUser removes a user and its uploaded avatar, or only marks it as
+// deleted when soft is set
+func (s *GRPCServer) DeleteUser(ctx context.Context, req *userpb.DeleteUserRequest) (*userpb.User, error) {
+	var user *User
+	var err error
+	if req.GetSoft() {
+		user, err = s.api.updateUser(ctx, req.GetEmail(), func(u *User) error {
+			now := s.api.clock.Now()
+			u.DeletedAt = &now
+			u.touch(now)
+			return nil
+		})
+	} else {
+		user, err = s.api.users.Delete(ctx, req.GetEmail())
+	}
+	if err != nil {
+		return nil, storeStatus(err)
+	}
+	if !req.GetSoft() {
+		if err := removeAvatarFile(user.AvatarPath); err != nil {
+			s.api.logger.Error("failed to remove avatar", zap.String("email", user.Email), zap.Error(err))
+		}
+	}
+	s.api.publish(eventUserDeleted, user)
+	return toProtoUser(user)
+}
+
+// storeStatus converts an error returned by the store into a gRPC status
+func storeStatus(err error) error {
+	switch {
+	case errors.Is(err, errUserNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, errUserExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, errStoreUnavailable):
+		return status.Error(codes.Unavailable, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+// patchFromMask builds a userPatch holding the fields of user named by
+// paths. Lists and settings named by the mask are replaced even when empty.
+func patchFromMask(user *userpb.User, paths []string) (*userPatch, error) {
+	if len(paths) == 0 {
+		return nil, errors.New("update_mask is required")
+	}
+
+	patch := &userPatch{}
+	prefs := fromProtoPreferences(user.GetPreferences())
+	for _, path := range paths {
+		if path == "username" {
+			patch.Username = &user.Username
+			continue
+		}
+		if patch.Preferences == nil {
+			patch.Preferences = &preferencesPatch{}
+		}
+		p := patch.Preferences
+		switch path {
+		case "preferences":
+			p.IsPublic, p.ShowEmail, p.Theme = &prefs.IsPublic, &prefs.ShowEmail, &prefs.Theme
+			p.Tags, p.Settings, p.Notifications = prefs.Tags, prefs.Settings, prefs.Notifications
+		case "preferences.is_public":
+			p.IsPublic = &prefs.IsPublic
+		case "preferences.show_email":
+			p.ShowEmail = &prefs.ShowEmail
+		case "preferences.theme":
+			p.Theme = &prefs.Theme
+		case "preferences.tags":
+			p.Tags = prefs.Tags
+		case "preferences.settings":
+			p.Settings = prefs.Settings
+		case "preferences.notifications":
+			p.Notifications = prefs.Notifications
+		default:
+			return nil, fmt.Errorf("unsupported update_mask path %q", path)
+		}
+	}
+	return patch, nil
+}
+
+// fromProtoUser converts the client-settable fields of pb into a User
+func fromProtoUser(pb *userpb.User) *User {
+	user := &User{
+		Username:  pb.GetUsername(),
+		Email:     pb.GetEmail(),
+		AvatarURL: pb.GetAvatarUrl(),
+	}
+	prefs := pb.GetPreferences()
+	user.Preferences.Theme = prefs.GetTheme()
+	if len(prefs.GetNotifications()) > 0 {
+		user.Preferences.Notifications = fromProtoNotifications(prefs.GetNotifications())
+	}
+	return user
+}
+
+// fromProtoPreferences converts pb into Preferences with non-nil lists and
+// settings
+func fromProtoPreferences(pb *userpb.Preferences) Preferences {
+	prefs := Preferences{
+		IsPublic:      pb.GetIsPublic(),
+		ShowEmail:     pb.GetShowEmail(),
+		Theme:         pb.GetTheme(),
+		Tags:          append([]string{}, pb.GetTags()...),
+		Settings:      map[string]any{},
+		Notifications: fromProtoNotifications(pb.GetNotifications()),
+	}
+	if pb.GetSettings() != nil {
+		prefs.Settings = pb.GetSettings().AsMap()
+	}
+	return prefs
+}
+
+func fromProtoNotifications(pbs []*userpb.Notification) []Notification {
+	notifications := make([]Notification, 0, len(pbs))
+	for _, n := range pbs {
+		notifications = append(notifications, Notification{
+			Type:      n.GetType(),
+			Channel:   n.GetChannel(),
+			Enabled:   n.GetEnabled(),
+			Frequency: n.GetFrequency(),
+		})
+	}
+	return notifications
+}
+
+// toProtoUser converts user to its protobuf form
+func toProtoUser(user *User) (*userpb.User, error) {
+	settings, err := structpb.NewStruct(user.Preferences.Settings)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	pb := &userpb.User{
+		Id:        user.ID,
+		Username:  user.Username,
+		Email:     user.Email,
+		CreatedAt: timestamppb.New(user.CreatedAt),
+		UpdatedAt: timestamppb.New(user.UpdatedAt),
+		Version:   int64(user.Version),
+		AvatarUrl: user.AvatarURL,
+		Preferences: &userpb.Preferences{
+			IsPublic:  user.Preferences.IsPublic,
+			ShowEmail: user.Preferences.ShowEmail,
+			Theme:     user.Preferences.Theme,
+			Tags:      user.Preferences.Tags,
+			Settings:  settings,
+		},
+	}
+	if user.DeletedAt != nil {
+		pb.DeletedAt = timestamppb.New(*user.DeletedAt)
+	}
+	for _, n := range user.Preferences.Notifications {
+		pb.Preferences.Notifications = append(pb.Preferences.Notifications, &userpb.Notification{
+			Type:      n.Type,
+			Channel:   n.Channel,
+			Enabled:   n.Enabled,
+			Frequency: n.Frequency,
+		})
+	}
+	return pb, nil
+}
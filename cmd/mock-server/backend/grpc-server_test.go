@@ -0,0 +1,82 @@
+package backend
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+
+	"github.com/amoylab/unla/cmd/mock-server/backend/userpb"
+)
+
+func newTestGRPCClient(t *testing.T, s *HTTPServer) userpb.UserServiceClient {
+	t.Helper()
+	g := NewGRPCServer(s)
+	assert.NoError(t, g.Start("127.0.0.1:0"))
+	t.Cleanup(func() { g.Stop() })
+
+	conn, err := grpc.NewClient(g.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return userpb.NewUserServiceClient(conn)
+}
+
+func TestGRPCSharesUserStore(t *testing.T) {
+	s := newTestServer(t)
+	client := newTestGRPCClient(t, s)
+	ctx := context.Background()
+
+	created, err := client.CreateUser(ctx, &userpb.CreateUserRequest{User: &userpb.User{
+		Username: "alice", Email: "Alice@example.com",
+	}})
+	assert.NoError(t, err)
+	assert.Equal(t, "alice@example.com", created.GetEmail())
+	assert.Equal(t, "light", created.GetPreferences().GetTheme())
+
+	w := doRequest(s, http.MethodGet, "/users/email/alice@example.com", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), created.GetId())
+
+	doRequest(s, http.MethodPost, "/users", `{"username":"bob","email":"bob@example.com"}`)
+	list, err := client.ListUsers(ctx, &userpb.ListUsersRequest{})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, list.GetTotal())
+
+	_, err = client.CreateUser(ctx, &userpb.CreateUserRequest{User: &userpb.User{Username: "bob", Email: "bob@example.com"}})
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+}
+
+func TestGRPCUpdateAndDeleteUser(t *testing.T) {
+	s := newTestServer(t)
+	client := newTestGRPCClient(t, s)
+	ctx := context.Background()
+	doRequest(s, http.MethodPost, "/users", `{"username":"alice","email":"alice@example.com"}`)
+
+	updated, err := client.UpdateUser(ctx, &userpb.UpdateUserRequest{
+		Email:      "alice@example.com",
+		User:       &userpb.User{Username: "alice2", Preferences: &userpb.Preferences{Theme: "dark"}},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"preferences.theme"}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", updated.GetUsername())
+	assert.Equal(t, "dark", updated.GetPreferences().GetTheme())
+	assert.EqualValues(t, 2, updated.GetVersion())
+
+	_, err = client.UpdateUser(ctx, &userpb.UpdateUserRequest{
+		Email:      "alice@example.com",
+		User:       &userpb.User{Preferences: &userpb.Preferences{Theme: "neon"}},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"preferences.theme"}},
+	})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = client.DeleteUser(ctx, &userpb.DeleteUserRequest{Email: "alice@example.com"})
+	assert.NoError(t, err)
+	_, err = client.GetUser(ctx, &userpb.GetUserRequest{Email: "alice@example.com"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
@@ -0,0 +1,54 @@
+package backend
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGzip(t *testing.T) {
+	s := newTestServer(t)
+	for i := 0; i < 20; i++ {
+		doRequest(s, http.MethodPost, "/users",
+			fmt.Sprintf(`{"username":"user%d","email":"user%d@example.com"}`, i, i))
+	}
+
+	tests := []struct {
+		name           string
+		target         string
+		acceptEncoding string
+		wantGzip       bool
+	}{
+		{"large list", "/users", "gzip, deflate", true},
+		{"gzip not accepted", "/users", "", false},
+		{"gzip refused", "/users", "gzip;q=0", false},
+		{"tiny payload", "/users/count", "gzip", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			w := httptest.NewRecorder()
+			s.router.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			if !tt.wantGzip {
+				assert.Empty(t, w.Header().Get("Content-Encoding"))
+				assert.Contains(t, w.Body.String(), "{")
+				return
+			}
+			assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+			assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+			zr, err := gzip.NewReader(w.Body)
+			assert.NoError(t, err)
+			body, err := io.ReadAll(zr)
+			assert.NoError(t, err)
+			assert.Contains(t, string(body), "user19@example.com")
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package backend
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// handleHealthz reports liveness: it answers 200 for as long as the
+// process is able to serve requests at all
+func (s *HTTPServer) handleHealthz(c *gin.Context) {
+	respond(c, http.StatusOK, gin.H{"status": "ok"})
+}
+
+// handleReadyz reports readiness. It answers 503 before StartAll has
+// bound every listener, once Stop has begun draining, and while the
+// weather circuit breaker is open because the provider is unreachable or
+// a remote user store does not answer. The result of every check is
+// included in the response.
+func (s *HTTPServer) handleReadyz(c *gin.Context) {
+	checks := gin.H{}
+	ready := true
+
+	switch {
+	case s.draining.Load():
+		checks["server"] = "draining"
+		ready = false
+	case !s.started.Load():
+		checks["server"] = "starting"
+		ready = false
+	default:
+		checks["server"] = "serving"
+	}
+
+	breaker := s.weatherBreaker.State()
+	checks["weather"] = breaker
+	if breaker == breakerOpen {
+		ready = false
+	}
+
+	if pinger, ok := s.users.(storePinger); ok {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), storePingTimeout)
+		err := pinger.Ping(ctx)
+		cancel()
+		if err != nil {
+			s.requestLogger(c).Warn("user store not ready", zap.Error(err))
+			checks["store"] = "unavailable"
+			ready = false
+		} else {
+			checks["store"] = "ok"
+		}
+	}
+
+	if !ready {
+		respond(c, http.StatusServiceUnavailable, gin.H{"status": "unavailable", "checks": checks})
+		return
+	}
+	respond(c, http.StatusOK, gin.H{"status": "ready", "checks": checks})
+}
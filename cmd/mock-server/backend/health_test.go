@@ -0,0 +1,51 @@
+package backend
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthz(t *testing.T) {
+	s := newTestServer(t)
+	w := doRequest(s, http.MethodGet, "/healthz", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"status":"ok"}`, w.Body.String())
+}
+
+func TestReadyz(t *testing.T) {
+	s := newTestServer(t)
+	readyz := func() (int, map[string]any) {
+		w := doRequest(s, http.MethodGet, "/readyz", "")
+		var body struct {
+			Checks map[string]any `json:"checks"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return w.Code, body.Checks
+	}
+
+	code, checks := readyz()
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "starting", checks["server"])
+
+	assert.NoError(t, s.StartAll("127.0.0.1:0"))
+	code, checks = readyz()
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, map[string]any{"server": "serving", "weather": breakerClosed}, checks)
+
+	s.weatherBreaker.Configure(1, time.Minute)
+	s.weatherBreaker.Done(allow(t, s.weatherBreaker), true, false)
+	code, checks = readyz()
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, breakerOpen, checks["weather"])
+
+	s.weatherBreaker.Configure(1, time.Minute)
+	assert.NoError(t, s.Stop())
+	code, checks = readyz()
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "draining", checks["server"])
+	assert.Equal(t, breakerClosed, checks["weather"])
+}
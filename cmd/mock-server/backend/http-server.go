@@ -1,219 +1,963 @@
 package backend
 
 import (
+	"bytes"
 	"context"
-	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
-	"github.com/joho/godotenv"
 	"go.uber.org/zap"
 )
 
 // Notification represents a user's notification preference
 type Notification struct {
-	Type      string  `json:"type"`      // email, push, sms
-	Channel   string  `json:"channel"`   // marketing, system, security
-	Enabled   bool    `json:"enabled"`   // whether this notification is enabled
-	Frequency float64 `json:"frequency"` // 0: realtime, 1: daily, 2: weekly, 3: monthly
+	XMLName   xml.Name `json:"-" xml:"notification"`
+	Type      string   `json:"type" xml:"type"`           // email, push, sms
+	Channel   string   `json:"channel" xml:"channel"`     // marketing, system, security
+	Enabled   bool     `json:"enabled" xml:"enabled"`     // whether this notification is enabled
+	Frequency float64  `json:"frequency" xml:"frequency"` // 0: realtime, 1: daily, 2: weekly, 3: monthly
 }
 
 type User struct {
-	ID        string    `json:"id"`
-	Username  string    `json:"username"`
-	Email     string    `json:"email"`
-	CreatedAt time.Time `json:"createdAt"`
+	XMLName   xml.Name  `json:"-" xml:"user"`
+	ID        string    `json:"id" xml:"id"`
+	Username  string    `json:"username" xml:"username"`
+	Email     string    `json:"email" xml:"email"`
+	CreatedAt time.Time `json:"createdAt" xml:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt" xml:"updatedAt"`
+	// Version starts at 1 and is incremented on every update
+	Version int `json:"version" xml:"version"`
+	// DeletedAt is set when the user is soft-deleted
+	DeletedAt *time.Time `json:"deletedAt,omitempty" xml:"deletedAt,omitempty"`
+	// AvatarURL is the avatar image URL set through the avatar endpoint
+	AvatarURL string `json:"avatarUrl,omitempty" xml:"avatarUrl,omitempty"`
+	// AvatarPath is the file holding the uploaded avatar, if any. It is
+	// internal and never sent to clients.
+	AvatarPath string `json:"-" xml:"-"`
 	// Add new fields for testing
-	Preferences struct {
-		IsPublic      bool           `json:"isPublic"`
-		ShowEmail     bool           `json:"showEmail"`
-		Theme         string         `json:"theme"`
-		Tags          []string       `json:"tags"`
-		Settings      map[string]any `json:"settings"`
-		Notifications []Notification `json:"notifications"`
-	} `json:"preferences"`
+	Preferences Preferences `json:"preferences" xml:"preferences"`
 }
 
-var users = make(map[string]*User)
+// Preferences holds a user's settings
+type Preferences struct {
+	IsPublic      bool           `json:"isPublic" xml:"isPublic"`
+	ShowEmail     bool           `json:"showEmail" xml:"showEmail"`
+	Theme         string         `json:"theme" xml:"theme"`
+	Tags          []string       `json:"tags" xml:"tags>tag"`
+	Settings      Settings       `json:"settings" xml:"settings"`
+	Notifications []Notification `json:"notifications" xml:"notifications>notification"`
+}
+
+// Settings holds free-form user settings, any JSON object
+type Settings map[string]any
+
+// MarshalXML encodes the settings as entry elements, see encodeXMLValue
+func (s Settings) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return encodeXMLValue(e, start, map[string]any(s))
+}
 
 // HTTPServer implements the Server interface
 type HTTPServer struct {
-	server *http.Server
-	router *gin.Engine
-	logger *zap.Logger
+	// servers and listeners hold one entry per address being served
+	servers   []*http.Server
+	listeners []net.Listener
+	router    *gin.Engine
+
+	// mu guards the settings replaced by Reload
+	mu      sync.RWMutex
+	cfg     Config
+	weather WeatherProvider
+
+	logger         *zap.Logger
+	logLevel       zap.AtomicLevel
+	users          Store
+	idempotency    *idempotencyCache
+	rateLimiter    *rateLimiter
+	jwks           *jwksCache
+	events         *eventBroker
+	audit          *auditLog
+	ids            IDGenerator
+	clock          Clock
+	weatherCache   *weatherCache
+	weatherBreaker *circuitBreaker
+	metrics        *metrics
+	tracing        *tracing
+	chaos          *chaos
+	jitter         *jitter
+	stubs          *stubSet
+	// upstream sends the requests proxied by handleProxy
+	upstream *http.Client
+	// serveErrs receives the error that stopped the listener
+	serveErrs chan error
+	// inFlight counts the requests being handled
+	inFlight atomic.Int64
+	// started and draining drive /readyz: started is set once every
+	// listener is bound, draining once Stop begins
+	started  atomic.Bool
+	draining atomic.Bool
 }
 
+// NewHTTPServer creates a server configured from the CONFIG_FILE and the
+// environment, see LoadConfig. It exits listing the problems when the
+// configuration is invalid, see Config.Validate.
 func NewHTTPServer() *HTTPServer {
-	// 加载 .env 文件
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found, using environment variables directly")
+	cfg, err := LoadConfig("")
+	if err == nil {
+		err = cfg.Validate()
 	}
+	if err != nil {
+		log.Fatalf("invalid configuration: %v", err)
+	}
+	return NewHTTPServerWithConfig(cfg)
+}
+
+// NewHTTPServerWithConfig creates a server with the given configuration
+func NewHTTPServerWithConfig(cfg Config) *HTTPServer {
 	// Initialize logger
-	logger, err := zap.NewProduction()
+	logger, logLevel, err := NewLogger(cfg)
 	if err != nil {
 		panic(err)
 	}
 
-	// Initialize router
-	router := gin.Default()
+	if err := cfg.APIKeys.load(); err != nil {
+		logger.Fatal("failed to load API keys", zap.Error(err))
+	}
+	weather, err := newWeatherProvider(cfg, logger)
+	if err != nil {
+		logger.Fatal("failed to set up weather provider", zap.Error(err))
+	}
+	tracing, err := newTracing(cfg)
+	if err != nil {
+		logger.Fatal("failed to set up tracing", zap.Error(err))
+	}
+	stubs, err := newStubSet(cfg.StubDir, cfg.StubReloadInterval, logger)
+	if err != nil {
+		logger.Fatal("failed to load stubs", zap.Error(err))
+	}
+	users, err := newStore(cfg, logger)
+	if err != nil {
+		logger.Fatal("failed to open user store", zap.Error(err))
+	}
+	if pinger, ok := users.(storePinger); ok {
+		ctx, cancel := context.WithTimeout(context.Background(), storePingTimeout)
+		if err := pinger.Ping(ctx); err != nil {
+			logger.Warn("user store unavailable, serving anyway", zap.String("backend", cfg.StoreBackend), zap.Error(err))
+		}
+		cancel()
+	}
+
+	s := &HTTPServer{
+		router:         gin.New(),
+		cfg:            cfg,
+		logger:         logger,
+		logLevel:       logLevel,
+		users:          users,
+		idempotency:    newIdempotencyCache(idempotencyKeyTTL),
+		rateLimiter:    newRateLimiter(cfg.RateLimit.IdleTTL),
+		jwks:           newJWKSCache(),
+		events:         newEventBroker(),
+		audit:          newAuditLog(cfg.AuditLogSize),
+		ids:            uuidGenerator{},
+		clock:          systemClock{},
+		serveErrs:      make(chan error, 1),
+		weather:        weather,
+		weatherCache:   newWeatherCache(cfg.WeatherCacheTTL),
+		weatherBreaker: newCircuitBreaker(cfg.WeatherBreakerThreshold, cfg.WeatherBreakerCooldown),
+		tracing:        tracing,
+		chaos:          newChaos(cfg.Chaos.Seed),
+		jitter:         newJitter(cfg.Delay.Seed),
+		stubs:          stubs,
+		upstream:       &http.Client{Timeout: upstreamTimeout},
+	}
+	// MOCK_DETERMINISTIC=1 makes responses reproducible for snapshot tests
+	if os.Getenv("MOCK_DETERMINISTIC") == "1" {
+		s.ids = &counterIDGenerator{}
+		s.clock = fixedClock{t: deterministicTime}
+	}
+	s.metrics = newMetrics(s)
+
+	s.router.Use(
+		requestIDMiddleware(),
+		s.tracingMiddleware(),
+		s.metricsMiddleware(),
+		s.accessLogMiddleware(),
+		s.gzipMiddleware(),
+		s.recoveryMiddleware(),
+		s.corsMiddleware(),
+		s.apiKeyMiddleware(),
+		s.jwtMiddleware(),
+		s.bodyLimitMiddleware(),
+		s.rateLimitMiddleware(),
+		s.basicAuthMiddleware(),
+		s.inFlightMiddleware(),
+		s.delayMiddleware(),
+		s.mockHeadersMiddleware(),
+		s.chaosMiddleware(),
+		s.stubMiddleware(),
+		s.auditMiddleware(),
+	)
+
+	s.registerRoutes(cfg)
+
+	return s
+}
+
+// inFlightMiddleware counts the requests being handled so shutdown can
+// report how many were cut off
+func (s *HTTPServer) inFlightMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		s.inFlight.Add(1)
+		defer s.inFlight.Add(-1)
+		c.Next()
+	}
+}
+
+// handleCreateUser creates a user. When an Idempotency-Key header is sent,
+// the 201 response is cached for idempotencyKeyTTL and replayed for retries
+// with the same key and body instead of creating the user again. Reusing a
+// key with a different body is rejected with 422. Failed requests are not
+// cached so the client can correct and retry them under the same key.
+func (s *HTTPServer) handleCreateUser(c *gin.Context) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		respondValidationError(c, err)
+		return
+	}
 
-	// Register routes
-	router.POST("/users", func(c *gin.Context) {
-		var user User
-		if err := c.ShouldBindJSON(&user); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	idempotencyKey := c.GetHeader("Idempotency-Key")
+	if idempotencyKey != "" {
+		cached, err := s.idempotency.Lookup(idempotencyKey, body)
+		if err != nil {
+			respondError(c, http.StatusUnprocessableEntity, codeIdempotencyConflict, err.Error())
 			return
 		}
+		if cached != nil {
+			c.Header("Idempotent-Replayed", "true")
+			respond(c, cached.status, cached.body)
+			return
+		}
+	}
 
-		// Generate ID and timestamp
-		user.ID = uuid.New().String()
-		user.CreatedAt = time.Now()
+	var user User
+	if err := decodeStrict(bytes.NewReader(body), &user); err != nil {
+		respondValidationError(c, err)
+		return
+	}
+	if err := s.prepareUser(&user); err != nil {
+		respondValidationError(c, err)
+		return
+	}
 
-		// Initialize default values
-		user.Preferences.IsPublic = false
-		user.Preferences.ShowEmail = true
-		user.Preferences.Theme = "light"
-		user.Preferences.Tags = []string{}
-		user.Preferences.Settings = make(map[string]any)
-		user.Preferences.Notifications = []Notification{}
+	// Store user
+	if err := s.users.Create(c.Request.Context(), &user); err != nil {
+		s.respondStoreError(c, err)
+		return
+	}
+	s.publish(eventUserCreated, &user)
+	c.Set(auditEmailKey, user.Email)
 
-		// Store user
-		users[user.Email] = &user
+	if idempotencyKey != "" {
+		s.idempotency.Store(idempotencyKey, body, http.StatusCreated, user.clone())
+	}
+	respond(c, http.StatusCreated, user)
+}
 
-		c.JSON(http.StatusCreated, user)
-	})
+// bulkCreateResult reports the outcome of one item of a bulk creation
+type bulkCreateResult struct {
+	Status int          `json:"status"`
+	User   *User        `json:"user,omitempty"`
+	Error  string       `json:"error,omitempty"`
+	Fields ErrorDetails `json:"fields,omitempty"`
+}
 
-	router.GET("/users/email/:email", func(c *gin.Context) {
-		email := c.Param("email")
-		user, exists := users[email]
-		if !exists {
-			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
-			return
+// handleBulkCreateUsers creates every user in the request array. Each item
+// gets its own status so invalid or duplicate entries don't fail the whole
+// batch. The response is 201 when all items succeed and 207 otherwise.
+func (s *HTTPServer) handleBulkCreateUsers(c *gin.Context) {
+	var input []User
+	if err := decodeStrict(c.Request.Body, &input); err != nil {
+		respondValidationError(c, err)
+		return
+	}
+
+	results := make([]bulkCreateResult, len(input))
+	valid := make([]*User, 0, len(input))
+	validIdx := make([]int, 0, len(input))
+	for i := range input {
+		if err := s.prepareUser(&input[i]); err != nil {
+			resp := toValidationResponse(err)
+			results[i] = bulkCreateResult{Status: http.StatusBadRequest, Error: resp.Error, Fields: resp.Details}
+			continue
 		}
+		valid = append(valid, &input[i])
+		validIdx = append(validIdx, i)
+	}
 
-		c.JSON(http.StatusOK, user)
+	status := http.StatusCreated
+	for j, err := range s.users.CreateMany(c.Request.Context(), valid) {
+		i := validIdx[j]
+		switch {
+		case errors.Is(err, errUserExists):
+			results[i] = bulkCreateResult{Status: http.StatusConflict, Error: err.Error()}
+			continue
+		case err != nil:
+			s.requestLogger(c).Error("user store failed", zap.Error(err))
+			results[i] = bulkCreateResult{Status: http.StatusInternalServerError, Error: "failed to access user store"}
+			continue
+		}
+		results[i] = bulkCreateResult{Status: http.StatusCreated, User: valid[j]}
+		s.publish(eventUserCreated, valid[j])
+	}
+	for _, r := range results {
+		if r.Status != http.StatusCreated {
+			status = http.StatusMultiStatus
+			break
+		}
+	}
+
+	respond(c, status, results)
+}
+
+// prepareUser validates a user submitted for creation and fills in the
+// server-assigned fields and default preferences. Preferences always start
+// from the configured defaults, with two exceptions: a non-empty theme in
+// the request body replaces the default theme, and notifications supplied
+// in the request body replace the default notifications entirely; sending
+// an empty array creates the user without any.
+func (s *HTTPServer) prepareUser(user *User) error {
+	user.Email = normalizeEmail(user.Email)
+	errs := fieldErrors{}
+	errs.add("email", validateEmail(user.Email))
+	errs.add("username", validateUsername(user.Username))
+	if user.AvatarURL != "" {
+		errs.add("avatarUrl", validateAvatarURL(user.AvatarURL))
+	}
+	if user.Preferences.Theme != "" {
+		errs.add("preferences.theme", validateTheme(user.Preferences.Theme, s.config().Themes))
+	}
+	validateNotifications(errs, "preferences.", user.Preferences.Notifications)
+	if err := errs.err(); err != nil {
+		return err
+	}
+
+	// Generate ID and timestamp
+	user.ID = s.ids.NewID()
+	user.CreatedAt = s.clock.Now()
+	user.UpdatedAt = user.CreatedAt
+	user.Version = 1
+
+	// Initialize default values
+	theme, notifications := user.Preferences.Theme, user.Preferences.Notifications
+	user.Preferences = s.config().DefaultPreferences.clone()
+	if theme != "" {
+		user.Preferences.Theme = theme
+	}
+	if notifications != nil {
+		user.Preferences.Notifications = notifications
+	}
+	return nil
+}
+
+// includeDeleted reports whether the request asks for soft-deleted users
+// to be included via ?includeDeleted=true
+func includeDeleted(c *gin.Context) bool {
+	return c.Query("includeDeleted") == "true"
+}
+
+// listUsers returns all users, leaving out soft-deleted ones unless
+// withDeleted is set
+func (s *HTTPServer) listUsers(ctx context.Context, withDeleted bool) ([]*User, error) {
+	list, err := s.users.List(ctx)
+	if err != nil || withDeleted {
+		return list, err
+	}
+	return slices.DeleteFunc(list, func(u *User) bool { return u.DeletedAt != nil }), nil
+}
+
+// getUser returns the user with the given email, treating soft-deleted
+// users as missing
+func (s *HTTPServer) getUser(ctx context.Context, email string) (*User, error) {
+	user, err := s.users.Get(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	if user.DeletedAt != nil {
+		return nil, errUserNotFound
+	}
+	return user, nil
+}
+
+// updateUser applies fn to the user with the given email, treating
+// soft-deleted users as missing
+func (s *HTTPServer) updateUser(ctx context.Context, email string, fn func(*User) error) (*User, error) {
+	return s.users.Update(ctx, email, func(u *User) error {
+		if u.DeletedAt != nil {
+			return errUserNotFound
+		}
+		return fn(u)
 	})
+}
 
-	// Add new endpoint for updating user preferences
-	router.PUT("/users/:email/preferences", func(c *gin.Context) {
-		email := c.Param("email")
-		user, exists := users[email]
-		if !exists {
-			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+// handleListUsers returns a page of users ordered by creation time. The
+// total number of users is reported in the X-Total-Count header.
+// Soft-deleted users are left out unless ?includeDeleted=true is given, and
+// the list is narrowed by the parameters of userFilter.
+//
+// Pages are selected by offset, or with ?cursor= by the nextCursor of the
+// previous page, starting with an empty cursor. Cursor pages are returned
+// as a userPage and don't skip or repeat users created or deleted during
+// the scan.
+func (s *HTTPServer) handleListUsers(c *gin.Context) {
+	limit, offset, err := parsePagination(c)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+	cursor, byCursor := c.GetQuery("cursor")
+	if byCursor && offset != 0 {
+		respondError(c, http.StatusBadRequest, codeInvalidRequest, "cursor and offset can't be combined")
+		return
+	}
+	filter, err := parseUserFilter(c)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+
+	list, err := s.listUsers(c.Request.Context(), includeDeleted(c))
+	if err != nil {
+		s.respondStoreError(c, err)
+		return
+	}
+	list = filter.apply(list)
+	c.Header("X-Total-Count", strconv.Itoa(len(list)))
+	if !byCursor {
+		respond(c, http.StatusOK, paginate(list, limit, offset))
+		return
+	}
+	page, err := userPageAfter(list, cursor, limit)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+	respond(c, http.StatusOK, page)
+}
+
+// handleCountUsers reports the number of active users, with soft-deleted
+// users counted separately
+func (s *HTTPServer) handleCountUsers(c *gin.Context) {
+	active, deleted, err := s.users.Count(c.Request.Context())
+	if err != nil {
+		s.respondStoreError(c, err)
+		return
+	}
+	respond(c, http.StatusOK, gin.H{"count": active, "deleted": deleted})
+}
+
+// handleSearchUsers returns the users whose username contains q, ignoring
+// case, narrowed by the parameters of userFilter. An optional limit caps
+// the number of results.
+func (s *HTTPServer) handleSearchUsers(c *gin.Context) {
+	q := strings.ToLower(c.Query("q"))
+	if q == "" {
+		respondError(c, http.StatusBadRequest, codeInvalidRequest, "missing search query q")
+		return
+	}
+
+	limit := -1
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			respondError(c, http.StatusBadRequest, codeInvalidRequest, fmt.Sprintf("invalid limit %q", v))
 			return
 		}
+		limit = n
+	}
+	filter, err := parseUserFilter(c)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
 
-		var preferences struct {
-			IsPublic      bool           `json:"isPublic"`
-			ShowEmail     bool           `json:"showEmail"`
-			Theme         string         `json:"theme"`
-			Tags          []string       `json:"tags"`
-			Settings      map[string]any `json:"settings"`
-			Notifications []Notification `json:"notifications"`
+	list, err := s.listUsers(c.Request.Context(), false)
+	if err != nil {
+		s.respondStoreError(c, err)
+		return
+	}
+	matches := []*User{}
+	for _, user := range list {
+		if limit >= 0 && len(matches) >= limit {
+			break
+		}
+		if strings.Contains(strings.ToLower(user.Username), q) && filter.match(user) {
+			matches = append(matches, user)
 		}
+	}
 
-		if err := c.ShouldBindJSON(&preferences); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
+	respond(c, http.StatusOK, matches)
+}
+
+func (s *HTTPServer) handleGetUser(c *gin.Context) {
+	email := c.Param("email")
+	user, err := s.users.Get(c.Request.Context(), email)
+	if err == nil && user.DeletedAt != nil && !includeDeleted(c) {
+		err = errUserNotFound
+	}
+	if err != nil {
+		s.respondStoreError(c, err)
+		return
+	}
+
+	etag := userETag(user)
+	c.Header("ETag", etag)
+	if etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	respond(c, http.StatusOK, user)
+}
+
+func (s *HTTPServer) handleGetUserByID(c *gin.Context) {
+	user, err := s.users.GetByID(c.Request.Context(), c.Param("id"))
+	if err == nil && user.DeletedAt != nil && !includeDeleted(c) {
+		err = errUserNotFound
+	}
+	if err != nil {
+		s.respondStoreError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, user)
+}
+
+// handlePatchUser updates only the fields present in the request body.
+// Unknown fields are rejected so typos don't silently become no-ops.
+func (s *HTTPServer) handlePatchUser(c *gin.Context) {
+	email := c.Param("email")
+
+	var patch userPatch
+	if err := decodeStrict(c.Request.Body, &patch); err != nil {
+		respondValidationError(c, err)
+		return
+	}
+	patch.normalize()
+	if err := patch.validate(s.config()); err != nil {
+		respondValidationError(c, err)
+		return
+	}
+
+	user, err := s.updateUser(c.Request.Context(), email, func(u *User) error {
+		patch.apply(u)
+		u.touch(s.clock.Now())
+		return nil
+	})
+	if err != nil {
+		s.respondStoreError(c, err)
+		return
+	}
+	s.publish(eventUserUpdated, user)
+
+	respond(c, http.StatusOK, user)
+}
+
+// handleDeleteUser removes a user along with its uploaded avatar. With
+// ?soft=true the user is only marked as deleted and can be brought back
+// with the restore endpoint, so the avatar is kept. With ?return=true the
+// deleted user is returned in the body instead of an empty 204 response.
+func (s *HTTPServer) handleDeleteUser(c *gin.Context) {
+	email := c.Param("email")
+	soft := c.Query("soft") == "true"
+
+	var user *User
+	var err error
+	if soft {
+		user, err = s.updateUser(c.Request.Context(), email, func(u *User) error {
+			now := s.clock.Now()
+			u.DeletedAt = &now
+			u.touch(now)
+			return nil
+		})
+	} else {
+		user, err = s.users.Delete(c.Request.Context(), email)
+	}
+	if err != nil {
+		s.respondStoreError(c, err)
+		return
+	}
+	if !soft {
+		if err := removeAvatarFile(user.AvatarPath); err != nil {
+			s.requestLogger(c).Error("failed to remove avatar", zap.String("email", user.Email), zap.Error(err))
 		}
+	}
+	s.publish(eventUserDeleted, user)
+
+	if c.Query("return") == "true" {
+		respond(c, http.StatusOK, user)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
 
-		user.Preferences = preferences
-		c.JSON(http.StatusOK, user)
+// handleRestoreUser clears the soft-delete marker of a user. Restoring a
+// user that isn't deleted is a no-op.
+func (s *HTTPServer) handleRestoreUser(c *gin.Context) {
+	email := c.Param("email")
+	user, err := s.users.Update(c.Request.Context(), email, func(u *User) error {
+		if u.DeletedAt != nil {
+			u.DeletedAt = nil
+			u.touch(s.clock.Now())
+		}
+		return nil
 	})
+	if err != nil {
+		s.respondStoreError(c, err)
+		return
+	}
+	s.publish(eventUserUpdated, user)
+
+	respond(c, http.StatusOK, user)
+}
 
-	router.POST("/users/:email/avatar", func(c *gin.Context) {
-		email := c.Param("email")
-		_, exists := users[email]
-		if !exists {
-			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+// handleUpdatePreferences replaces the preferences of an existing user.
+// With ?merge=true only the fields present in the body are overwritten and
+// the others are kept, as with PATCH /users/:email. Note that tags,
+// settings and notifications are still replaced as a whole when present.
+// An If-Match header carrying the expected version turns the update into a
+// compare-and-swap that fails with 412 when the stored version differs.
+// Without If-Match the update is applied unconditionally.
+func (s *HTTPServer) handleUpdatePreferences(c *gin.Context) {
+	email := c.Param("email")
+
+	expectedVersion, err := parseIfMatchVersion(c.GetHeader("If-Match"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+
+	var apply func(*Preferences)
+	errs := fieldErrors{}
+	if c.Query("merge") == "true" {
+		var patch preferencesPatch
+		if err := decodeStrict(c.Request.Body, &patch); err != nil {
+			respondValidationError(c, err)
 			return
 		}
-
-		avatarURL := c.PostForm("url")
-		if avatarURL == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "missing url in form"})
+		patch.normalize()
+		patch.validate(errs, "", s.config())
+		apply = patch.apply
+	} else {
+		var preferences Preferences
+		if err := decodeStrict(c.Request.Body, &preferences); err != nil {
+			respondValidationError(c, err)
 			return
 		}
+		preferences.Tags = normalizeTags(preferences.Tags)
+		errs.add("theme", validateTheme(preferences.Theme, s.config().Themes))
+		errs.add("tags", validateTags(preferences.Tags, s.config().MaxTags))
+		errs.add("settings", validateSettings(preferences.Settings, s.config().SettingsLimits))
+		validateNotifications(errs, "", preferences.Notifications)
+		apply = func(p *Preferences) { *p = preferences }
+	}
+	if err := errs.err(); err != nil {
+		respondValidationError(c, err)
+		return
+	}
 
-		c.JSON(http.StatusOK, gin.H{
-			"message":   "avatar updated",
-			"avatarUrl": avatarURL,
-		})
+	user, err := s.updateUser(c.Request.Context(), email, func(u *User) error {
+		if expectedVersion != 0 && u.Version != expectedVersion {
+			return errVersionMismatch
+		}
+		apply(&u.Preferences)
+		u.touch(s.clock.Now())
+		return nil
 	})
+	switch {
+	case errors.Is(err, errVersionMismatch):
+		respondError(c, http.StatusPreconditionFailed, codeVersionMismatch, err.Error())
+		return
+	case err != nil:
+		s.respondStoreError(c, err)
+		return
+	}
+	s.publish(eventUserUpdated, user)
 
-	// 获取天气 API Key
-	weatherAPIKey := os.Getenv("WEATHER_API_KEY") // 从环境变量获取
-	if weatherAPIKey == "" {
-		logger.Fatal("WEATHER_API_KEY not set in environment")
+	respond(c, http.StatusOK, user)
+}
+
+// handleResetPreferences restores the preferences of an existing user to
+// the configured defaults that new users start with
+func (s *HTTPServer) handleResetPreferences(c *gin.Context) {
+	user, err := s.updateUser(c.Request.Context(), c.Param("email"), func(u *User) error {
+		u.Preferences = s.config().DefaultPreferences.clone()
+		u.touch(s.clock.Now())
+		return nil
+	})
+	if err != nil {
+		s.respondStoreError(c, err)
+		return
 	}
+	s.publish(eventUserUpdated, user)
+
+	respond(c, http.StatusOK, user)
+}
 
-	router.GET("/weather", func(c *gin.Context) {
-		city := c.DefaultQuery("city", "110101")
+// parseIfMatchVersion parses an If-Match header holding a user version,
+// quoted or not. An empty header yields 0, meaning no precondition.
+func parseIfMatchVersion(header string) (int, error) {
+	if header == "" {
+		return 0, nil
+	}
+	version, err := strconv.Atoi(strings.Trim(strings.TrimSpace(header), `"`))
+	if err != nil || version < 1 {
+		return 0, fmt.Errorf("invalid If-Match version %q", header)
+	}
+	return version, nil
+}
 
-		weatherURL := "https://restapi.amap.com/v3/weather/weatherInfo?city=" + city + "&key=" + weatherAPIKey
-		resp, err := http.Get(weatherURL)
+// newServer returns an http.Server for addr serving the router with the
+// configured timeouts and TLS settings
+func (s *HTTPServer) newServer(addr string) (*http.Server, error) {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           s.router,
+		ReadTimeout:       s.config().ReadTimeout,
+		ReadHeaderTimeout: s.config().ReadHeaderTimeout,
+		WriteTimeout:      s.config().WriteTimeout,
+		IdleTimeout:       s.config().IdleTimeout,
+	}
+	if s.config().TLS.Enabled() {
+		tlsConfig, err := s.config().TLS.build()
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch weather data"})
-			return
+			return nil, err
 		}
-		defer resp.Body.Close()
+		srv.TLSConfig = tlsConfig
+	}
+	return srv, nil
+}
 
-		var result map[string]interface{}
-		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse response"})
-			return
+// Start binds addr and begins serving in the background. Bind failures,
+// such as the address being in use, are returned directly; errors that
+// stop the listener later are reported by WaitForShutdown. Use Run to
+// serve until an interrupt signal arrives.
+//
+// An addr of the form unix:/path/to/socket, or any addr when ListenNetwork
+// is "unix", listens on a Unix domain socket. A stale socket file left at
+// the path is replaced, and the socket file is removed on Stop.
+func (s *HTTPServer) Start(addr string) error {
+	return s.StartAll(addr)
+}
+
+// StartAll is like Start but serves the router on every address at once,
+// with one http.Server per address. All addresses are bound before any of
+// them starts serving; if one fails to bind, the others are closed again
+// and the error is returned.
+func (s *HTTPServer) StartAll(addrs ...string) error {
+	if len(addrs) == 0 {
+		return errors.New("no listen address")
+	}
+
+	servers := make([]*http.Server, 0, len(addrs))
+	listeners := make([]net.Listener, 0, len(addrs))
+	closeAll := func() {
+		for _, ln := range listeners {
+			ln.Close()
+		}
+	}
+	for _, addr := range addrs {
+		srv, err := s.newServer(addr)
+		if err != nil {
+			closeAll()
+			return err
 		}
+		ln, err := s.listen(addr)
+		if err != nil {
+			closeAll()
+			return err
+		}
+		servers = append(servers, srv)
+		listeners = append(listeners, ln)
+	}
+	s.servers = servers
+	s.listeners = listeners
 
-		c.JSON(http.StatusOK, result)
-	})
+	for i, srv := range servers {
+		go s.serve(srv, listeners[i])
+	}
+	s.started.Store(true)
+	return nil
+}
 
-	return &HTTPServer{
-		router: router,
-		logger: logger,
+// serve runs srv on ln until it is shut down. The first error that stops
+// any of the servers is reported to WaitForShutdown.
+func (s *HTTPServer) serve(srv *http.Server, ln net.Listener) {
+	var err error
+	if s.config().TLS.Enabled() {
+		s.logger.Info("Server is running on " + ln.Addr().String() + " with TLS")
+		err = srv.ServeTLS(ln, s.config().TLS.CertFile, s.config().TLS.KeyFile)
+	} else {
+		s.logger.Info("Server is running on " + ln.Addr().String())
+		err = srv.Serve(ln)
+	}
+	if err != nil && err != http.ErrServerClosed {
+		s.logger.Error("server stopped", zap.Error(err), zap.Stringer("addr", ln.Addr()))
+		select {
+		case s.serveErrs <- err:
+		default:
+		}
 	}
 }
 
-func (s *HTTPServer) Start(addr string) error {
-	// Create server instance
-	srv := &http.Server{
-		Addr:    addr,
-		Handler: s.router,
+// listen binds addr on the network selected by its unix: prefix or
+// ListenNetwork
+func (s *HTTPServer) listen(addr string) (net.Listener, error) {
+	network := s.config().ListenNetwork
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		network, addr = "unix", path
+	}
+	if network != "unix" {
+		return net.Listen(network, addr)
 	}
-	s.server = srv
 
-	go func() {
-		s.logger.Info("Server is running on " + addr)
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			s.logger.Fatal("failed to start server", zap.Error(err))
+	if info, err := os.Stat(addr); err == nil && info.Mode()&os.ModeSocket != 0 {
+		if err := os.Remove(addr); err != nil {
+			return nil, err
 		}
-	}()
+	}
+	// Closing a listener created by net.Listen also removes the socket file
+	return net.Listen("unix", addr)
+}
 
-	// Wait for interrupt signal
+// Addr returns the first address the server is listening on, or nil
+// before Start
+func (s *HTTPServer) Addr() net.Addr {
+	if len(s.listeners) == 0 {
+		return nil
+	}
+	return s.listeners[0].Addr()
+}
+
+// Addrs returns every address the server is listening on, in the order
+// they were passed to StartAll
+func (s *HTTPServer) Addrs() []net.Addr {
+	addrs := make([]net.Addr, 0, len(s.listeners))
+	for _, ln := range s.listeners {
+		addrs = append(addrs, ln.Addr())
+	}
+	return addrs
+}
+
+// WaitForShutdown blocks until SIGINT or SIGTERM is received, returning
+// nil, or until a listener started by Start fails, returning its error.
+// SIGHUP reloads the configuration and keeps waiting. It doesn't stop the
+// server; call Stop afterwards.
+func (s *HTTPServer) WaitForShutdown() error {
+	return s.WaitForShutdownContext(context.Background())
+}
+
+// WaitForShutdownContext is like WaitForShutdown but also returns nil once
+// ctx is done
+func (s *HTTPServer) WaitForShutdownContext(ctx context.Context) error {
 	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(quit)
+
+	for {
+		select {
+		case sig := <-quit:
+			if sig == syscall.SIGHUP {
+				s.logger.Info("Reloading configuration...")
+				if err := s.Reload(); err != nil {
+					s.logger.Error("failed to reload configuration", zap.Error(err))
+				}
+				continue
+			}
+			s.logger.Info("Shutting down server...")
+			return nil
+		case <-ctx.Done():
+			s.logger.Info("Shutting down server...")
+			return nil
+		case err := <-s.serveErrs:
+			return err
+		}
+	}
+}
 
-	s.logger.Info("Shutting down server...")
-	return nil
+// Run serves on every addr until an interrupt signal arrives or a listener
+// fails, then shuts the server down
+func (s *HTTPServer) Run(addrs ...string) error {
+	return s.RunContext(context.Background(), addrs...)
+}
+
+// RunContext is like Run but also shuts the server down once ctx is done.
+// It returns only after Stop has finished.
+func (s *HTTPServer) RunContext(ctx context.Context, addrs ...string) error {
+	if err := s.StartAll(addrs...); err != nil {
+		return err
+	}
+	waitErr := s.WaitForShutdownContext(ctx)
+	if err := s.Stop(); err != nil && waitErr == nil {
+		return err
+	}
+	return waitErr
 }
 
+// Stop shuts every server down in parallel, sharing the ShutdownTimeout,
+// and returns the errors of all that failed. /readyz fails from the moment
+// Stop is called; with a ShutdownDrainDelay the servers keep serving for
+// that long first.
 func (s *HTTPServer) Stop() error {
-	if s.server == nil {
-		return nil
+	s.draining.Store(true)
+	s.idempotency.Close()
+	s.rateLimiter.Close()
+	s.stubs.Close()
+	if len(s.servers) == 0 {
+		return s.closeStore()
+	}
+
+	cfg := s.config()
+	if cfg.ShutdownDrainDelay > 0 {
+		s.logger.Info("draining before shutdown", zap.Duration("delay", cfg.ShutdownDrainDelay))
+		time.Sleep(cfg.ShutdownDrainDelay)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
 	defer cancel()
 	defer s.logger.Sync()
 
-	if err := s.server.Shutdown(ctx); err != nil {
-		s.logger.Error("failed to shutdown server", zap.Error(err))
+	errs := make([]error, len(s.servers))
+	var wg sync.WaitGroup
+	for i, srv := range s.servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = srv.Shutdown(ctx)
+		}()
+	}
+	wg.Wait()
+	errs = append(errs, s.closeStore())
+	if err := s.tracing.shutdown(ctx); err != nil {
+		s.logger.Warn("failed to flush traces", zap.Error(err))
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		s.logger.Error("failed to shutdown server", zap.Error(err),
+			zap.Int64("inFlightRequests", s.inFlight.Load()))
 		return err
 	}
 
@@ -0,0 +1,875 @@
+package backend
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func newTestServer(t *testing.T) *HTTPServer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	t.Setenv("WEATHER_API_KEY", "test")
+	return NewHTTPServer()
+}
+
+func doRequest(s *HTTPServer, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+// stepClock is a Clock that only moves when advanced
+type stepClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *stepClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *stepClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// decodeUser decodes the user in the body of w
+func decodeUser(t *testing.T, w *httptest.ResponseRecorder) User {
+	t.Helper()
+	var user User
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &user))
+	return user
+}
+
+func TestCreateUserConcurrentDuplicate(t *testing.T) {
+	s := newTestServer(t)
+
+	const workers = 20
+	codes := make([]int, workers)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			w := doRequest(s, http.MethodPost, "/users", `{"username":"alice","email":"alice@example.com"}`)
+			codes[i] = w.Code
+		}(i)
+	}
+	wg.Wait()
+
+	var created, conflicts int
+	for _, code := range codes {
+		switch code {
+		case http.StatusCreated:
+			created++
+		case http.StatusConflict:
+			conflicts++
+		}
+	}
+	assert.Equal(t, 1, created)
+	assert.Equal(t, workers-1, conflicts)
+}
+
+func TestPatchUserKeepsAbsentFields(t *testing.T) {
+	s := newTestServer(t)
+	doRequest(s, http.MethodPost, "/users", `{"username":"alice","email":"alice@example.com"}`)
+	doRequest(s, http.MethodPut, "/users/alice@example.com/preferences", `{"theme":"dark","tags":["beta"]}`)
+
+	w := doRequest(s, http.MethodPatch, "/users/alice@example.com", `{"preferences":{"isPublic":true}}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var user User
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &user))
+	assert.True(t, user.Preferences.IsPublic)
+	assert.Equal(t, "dark", user.Preferences.Theme)
+	assert.Equal(t, []string{"beta"}, user.Preferences.Tags)
+
+	w = doRequest(s, http.MethodPatch, "/users/alice@example.com", `{"preferences":{"isPublik":true}}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestUpdatedAtTracksMutations(t *testing.T) {
+	s := newTestServer(t)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := &stepClock{now: start}
+	s.SetClock(clock)
+
+	w := doRequest(s, http.MethodPost, "/users", `{"username":"alice","email":"alice@example.com"}`)
+	created := decodeUser(t, w)
+	assert.True(t, start.Equal(created.CreatedAt))
+	assert.True(t, start.Equal(created.UpdatedAt))
+
+	clock.Advance(time.Minute)
+	w = doRequest(s, http.MethodGet, "/users/email/alice@example.com", "")
+	assert.True(t, start.Equal(decodeUser(t, w).UpdatedAt), "reads don't touch updatedAt")
+
+	w = doRequest(s, http.MethodPatch, "/users/alice@example.com", `{"preferences":{"isPublic":true}}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	patched := decodeUser(t, w)
+	assert.True(t, start.Add(time.Minute).Equal(patched.UpdatedAt))
+	assert.True(t, start.Equal(patched.CreatedAt))
+
+	clock.Advance(time.Minute)
+	w = doRequest(s, http.MethodPut, "/users/alice@example.com/preferences", `{"theme":"dark"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, start.Add(2*time.Minute).Equal(decodeUser(t, w).UpdatedAt))
+
+	clock.Advance(time.Minute)
+	w = doRequest(s, http.MethodGet, "/users/email/alice@example.com", "")
+	assert.True(t, start.Add(2*time.Minute).Equal(decodeUser(t, w).UpdatedAt))
+}
+
+func TestUpdatePreferencesIfMatch(t *testing.T) {
+	s := newTestServer(t)
+	doRequest(s, http.MethodPost, "/users", `{"username":"alice","email":"alice@example.com"}`)
+	put := func(ifMatch, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/users/alice@example.com/preferences", strings.NewReader(body))
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := put("1", `{"theme":"dark"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 2, decodeUser(t, w).Version)
+
+	w = put("1", `{"theme":"light"}`)
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+	var body APIError
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, codeVersionMismatch, body.Code)
+	w = doRequest(s, http.MethodGet, "/users/email/alice@example.com", "")
+	assert.Equal(t, "dark", decodeUser(t, w).Preferences.Theme, "a stale version changes nothing")
+
+	w = put(`"2"`, `{"theme":"light"}`)
+	assert.Equal(t, http.StatusOK, w.Code, "quoted versions match too")
+	assert.Equal(t, 3, decodeUser(t, w).Version)
+
+	w = put("", `{"theme":"dark"}`)
+	assert.Equal(t, http.StatusOK, w.Code, "without If-Match the update is unconditional")
+	assert.Equal(t, 4, decodeUser(t, w).Version)
+
+	w = put("latest", `{"theme":"dark"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestEmailLookupIsCaseInsensitive(t *testing.T) {
+	s := newTestServer(t)
+
+	w := doRequest(s, http.MethodPost, "/users", `{"username":"alice","email":" Alice@Example.com "}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Contains(t, w.Body.String(), `"email":"alice@example.com"`)
+
+	for _, email := range []string{"alice@example.com", "ALICE@EXAMPLE.COM", "aLiCe@eXaMpLe.CoM"} {
+		w := doRequest(s, http.MethodGet, "/users/email/"+email, "")
+		assert.Equal(t, http.StatusOK, w.Code, email)
+	}
+
+	w = doRequest(s, http.MethodPost, "/users", `{"username":"alice2","email":"ALICE@example.com"}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestGetUserByID(t *testing.T) {
+	s := newTestServer(t)
+
+	w := doRequest(s, http.MethodPost, "/users", `{"username":"alice","email":"alice@example.com"}`)
+	var created User
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+
+	w = doRequest(s, http.MethodGet, "/users/id/"+created.ID, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"email":"alice@example.com"`)
+
+	doRequest(s, http.MethodDelete, "/users/alice@example.com", "")
+	w = doRequest(s, http.MethodGet, "/users/id/"+created.ID, "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestListUsers(t *testing.T) {
+	s := newTestServer(t)
+	// Stored newest first so the listing has to sort by creation time
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 119; i >= 0; i-- {
+		assert.NoError(t, s.users.Put(context.Background(), &User{
+			ID:        fmt.Sprintf("id-%03d", 119-i),
+			Username:  fmt.Sprintf("user%03d", i),
+			Email:     fmt.Sprintf("user%03d@example.com", i),
+			CreatedAt: created.Add(time.Duration(i) * time.Minute),
+		}))
+	}
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantFirst  string
+		wantLen    int
+	}{
+		{"defaults", "", http.StatusOK, "user000", defaultPageLimit},
+		{"limit and offset", "?limit=5&offset=10", http.StatusOK, "user010", 5},
+		{"limit capped", "?limit=500", http.StatusOK, "user000", maxPageLimit},
+		{"last page", "?offset=115", http.StatusOK, "user115", 5},
+		{"offset past the end", "?offset=200", http.StatusOK, "", 0},
+		{"negative limit", "?limit=-1", http.StatusBadRequest, "", 0},
+		{"zero limit", "?limit=0", http.StatusBadRequest, "", 0},
+		{"negative offset", "?offset=-1", http.StatusBadRequest, "", 0},
+		{"non-numeric limit", "?limit=ten", http.StatusBadRequest, "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := doRequest(s, http.MethodGet, "/users"+tt.query, "")
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			assert.Equal(t, "120", w.Header().Get("X-Total-Count"))
+			var users []User
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &users))
+			if !assert.Len(t, users, tt.wantLen) || tt.wantLen == 0 {
+				return
+			}
+			assert.Equal(t, tt.wantFirst, users[0].Username)
+			for i := 1; i < len(users); i++ {
+				assert.True(t, users[i-1].CreatedAt.Before(users[i].CreatedAt), "ordered by createdAt")
+			}
+		})
+	}
+}
+
+func TestCountUsers(t *testing.T) {
+	s := newTestServer(t)
+	for _, name := range []string{"alice", "bob", "carol"} {
+		w := doRequest(s, http.MethodPost, "/users", `{"username":"`+name+`","email":"`+name+`@example.com"}`)
+		assert.Equal(t, http.StatusCreated, w.Code)
+	}
+
+	w := doRequest(s, http.MethodGet, "/users/count", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"count":3,"deleted":0}`, w.Body.String())
+}
+
+func TestSearchUsers(t *testing.T) {
+	s := newTestServer(t)
+	for _, name := range []string{"alice", "Alicia", "bob"} {
+		doRequest(s, http.MethodPost, "/users", `{"username":"`+name+`","email":"`+name+`@example.com"}`)
+	}
+
+	w := doRequest(s, http.MethodGet, "/users/search?q=ALI", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var users []User
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &users))
+	assert.Len(t, users, 2)
+
+	w = doRequest(s, http.MethodGet, "/users/search?q=ali&limit=1", "")
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &users))
+	assert.Len(t, users, 1)
+
+	w = doRequest(s, http.MethodGet, "/users/search", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestGetUserETag(t *testing.T) {
+	s := newTestServer(t)
+	doRequest(s, http.MethodPost, "/users", `{"username":"alice","email":"alice@example.com"}`)
+
+	w := doRequest(s, http.MethodGet, "/users/email/alice@example.com", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	etag := w.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	req := httptest.NewRequest(http.MethodGet, "/users/email/alice@example.com", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.String())
+
+	doRequest(s, http.MethodPatch, "/users/alice@example.com", `{"preferences":{"theme":"dark"}}`)
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEqual(t, etag, w.Header().Get("ETag"))
+}
+
+func TestBulkCreateUsers(t *testing.T) {
+	s := newTestServer(t)
+	doRequest(s, http.MethodPost, "/users", `{"username":"carol","email":"carol@example.com"}`)
+
+	w := doRequest(s, http.MethodPost, "/users/bulk", `[
+		{"username":"alice","email":"alice@example.com"},
+		{"username":"alice2","email":"alice@example.com"},
+		{"username":"bad","email":"not-an-email"},
+		{"username":"carol","email":"carol@example.com"}
+	]`)
+	assert.Equal(t, http.StatusMultiStatus, w.Code)
+
+	var results []bulkCreateResult
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &results))
+	assert.Len(t, results, 4)
+	assert.Equal(t, http.StatusCreated, results[0].Status)
+	assert.Equal(t, http.StatusConflict, results[1].Status)
+	assert.Equal(t, http.StatusBadRequest, results[2].Status)
+	assert.Equal(t, http.StatusConflict, results[3].Status)
+}
+
+func TestRejectUnknownFields(t *testing.T) {
+	s := newTestServer(t)
+
+	w := doRequest(s, http.MethodPost, "/users", `{"usrname":"alice","email":"alice@example.com"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "usrname")
+
+	doRequest(s, http.MethodPost, "/users", `{"username":"alice","email":"alice@example.com"}`)
+	w = doRequest(s, http.MethodPut, "/users/alice@example.com/preferences", `{"theme":"dark","colour":"red"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "colour")
+}
+
+func TestDeleteUser(t *testing.T) {
+	s := newTestServer(t)
+	for _, name := range []string{"alice", "bobby"} {
+		w := doRequest(s, http.MethodPost, "/users", `{"username":"`+name+`","email":"`+name+`@example.com"}`)
+		assert.Equal(t, http.StatusCreated, w.Code)
+	}
+
+	w := doRequest(s, http.MethodDelete, "/users/alice@example.com", "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Empty(t, w.Body.String())
+	assert.Equal(t, http.StatusNotFound, doRequest(s, http.MethodGet, "/users/email/alice@example.com", "").Code)
+
+	w = doRequest(s, http.MethodDelete, "/users/alice@example.com", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.JSONEq(t, `{"code":"not_found","error":"user not found"}`, w.Body.String())
+
+	w = doRequest(s, http.MethodDelete, "/users/bobby@example.com?return=true", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var deleted User
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &deleted))
+	assert.Equal(t, "bobby", deleted.Username)
+	assert.Equal(t, "bobby@example.com", deleted.Email)
+	assert.Equal(t, http.StatusNotFound, doRequest(s, http.MethodGet, "/users/email/bobby@example.com", "").Code)
+}
+
+func TestSoftDeleteAndRestore(t *testing.T) {
+	s := newTestServer(t)
+	doRequest(s, http.MethodPost, "/users", `{"username":"alice","email":"alice@example.com"}`)
+
+	w := doRequest(s, http.MethodDelete, "/users/alice@example.com?soft=true", "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	w = doRequest(s, http.MethodGet, "/users/email/alice@example.com", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = doRequest(s, http.MethodGet, "/users/email/alice@example.com?includeDeleted=true", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"deletedAt"`)
+	w = doRequest(s, http.MethodGet, "/users", "")
+	assert.JSONEq(t, `[]`, w.Body.String())
+	w = doRequest(s, http.MethodGet, "/users/count", "")
+	assert.JSONEq(t, `{"count":0,"deleted":1}`, w.Body.String())
+
+	w = doRequest(s, http.MethodPost, "/users/alice@example.com/restore", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), `"deletedAt"`)
+	w = doRequest(s, http.MethodGet, "/users/email/alice@example.com", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestCreateUserIdempotencyKey(t *testing.T) {
+	s := newTestServer(t)
+	post := func(key, body string, accept ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(body))
+		req.Header.Set("Idempotency-Key", key)
+		if len(accept) > 0 {
+			req.Header.Set("Accept", accept[0])
+		}
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+
+	body := `{"username":"alice","email":"alice@example.com"}`
+	first := post("key-1", body)
+	assert.Equal(t, http.StatusCreated, first.Code)
+
+	retry := post("key-1", body)
+	assert.Equal(t, http.StatusCreated, retry.Code)
+	assert.Equal(t, first.Body.String(), retry.Body.String())
+	assert.Equal(t, "true", retry.Header().Get("Idempotent-Replayed"))
+
+	xmlRetry := post("key-1", body, "application/xml")
+	assert.Equal(t, http.StatusCreated, xmlRetry.Code)
+	assert.Equal(t, "application/xml; charset=utf-8", xmlRetry.Header().Get("Content-Type"))
+	assert.Contains(t, xmlRetry.Body.String(), "<email>alice@example.com</email>")
+
+	w := post("key-1", `{"username":"bob","email":"bob@example.com"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	w = post("key-2", body)
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestDeterministicMode(t *testing.T) {
+	t.Setenv("MOCK_DETERMINISTIC", "1")
+	s := newTestServer(t)
+
+	w := doRequest(s, http.MethodPost, "/users", `{"username":"alice","email":"alice@example.com"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.JSONEq(t, `{
+		"id": "00000000-0000-0000-0000-000000000001",
+		"username": "alice",
+		"email": "alice@example.com",
+		"createdAt": "2024-01-01T00:00:00Z",
+		"updatedAt": "2024-01-01T00:00:00Z",
+		"version": 1,
+		"preferences": {
+			"isPublic": false,
+			"showEmail": true,
+			"theme": "light",
+			"tags": [],
+			"settings": {},
+			"notifications": []
+		}
+	}`, w.Body.String())
+}
+
+func TestCreateUserFieldErrors(t *testing.T) {
+	s := newTestServer(t)
+
+	w := doRequest(s, http.MethodPost, "/users", `{"username":"a","email":"not-an-email"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var resp APIError
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "validation failed", resp.Error)
+	assert.Contains(t, resp.Details, "email")
+	assert.Contains(t, resp.Details, "username")
+
+	w = doRequest(s, http.MethodPost, "/users", `{"username":42,"email":"alice@example.com"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	resp = APIError{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, ErrorDetails{"username": "must be of type string"}, resp.Details)
+}
+
+func TestErrorEnvelope(t *testing.T) {
+	s := newTestServer(t)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+		want   APIError
+	}{
+		{"unknown user", http.MethodGet, "/users/email/nobody@example.com", "", http.StatusNotFound,
+			APIError{Error: "user not found", Code: codeNotFound}},
+		{"unknown route", http.MethodGet, "/v1/nothing", "", http.StatusNotFound,
+			APIError{Error: "no route for GET /v1/nothing", Code: codeNotFound}},
+		{"invalid query", http.MethodGet, "/users/search?q=a&limit=many", "", http.StatusBadRequest,
+			APIError{Error: `invalid limit "many"`, Code: codeInvalidRequest}},
+		{"invalid body", http.MethodPost, "/users", `{"username":"alice"}`, http.StatusBadRequest,
+			APIError{Error: "validation failed", Code: codeValidationFailed, Details: map[string]string{"email": "email is required"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := doRequest(s, tt.method, tt.path, tt.body)
+			assert.Equal(t, tt.status, w.Code)
+			assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+			var got APIError
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCreateUserUsesConfiguredDefaults(t *testing.T) {
+	t.Setenv("DEFAULT_THEME", "dark")
+	t.Setenv("DEFAULT_TAGS", "beta, internal")
+	s := newTestServer(t)
+
+	w := doRequest(s, http.MethodPost, "/users", `{"username":"alice","email":"alice@example.com"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var user User
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &user))
+	assert.Equal(t, "dark", user.Preferences.Theme)
+	assert.Equal(t, []string{"beta", "internal"}, user.Preferences.Tags)
+	assert.True(t, user.Preferences.ShowEmail)
+}
+
+func TestCreateUserSeedsDefaultNotifications(t *testing.T) {
+	t.Setenv("DEFAULT_NOTIFICATIONS", "email:security, push:system")
+	s := newTestServer(t)
+
+	w := doRequest(s, http.MethodPost, "/users", `{"username":"alice","email":"alice@example.com"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var user User
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &user))
+	assert.Equal(t, []Notification{
+		{Type: "email", Channel: "security", Enabled: true, Frequency: FrequencyRealtime},
+		{Type: "push", Channel: "system", Enabled: true, Frequency: FrequencyRealtime},
+	}, user.Preferences.Notifications)
+
+	w = doRequest(s, http.MethodPost, "/users",
+		`{"username":"bob","email":"bob@example.com","preferences":{"notifications":[{"type":"sms","channel":"marketing","frequency":1}]}}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	user = User{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &user))
+	assert.Equal(t, []Notification{
+		{Type: "sms", Channel: "marketing", Frequency: FrequencyDaily},
+	}, user.Preferences.Notifications)
+}
+
+func TestResetPreferences(t *testing.T) {
+	t.Setenv("DEFAULT_THEME", "dark")
+	t.Setenv("DEFAULT_TAGS", "beta")
+	t.Setenv("DEFAULT_NOTIFICATIONS", "email:security")
+	s := newTestServer(t)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := &stepClock{now: start}
+	s.SetClock(clock)
+
+	doRequest(s, http.MethodPost, "/users", `{"username":"alice","email":"alice@example.com"}`)
+	w := doRequest(s, http.MethodPut, "/users/alice@example.com/preferences",
+		`{"isPublic":true,"theme":"light","tags":["x"],"settings":{"lang":"en"},"notifications":[{"type":"sms","channel":"marketing"}]}`)
+	assert.Equal(t, 2, decodeUser(t, w).Version)
+
+	clock.Advance(time.Minute)
+	w = doRequest(s, http.MethodPost, "/users/alice@example.com/preferences/reset", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	reset := decodeUser(t, w)
+	assert.Equal(t, Preferences{
+		ShowEmail:     true,
+		Theme:         "dark",
+		Tags:          []string{"beta"},
+		Settings:      Settings{},
+		Notifications: []Notification{{Type: "email", Channel: "security", Enabled: true}},
+	}, reset.Preferences)
+	assert.Equal(t, 3, reset.Version)
+	assert.True(t, start.Add(time.Minute).Equal(reset.UpdatedAt))
+
+	// The reset user doesn't share the defaults
+	doRequest(s, http.MethodPut, "/users/alice@example.com/notifications", `[{"type":"push","channel":"system"}]`)
+	assert.Equal(t, []Notification{{Type: "email", Channel: "security", Enabled: true}}, s.config().DefaultPreferences.Notifications)
+
+	w = doRequest(s, http.MethodPost, "/users/bobby@example.com/preferences/reset", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestUpdatePreferencesRejectsUnknownNotificationType(t *testing.T) {
+	s := newTestServer(t)
+	doRequest(s, http.MethodPost, "/users", `{"username":"alice","email":"alice@example.com"}`)
+
+	w := doRequest(s, http.MethodPut, "/users/alice@example.com/preferences",
+		`{"notifications":[{"type":"email","channel":"system"},{"type":"emial","channel":"system"}]}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"notifications[1].type"`)
+	assert.Contains(t, w.Body.String(), `emial`)
+}
+
+func TestPreferencesTheme(t *testing.T) {
+	t.Setenv("THEMES", "light,dark,solarized")
+	s := newTestServer(t)
+
+	w := doRequest(s, http.MethodPost, "/users",
+		`{"username":"alice","email":"alice@example.com","preferences":{"theme":"solarized"}}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Contains(t, w.Body.String(), `"theme":"solarized"`)
+
+	w = doRequest(s, http.MethodPut, "/users/alice@example.com/preferences", `{"theme":"dark"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = doRequest(s, http.MethodPut, "/users/alice@example.com/preferences", `{"theme":"system"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"theme"`)
+
+	w = doRequest(s, http.MethodPost, "/users",
+		`{"username":"bob","email":"bob@example.com","preferences":{"theme":"neon"}}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"preferences.theme"`)
+}
+
+func TestUpdatePreferencesMerge(t *testing.T) {
+	s := newTestServer(t)
+	doRequest(s, http.MethodPost, "/users", `{"username":"alice","email":"alice@example.com"}`)
+	doRequest(s, http.MethodPut, "/users/alice@example.com/preferences", `{"theme":"light","tags":["beta"]}`)
+
+	w := doRequest(s, http.MethodPut, "/users/alice@example.com/preferences?merge=true", `{"theme":"dark"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var user User
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &user))
+	assert.Equal(t, "dark", user.Preferences.Theme)
+	assert.Equal(t, []string{"beta"}, user.Preferences.Tags)
+
+	w = doRequest(s, http.MethodPut, "/users/alice@example.com/preferences", `{"theme":"light"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	user = User{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &user))
+	assert.Empty(t, user.Preferences.Tags)
+}
+
+func TestSlowClientIsCutOff(t *testing.T) {
+	t.Setenv("READ_TIMEOUT", "100ms")
+	s := newTestServer(t)
+	srv, err := s.newServer("")
+	assert.NoError(t, err)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	go srv.Serve(ln)
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	// Send the headers but only part of the promised body
+	_, err = conn.Write([]byte("POST /users HTTP/1.1\r\nHost: test\r\nContent-Type: application/json\r\n" +
+		"Content-Length: 100\r\n\r\n{\"username\":"))
+	assert.NoError(t, err)
+
+	start := time.Now()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err == nil {
+		resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	}
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestStartReportsBindFailure(t *testing.T) {
+	first := newTestServer(t)
+	assert.NoError(t, first.Start("127.0.0.1:0"))
+	defer first.Stop()
+
+	resp, err := http.Get("http://" + first.Addr().String() + "/users/count")
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	second := newTestServer(t)
+	err = second.Start(first.Addr().String())
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "address already in use")
+}
+
+func TestStartAllServesEveryAddress(t *testing.T) {
+	s := newTestServer(t)
+	assert.NoError(t, s.StartAll("127.0.0.1:0", "127.0.0.1:0"))
+
+	addrs := s.Addrs()
+	assert.Len(t, addrs, 2)
+	for _, addr := range addrs {
+		resp, err := http.Get("http://" + addr.String() + "/users/count")
+		assert.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	busy := newTestServer(t)
+	err := busy.StartAll("127.0.0.1:0", addrs[1].String())
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "address already in use")
+	assert.Empty(t, busy.Addrs())
+
+	assert.NoError(t, s.Stop())
+	_, err = http.Get("http://" + addrs[0].String() + "/users/count")
+	assert.Error(t, err)
+}
+
+func TestWaitForShutdown(t *testing.T) {
+	// Registered first so the repeated SIGTERM never reaches the default
+	// handler, however late WaitForShutdown subscribes
+	quit := make(chan os.Signal, 16)
+	signal.Notify(quit, syscall.SIGTERM)
+	defer signal.Stop(quit)
+
+	s := newTestServer(t)
+	assert.NoError(t, s.Start("127.0.0.1:0"))
+	defer s.Stop()
+	done := make(chan error, 1)
+	go func() { done <- s.WaitForShutdown() }()
+
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case err := <-done:
+			assert.NoError(t, err)
+			resp, err := http.Get("http://" + s.Addr().String() + "/users/count")
+			if assert.NoError(t, err, "WaitForShutdown leaves the server running") {
+				resp.Body.Close()
+			}
+			return
+		case <-ticker.C:
+			assert.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGTERM))
+		case <-timeout:
+			t.Fatal("WaitForShutdown didn't return on SIGTERM")
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer busy.Close()
+	err = newTestServer(t).Run(busy.Addr().String())
+	assert.ErrorContains(t, err, "address already in use", "bind failures return at once")
+
+	s := newTestServer(t)
+	done := make(chan error, 1)
+	go func() { done <- s.Run("127.0.0.1:0") }()
+	for !s.started.Load() {
+		time.Sleep(time.Millisecond)
+	}
+	addr := s.Addr().String()
+	s.listeners[0].Close()
+
+	select {
+	case err := <-done:
+		assert.Error(t, err, "a failed listener ends Run")
+	case <-time.After(2 * time.Second):
+		t.Fatal("Run didn't return after its listener failed")
+	}
+	_, err = http.Get("http://" + addr + "/users/count")
+	assert.Error(t, err, "Run stops the server")
+}
+
+func TestRunContext(t *testing.T) {
+	t.Setenv("SHUTDOWN_DRAIN_DELAY", "50ms")
+	s := newTestServer(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.RunContext(ctx, "127.0.0.1:0") }()
+	for !s.started.Load() {
+		time.Sleep(time.Millisecond)
+	}
+	addr := s.Addr().String()
+
+	start := time.Now()
+	cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+		assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond, "RunContext waits for Stop to finish")
+	case <-time.After(2 * time.Second):
+		t.Fatal("RunContext didn't return after ctx was canceled")
+	}
+	_, err := http.Get("http://" + addr + "/users/count")
+	assert.Error(t, err, "RunContext stops the server")
+}
+
+func TestStopTimeout(t *testing.T) {
+	t.Setenv("SHUTDOWN_TIMEOUT", "50ms")
+	s := newTestServer(t)
+	core, logs := observer.New(zapcore.InfoLevel)
+	s.logger = zap.New(core)
+	release := make(chan struct{})
+	defer close(release)
+	handling := make(chan struct{})
+	s.router.GET("/slow", func(c *gin.Context) {
+		close(handling)
+		<-release
+	})
+	assert.NoError(t, s.Start("127.0.0.1:0"))
+
+	go http.Get("http://" + s.Addr().String() + "/slow")
+	<-handling
+
+	start := time.Now()
+	assert.ErrorIs(t, s.Stop(), context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second, "Stop gives up after SHUTDOWN_TIMEOUT")
+	entries := logs.FilterMessage("failed to shutdown server").All()
+	if assert.Len(t, entries, 1) {
+		assert.EqualValues(t, 1, entries[0].ContextMap()["inFlightRequests"])
+	}
+}
+
+func TestStartOnUnixSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "mock.sock")
+	s := newTestServer(t)
+	assert.NoError(t, s.Start("unix:"+socket))
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		},
+	}}
+	resp, err := client.Get("http://mock/users/count")
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	assert.NoError(t, s.Stop())
+	assert.NoFileExists(t, socket)
+}
+
+func TestReloadAppliesHotSettings(t *testing.T) {
+	t.Setenv("THEMES", "light,dark")
+	t.Setenv("READ_TIMEOUT", "30s")
+	s := newTestServer(t)
+
+	t.Setenv("THEMES", "light,dark,solarized")
+	t.Setenv("WEATHER_PROVIDER", "static")
+	t.Setenv("READ_TIMEOUT", "1s")
+	assert.NoError(t, s.Reload())
+
+	w := doRequest(s, http.MethodPost, "/users",
+		`{"username":"alice","email":"alice@example.com","preferences":{"theme":"solarized"}}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, StaticProvider{}, s.weatherProvider())
+	assert.Equal(t, 30*time.Second, s.config().ReadTimeout)
+
+	t.Setenv("WEATHER_PROVIDER", "unknown")
+	assert.Error(t, s.Reload())
+	assert.Equal(t, StaticProvider{}, s.weatherProvider())
+}
+
+func TestReloadKeepsRealEnvOverDotEnv(t *testing.T) {
+	t.Chdir(t.TempDir())
+	t.Setenv("THEMES", "light,dark")
+	// MAX_TAGS only comes from the .env file; restore it to unset afterwards
+	t.Setenv("MAX_TAGS", "")
+	os.Unsetenv("MAX_TAGS")
+	assert.NoError(t, os.WriteFile(".env", []byte("THEMES=light\nMAX_TAGS=3\n"), 0o600))
+	s := newTestServer(t)
+	assert.Equal(t, []string{"light", "dark"}, s.config().Themes)
+	assert.Equal(t, 3, s.config().MaxTags)
+
+	assert.NoError(t, os.WriteFile(".env", []byte("THEMES=light\nMAX_TAGS=4\n"), 0o600))
+	assert.NoError(t, s.Reload())
+	assert.Equal(t, []string{"light", "dark"}, s.config().Themes, "real environment variables win")
+	assert.Equal(t, 4, s.config().MaxTags, "edits to the .env file apply")
+}
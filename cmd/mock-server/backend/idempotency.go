@@ -0,0 +1,114 @@
+package backend
+
+import (
+	"crypto/sha256"
+	"errors"
+	"sync"
+	"time"
+)
+
+const idempotencyKeyTTL = 10 * time.Minute
+
+var errIdempotencyKeyReused = errors.New("idempotency key already used with a different request body")
+
+// idempotentResponse is a cached response for an Idempotency-Key. The body
+// is kept unencoded so every replay is negotiated like the original
+// response, see respond.
+type idempotentResponse struct {
+	requestHash [sha256.Size]byte
+	status      int
+	body        any
+	expiresAt   time.Time
+}
+
+// idempotencyCache remembers responses by Idempotency-Key so that retried
+// requests get the original response instead of being processed twice.
+// Expired entries are removed by a background sweeper until Close is called.
+type idempotencyCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]*idempotentResponse
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newIdempotencyCache(ttl time.Duration) *idempotencyCache {
+	c := &idempotencyCache{
+		ttl:     ttl,
+		entries: make(map[string]*idempotentResponse),
+		done:    make(chan struct{}),
+	}
+	go c.sweepLoop(min(ttl, time.Minute))
+	return c
+}
+
+// Lookup returns the cached response for key, or nil if there is none. It
+// returns errIdempotencyKeyReused when key was used for a different body.
+func (c *idempotencyCache) Lookup(key string, requestBody []byte) (*idempotentResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return nil, nil
+	}
+	if entry.requestHash != sha256.Sum256(requestBody) {
+		return nil, errIdempotencyKeyReused
+	}
+	return entry, nil
+}
+
+// Store caches the response produced for key and requestBody
+func (c *idempotencyCache) Store(key string, requestBody []byte, status int, body any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[key] = &idempotentResponse{
+		requestHash: sha256.Sum256(requestBody),
+		status:      status,
+		body:        body,
+		expiresAt:   time.Now().Add(c.ttl),
+	}
+}
+
+// Clear drops every cached response and returns how many there were
+func (c *idempotencyCache) Clear() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := len(c.entries)
+	c.entries = make(map[string]*idempotentResponse)
+	return n
+}
+
+// Close stops the background sweeper
+func (c *idempotencyCache) Close() {
+	c.closeOnce.Do(func() { close(c.done) })
+}
+
+func (c *idempotencyCache) sweepLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case now := <-ticker.C:
+			c.sweep(now)
+		}
+	}
+}
+
+// sweep removes the entries that expired before now
+func (c *idempotencyCache) sweep(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, entry := range c.entries {
+		if now.After(entry.expiresAt) {
+			delete(c.entries, key)
+		}
+	}
+}
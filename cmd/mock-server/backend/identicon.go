@@ -0,0 +1,62 @@
+package backend
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+const (
+	identiconGrid  = 5
+	identiconCell  = 40
+	identiconInset = identiconCell / 2
+)
+
+// identiconPNG renders a symmetric identicon for key as a PNG. The pattern
+// and color come from a SHA-256 hash of key, so the same key always yields
+// the same image.
+func identiconPNG(key string) ([]byte, error) {
+	sum := sha256.Sum256([]byte(key))
+	fg := color.NRGBA{R: sum[0], G: sum[1], B: sum[2], A: 0xff}
+	bg := color.NRGBA{R: 0xf0, G: 0xf0, B: 0xf0, A: 0xff}
+
+	size := identiconGrid*identiconCell + 2*identiconInset
+	img := image.NewNRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			img.SetNRGBA(x, y, bg)
+		}
+	}
+
+	// Only the left half plus the middle column is derived from the hash;
+	// the right half mirrors it
+	half := (identiconGrid + 1) / 2
+	for row := 0; row < identiconGrid; row++ {
+		for col := 0; col < half; col++ {
+			if sum[3+row*half+col]%2 == 0 {
+				continue
+			}
+			fillIdenticonCell(img, col, row, fg)
+			fillIdenticonCell(img, identiconGrid-1-col, row, fg)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// fillIdenticonCell paints the grid cell at col, row with c
+func fillIdenticonCell(img *image.NRGBA, col, row int, c color.NRGBA) {
+	x0 := identiconInset + col*identiconCell
+	y0 := identiconInset + row*identiconCell
+	for y := y0; y < y0+identiconCell; y++ {
+		for x := x0; x < x0+identiconCell; x++ {
+			img.SetNRGBA(x, y, c)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	defaultStoreFile         = "mock-server-users.json"
+	defaultStoreFileInterval = 30 * time.Second
+)
+
+// jsonFileStore is a memoryStore that is loaded from a JSON file on startup
+// and written back to it every interval when something changed, and once
+// more on Close. Snapshots replace the file atomically, so a crash leaves
+// either the previous or the new snapshot behind.
+type jsonFileStore struct {
+	*memoryStore
+	path   string
+	logger *zap.Logger
+
+	// dirty is set by every write and cleared by a snapshot
+	dirty atomic.Bool
+	// saveMu serializes snapshots
+	saveMu sync.Mutex
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+var _ Store = (*jsonFileStore)(nil)
+
+// newJSONFileStore loads the users saved in the file at path, if it exists,
+// and snapshots them every interval. An interval of 0 only snapshots on
+// Close.
+func newJSONFileStore(path string, interval time.Duration, logger *zap.Logger) (*jsonFileStore, error) {
+	s := &jsonFileStore{
+		memoryStore: newMemoryStore(),
+		path:        path,
+		logger:      logger.Named("store.file"),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+
+	if interval <= 0 {
+		close(s.done)
+		return s, nil
+	}
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := s.Save(); err != nil {
+					s.logger.Error("failed to snapshot users", zap.String("path", s.path), zap.Error(err))
+				}
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+	return s, nil
+}
+
+// load reads the snapshot at path. A missing file is an empty store.
+func (s *jsonFileStore) load() error {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read store file: %w", err)
+	}
+
+	var users []storedUser
+	if err := json.Unmarshal(data, &users); err != nil {
+		return fmt.Errorf("invalid store file %s: %w", s.path, err)
+	}
+	for _, user := range users {
+		s.memoryStore.Put(context.Background(), user.toUser())
+	}
+	return nil
+}
+
+// Save writes a snapshot of the users to the file if anything changed
+// since the last one. The snapshot goes to a temporary file in the same
+// directory which then replaces the file.
+func (s *jsonFileStore) Save() error {
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+
+	if !s.dirty.Swap(false) {
+		return nil
+	}
+	if err := s.save(); err != nil {
+		s.dirty.Store(true)
+		return err
+	}
+	return nil
+}
+
+func (s *jsonFileStore) save() error {
+	users, _ := s.memoryStore.List(context.Background())
+	stored := make([]storedUser, len(users))
+	for i, user := range users {
+		stored[i] = newStoredUser(user)
+	}
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(s.path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(s.path)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// Close stops the periodic snapshots and writes a final one
+func (s *jsonFileStore) Close() error {
+	select {
+	case <-s.stop:
+	default:
+		close(s.stop)
+	}
+	<-s.done
+	return s.Save()
+}
+
+// Put implements Store.Put
+func (s *jsonFileStore) Put(ctx context.Context, user *User) error {
+	defer s.dirty.Store(true)
+	return s.memoryStore.Put(ctx, user)
+}
+
+// Create implements Store.Create
+func (s *jsonFileStore) Create(ctx context.Context, user *User) error {
+	defer s.dirty.Store(true)
+	return s.memoryStore.Create(ctx, user)
+}
+
+// CreateMany implements Store.CreateMany
+func (s *jsonFileStore) CreateMany(ctx context.Context, users []*User) []error {
+	defer s.dirty.Store(true)
+	return s.memoryStore.CreateMany(ctx, users)
+}
+
+// Update implements Store.Update
+func (s *jsonFileStore) Update(ctx context.Context, email string, fn func(*User) error) (*User, error) {
+	defer s.dirty.Store(true)
+	return s.memoryStore.Update(ctx, email, fn)
+}
+
+// Replace implements Store.Replace
+func (s *jsonFileStore) Replace(ctx context.Context, users []*User) ([]*User, error) {
+	defer s.dirty.Store(true)
+	return s.memoryStore.Replace(ctx, users)
+}
+
+// Delete implements Store.Delete
+func (s *jsonFileStore) Delete(ctx context.Context, email string) (*User, error) {
+	defer s.dirty.Store(true)
+	return s.memoryStore.Delete(ctx, email)
+}
@@ -0,0 +1,63 @@
+package backend
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestJSONFileStoreSurvivesRestart(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "users.json")
+
+	store, err := newJSONFileStore(path, 0, zap.NewNop())
+	assert.NoError(t, err)
+	alice := &User{ID: "1", Username: "alice", Email: "alice@example.com", AvatarPath: "/tmp/1.png",
+		Preferences: Preferences{Theme: "dark", Tags: []string{"beta"}}}
+	assert.NoError(t, store.Create(ctx, alice))
+	assert.NoError(t, store.Create(ctx, &User{ID: "2", Username: "bob", Email: "bob@example.com"}))
+	_, err = store.Delete(ctx, "bob@example.com")
+	assert.NoError(t, err)
+	_, err = os.Stat(path)
+	assert.ErrorIs(t, err, os.ErrNotExist, "no snapshot before Close without an interval")
+	assert.NoError(t, store.Close())
+
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1, "temporary files are cleaned up")
+
+	store, err = newJSONFileStore(path, 0, zap.NewNop())
+	assert.NoError(t, err)
+	got, err := store.Get(ctx, "alice@example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, alice, got)
+	_, err = store.Get(ctx, "bob@example.com")
+	assert.ErrorIs(t, err, errUserNotFound)
+	assert.NoError(t, store.Close())
+}
+
+func TestJSONFileStoreSnapshotsPeriodically(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "users.json")
+	store, err := newJSONFileStore(path, 10*time.Millisecond, zap.NewNop())
+	assert.NoError(t, err)
+	defer store.Close()
+
+	assert.NoError(t, store.Create(context.Background(), &User{ID: "1", Email: "alice@example.com"}))
+	assert.Eventually(t, func() bool {
+		data, err := os.ReadFile(path)
+		return err == nil && len(data) > 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestJSONFileStoreInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.json")
+	assert.NoError(t, os.WriteFile(path, []byte("{"), 0o600))
+	_, err := newJSONFileStore(path, 0, zap.NewNop())
+	assert.ErrorContains(t, err, "invalid store file")
+}
@@ -0,0 +1,266 @@
+package backend
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// jwtClaimsKey is the context key holding the jwt.MapClaims of the
+	// validated token
+	jwtClaimsKey = "jwtClaims"
+
+	defaultJWKSRefresh = 10 * time.Minute
+	// jwksMinRefetch limits refetches triggered by unknown key IDs
+	jwksMinRefetch = 10 * time.Second
+)
+
+var errJWKSKeyNotFound = errors.New("signing key not found in JWKS")
+
+// JWTConfig holds the bearer token validation settings. Validation is
+// enabled when Secret or JWKSURL is set.
+type JWTConfig struct {
+	// Secret verifies HS256, HS384 and HS512 tokens
+	Secret string
+	// JWKSURL is fetched for the public keys verifying RS*, PS* and ES*
+	// tokens, matched by their kid header
+	JWKSURL string
+	// JWKSRefresh is how often the JWKS is fetched again
+	JWKSRefresh time.Duration
+	// Audience, when set, must be among the token's aud claim
+	Audience string
+	// Issuer, when set, must equal the token's iss claim
+	Issuer string
+	// ExemptPaths never require a token, e.g. health checks
+	ExemptPaths []string
+}
+
+// Enabled reports whether requests must carry a bearer token
+func (c JWTConfig) Enabled() bool {
+	return c.Secret != "" || c.JWKSURL != ""
+}
+
+// validMethods returns the signing algorithms accepted with the
+// configured keys
+func (c JWTConfig) validMethods() []string {
+	var methods []string
+	if c.Secret != "" {
+		methods = append(methods, "HS256", "HS384", "HS512")
+	}
+	if c.JWKSURL != "" {
+		methods = append(methods, "RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512")
+	}
+	return methods
+}
+
+// jwtMiddleware requires a valid bearer JWT on every request except
+// preflights and exempt paths, answering 401 when it is missing, expired,
+// not yet valid, badly signed or issued for another audience or issuer.
+// The claims of a valid token are stored in the context.
+func (s *HTTPServer) jwtMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg := s.config().JWT
+		if !cfg.Enabled() || c.Request.Method == http.MethodOptions || slices.Contains(cfg.ExemptPaths, c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+
+		scheme, token, _ := strings.Cut(c.GetHeader("Authorization"), " ")
+		if !strings.EqualFold(scheme, "Bearer") || token == "" {
+			c.Header("WWW-Authenticate", `Bearer realm="mock-server"`)
+			respondError(c, http.StatusUnauthorized, codeUnauthorized, "missing bearer token")
+			return
+		}
+
+		claims, err := s.parseJWT(c.Request.Context(), cfg, token)
+		if err != nil {
+			c.Header("WWW-Authenticate", `Bearer realm="mock-server", error="invalid_token"`)
+			respondError(c, http.StatusUnauthorized, codeUnauthorized, "invalid token: "+err.Error())
+			return
+		}
+		c.Set(jwtClaimsKey, claims)
+		c.Next()
+	}
+}
+
+// parseJWT verifies token against cfg and returns its claims
+func (s *HTTPServer) parseJWT(ctx context.Context, cfg JWTConfig, token string) (jwt.MapClaims, error) {
+	opts := []jwt.ParserOption{jwt.WithValidMethods(cfg.validMethods())}
+	if cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.Audience))
+	}
+	if cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.Issuer))
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (any, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); ok {
+			return []byte(cfg.Secret), nil
+		}
+		kid, _ := t.Header["kid"].(string)
+		return s.jwks.Key(ctx, cfg.JWKSURL, kid, cfg.JWKSRefresh)
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// jwksCache holds the public keys fetched from a JWKS URL. Keys are
+// fetched again once the refresh interval has passed, or earlier when a
+// token names an unknown key ID, at most every jwksMinRefetch.
+type jwksCache struct {
+	client *http.Client
+
+	mu        sync.Mutex
+	url       string
+	keys      map[string]any
+	fetchedAt time.Time
+}
+
+func newJWKSCache() *jwksCache {
+	return &jwksCache{client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Key returns the public key with the given ID from the JWKS at url. An
+// empty kid matches a JWKS holding a single key.
+func (c *jwksCache) Key(ctx context.Context, url, kid string, refresh time.Duration) (any, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if refresh <= 0 {
+		refresh = defaultJWKSRefresh
+	}
+	stale := c.url != url || time.Since(c.fetchedAt) > refresh
+	_, known := c.lookup(kid)
+	if stale || (!known && time.Since(c.fetchedAt) > jwksMinRefetch) {
+		keys, err := c.fetch(ctx, url)
+		if err != nil {
+			return nil, err
+		}
+		c.url, c.keys, c.fetchedAt = url, keys, time.Now()
+	}
+
+	key, ok := c.lookup(kid)
+	if !ok {
+		return nil, errJWKSKeyNotFound
+	}
+	return key, nil
+}
+
+// lookup returns the cached key with the given ID. The caller must hold
+// the lock.
+func (c *jwksCache) lookup(kid string) (any, bool) {
+	if kid == "" && len(c.keys) == 1 {
+		for _, key := range c.keys {
+			return key, true
+		}
+	}
+	key, ok := c.keys[kid]
+	return key, ok
+}
+
+// jsonWebKey is the subset of RFC 7517 fields used for RSA and EC keys
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// fetch downloads the JWKS at url and returns its signing keys by ID. Keys
+// of unsupported types are skipped.
+func (c *jwksCache) fetch(ctx context.Context, url string) (map[string]any, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch JWKS: unexpected status %s", resp.Status)
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("decode JWKS: %w", err)
+	}
+
+	keys := make(map[string]any, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		if key, err := jwk.publicKey(); err == nil {
+			keys[jwk.Kid] = key
+		}
+	}
+	return keys, nil
+}
+
+// publicKey converts the JWK into an *rsa.PublicKey or *ecdsa.PublicKey
+func (k jsonWebKey) publicKey() (any, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeJWKInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeJWKInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		curves := map[string]elliptic.Curve{"P-256": elliptic.P256(), "P-384": elliptic.P384(), "P-521": elliptic.P521()}
+		curve, ok := curves[k.Crv]
+		if !ok {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeJWKInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeJWKInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+// decodeJWKInt decodes a base64url encoded big-endian integer
+func decodeJWKInt(v string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(v)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,106 @@
+package backend
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func signHS256(t *testing.T, secret string, claims jwt.MapClaims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	assert.NoError(t, err)
+	return token
+}
+
+func doBearerRequest(s *HTTPServer, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/test/claims", nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+func registerClaimsRoute(s *HTTPServer) {
+	s.router.GET("/test/claims", func(c *gin.Context) {
+		claims, _ := c.Get(jwtClaimsKey)
+		c.JSON(http.StatusOK, claims)
+	})
+}
+
+func TestJWTWithSecret(t *testing.T) {
+	t.Setenv("JWT_SECRET", "top-secret")
+	t.Setenv("JWT_AUDIENCE", "mock-server")
+	t.Setenv("JWT_ISSUER", "gateway")
+	s := newTestServer(t)
+	registerClaimsRoute(s)
+
+	claims := func(exp time.Duration, aud string) jwt.MapClaims {
+		return jwt.MapClaims{"sub": "alice", "aud": aud, "iss": "gateway", "exp": time.Now().Add(exp).Unix()}
+	}
+	valid := signHS256(t, "top-secret", claims(time.Hour, "mock-server"))
+
+	tests := []struct {
+		name       string
+		token      string
+		wantStatus int
+	}{
+		{"valid", valid, http.StatusOK},
+		{"missing", "", http.StatusUnauthorized},
+		{"expired", signHS256(t, "top-secret", claims(-time.Hour, "mock-server")), http.StatusUnauthorized},
+		{"tampered", valid[:len(valid)-2] + "xx", http.StatusUnauthorized},
+		{"wrong secret", signHS256(t, "other", claims(time.Hour, "mock-server")), http.StatusUnauthorized},
+		{"wrong audience", signHS256(t, "top-secret", claims(time.Hour, "other")), http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := doBearerRequest(s, tt.token)
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantStatus == http.StatusOK {
+				assert.Contains(t, w.Body.String(), `"sub":"alice"`)
+			} else {
+				assert.Contains(t, w.Header().Get("WWW-Authenticate"), "Bearer")
+			}
+		})
+	}
+}
+
+func TestJWTWithJWKS(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "k1",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	}))
+	defer jwks.Close()
+
+	t.Setenv("JWT_JWKS_URL", jwks.URL)
+	s := newTestServer(t)
+	registerClaimsRoute(s)
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"sub": "bob", "exp": time.Now().Add(time.Hour).Unix()})
+	token.Header["kid"] = "k1"
+	signed, err := token.SignedString(key)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, doBearerRequest(s, signed).Code)
+
+	// HMAC tokens are rejected when only a JWKS is configured
+	assert.Equal(t, http.StatusUnauthorized, doBearerRequest(s, signHS256(t, "", jwt.MapClaims{"sub": "bob"})).Code)
+}
@@ -0,0 +1,47 @@
+package backend
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Log formats
+const (
+	logFormatJSON    = "json"
+	logFormatConsole = "console"
+)
+
+// NewLogger builds a logger writing to stderr at cfg.LogLevel in
+// cfg.LogFormat: json, the zap production encoding, or console for
+// human-readable lines. The returned level changes the logger's level at
+// runtime.
+func NewLogger(cfg Config) (*zap.Logger, zap.AtomicLevel, error) {
+	level := zap.NewAtomicLevelAt(cfg.LogLevel)
+
+	zapCfg := zap.NewProductionConfig()
+	zapCfg.Level = level
+	switch cfg.LogFormat {
+	case "", logFormatJSON:
+	case logFormatConsole:
+		zapCfg.Encoding = logFormatConsole
+		zapCfg.EncoderConfig = zap.NewDevelopmentEncoderConfig()
+		zapCfg.EncoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
+	default:
+		return nil, level, fmt.Errorf("unknown LOG_FORMAT %q", cfg.LogFormat)
+	}
+
+	logger, err := zapCfg.Build()
+	if err != nil {
+		return nil, level, err
+	}
+	return logger, level, nil
+}
+
+// handleLogLevel reports the current log level on GET and changes it on
+// PUT, taking {"level":"debug"} as the body; see zap.AtomicLevel.ServeHTTP
+func (s *HTTPServer) handleLogLevel(c *gin.Context) {
+	s.logLevel.ServeHTTP(c.Writer, c.Request)
+}
@@ -0,0 +1,57 @@
+package backend
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestNewLogger(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		wantErr bool
+	}{
+		{"default", "", false},
+		{"json", logFormatJSON, false},
+		{"console", logFormatConsole, false},
+		{"unknown", "xml", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.LogLevel = zapcore.DebugLevel
+			cfg.LogFormat = tt.format
+			logger, level, err := NewLogger(cfg)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, zapcore.DebugLevel, level.Level())
+			assert.True(t, logger.Core().Enabled(zapcore.DebugLevel))
+		})
+	}
+}
+
+func TestLogLevelEndpoint(t *testing.T) {
+	t.Setenv("LOG_LEVEL", "warn")
+	t.Setenv("ENABLE_ADMIN", "true")
+	s := newTestServer(t)
+	assert.False(t, s.logger.Core().Enabled(zapcore.InfoLevel))
+
+	w := doRequest(s, http.MethodGet, "/debug/loglevel", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"level":"warn"}`, w.Body.String())
+
+	w = doRequest(s, http.MethodPut, "/debug/loglevel", `{"level":"debug"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"level":"debug"}`, w.Body.String())
+	assert.True(t, s.logger.Core().Enabled(zapcore.DebugLevel))
+
+	w = doRequest(s, http.MethodPut, "/debug/loglevel", `{"level":"loud"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, zapcore.DebugLevel, s.logLevel.Level())
+}
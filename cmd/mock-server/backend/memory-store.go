@@ -0,0 +1,300 @@
+package backend
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+// memoryStore is the default Store: a concurrency-safe in-memory user
+// store keyed by the normalized email, with a secondary index by ID. Users
+// are copied on the way in and out so callers never share memory with the
+// stored records. Its methods never fail other than with errUserNotFound
+// and errUserExists.
+type memoryStore struct {
+	sync.RWMutex
+	users     map[string]*User
+	byID      map[string]*User
+	scenarios map[string]string
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		users:     make(map[string]*User),
+		byID:      make(map[string]*User),
+		scenarios: make(map[string]string),
+	}
+}
+
+// Get returns a copy of the user with the given email
+func (s *memoryStore) Get(_ context.Context, email string) (*User, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	user, ok := s.users[normalizeEmail(email)]
+	if !ok {
+		return nil, errUserNotFound
+	}
+	return user.clone(), nil
+}
+
+// GetByID returns a copy of the user with the given ID
+func (s *memoryStore) GetByID(_ context.Context, id string) (*User, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	user, ok := s.byID[id]
+	if !ok {
+		return nil, errUserNotFound
+	}
+	return user.clone(), nil
+}
+
+// Put stores a copy of the user, replacing any existing user with the same email
+func (s *memoryStore) Put(_ context.Context, user *User) error {
+	s.Lock()
+	defer s.Unlock()
+
+	s.set(normalizeEmail(user.Email), user.clone())
+	return nil
+}
+
+// Create stores a copy of the user unless a user with the same email
+// already exists, in which case errUserExists is returned
+func (s *memoryStore) Create(_ context.Context, user *User) error {
+	s.Lock()
+	defer s.Unlock()
+
+	key := normalizeEmail(user.Email)
+	if _, exists := s.users[key]; exists {
+		return errUserExists
+	}
+	s.set(key, user.clone())
+	return nil
+}
+
+// CreateMany stores copies of all users under a single lock acquisition.
+// The returned slice holds one error per user: nil on success or
+// errUserExists when the email is already taken, including by an earlier
+// user in the same batch.
+func (s *memoryStore) CreateMany(_ context.Context, users []*User) []error {
+	s.Lock()
+	defer s.Unlock()
+
+	errs := make([]error, len(users))
+	for i, user := range users {
+		key := normalizeEmail(user.Email)
+		if _, exists := s.users[key]; exists {
+			errs[i] = errUserExists
+			continue
+		}
+		s.set(key, user.clone())
+	}
+	return errs
+}
+
+// Update applies fn to the stored user while holding the write lock and
+// returns a copy of the result. If fn returns an error the user is left as is.
+func (s *memoryStore) Update(_ context.Context, email string, fn func(*User) error) (*User, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	key := normalizeEmail(email)
+	user, ok := s.users[key]
+	if !ok {
+		return nil, errUserNotFound
+	}
+
+	updated := user.clone()
+	if err := fn(updated); err != nil {
+		return nil, err
+	}
+	s.set(key, updated)
+	return updated.clone(), nil
+}
+
+// Delete removes the user with the given email and returns it
+func (s *memoryStore) Delete(_ context.Context, email string) (*User, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	key := normalizeEmail(email)
+	user, ok := s.users[key]
+	if !ok {
+		return nil, errUserNotFound
+	}
+	s.remove(key)
+	return user, nil
+}
+
+// Count returns the number of active and soft-deleted users
+func (s *memoryStore) Count(context.Context) (active, deleted int, err error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	for _, user := range s.users {
+		if user.DeletedAt != nil {
+			deleted++
+		} else {
+			active++
+		}
+	}
+	return active, deleted, nil
+}
+
+// List returns copies of all stored users in the order of sortUsers
+func (s *memoryStore) List(context.Context) ([]*User, error) {
+	s.RLock()
+	list := make([]*User, 0, len(s.users))
+	for _, user := range s.users {
+		list = append(list, user.clone())
+	}
+	s.RUnlock()
+
+	sortUsers(list)
+	return list, nil
+}
+
+// ListAfter implements userPager.ListAfter, copying only the users returned
+func (s *memoryStore) ListAfter(_ context.Context, after *User, limit int) ([]*User, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	list := make([]*User, 0, len(s.users))
+	for _, user := range s.users {
+		if after == nil || compareUsers(user, after) > 0 {
+			list = append(list, user)
+		}
+	}
+	sortUsers(list)
+	list = list[:min(limit, len(list))]
+	for i, user := range list {
+		list[i] = user.clone()
+	}
+	return list, nil
+}
+
+// Replace implements Store.Replace
+func (s *memoryStore) Replace(_ context.Context, users []*User) ([]*User, error) {
+	s.Lock()
+	// The removed users are no longer reachable from the store, so they
+	// are returned without copying
+	removed := slices.Collect(maps.Values(s.users))
+	s.users = make(map[string]*User, len(users))
+	s.byID = make(map[string]*User, len(users))
+	for _, user := range users {
+		s.set(normalizeEmail(user.Email), user.clone())
+	}
+	s.Unlock()
+
+	sortUsers(removed)
+	return removed, nil
+}
+
+// ScenarioState implements Store.ScenarioState
+func (s *memoryStore) ScenarioState(_ context.Context, name string) (string, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	if state, ok := s.scenarios[name]; ok {
+		return state, nil
+	}
+	return scenarioStarted, nil
+}
+
+// SetScenarioState implements Store.SetScenarioState
+func (s *memoryStore) SetScenarioState(_ context.Context, name, state string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	if state == scenarioStarted {
+		delete(s.scenarios, name)
+	} else {
+		s.scenarios[name] = state
+	}
+	return nil
+}
+
+// Scenarios implements Store.Scenarios
+func (s *memoryStore) Scenarios(context.Context) (map[string]string, error) {
+	s.RLock()
+	defer s.RUnlock()
+	return maps.Clone(s.scenarios), nil
+}
+
+// ResetScenarios implements Store.ResetScenarios
+func (s *memoryStore) ResetScenarios(context.Context) error {
+	s.Lock()
+	defer s.Unlock()
+	clear(s.scenarios)
+	return nil
+}
+
+// set stores user under key and keeps the ID index in sync. The caller
+// must hold the write lock.
+func (s *memoryStore) set(key string, user *User) {
+	s.remove(key)
+	s.users[key] = user
+	s.byID[user.ID] = user
+}
+
+// remove deletes the user stored under key from both indexes. The caller
+// must hold the write lock.
+func (s *memoryStore) remove(key string) {
+	if old, ok := s.users[key]; ok {
+		delete(s.byID, old.ID)
+		delete(s.users, key)
+	}
+}
+
+// touch records a modification of the user at now and bumps its version
+func (u *User) touch(now time.Time) {
+	u.UpdatedAt = now
+	u.Version++
+}
+
+// clone returns a copy of the user that shares no slices or maps with the
+// original
+func (u *User) clone() *User {
+	c := *u
+	if u.DeletedAt != nil {
+		deletedAt := *u.DeletedAt
+		c.DeletedAt = &deletedAt
+	}
+	c.Preferences = u.Preferences.clone()
+	return &c
+}
+
+// clone returns a copy of the preferences that shares no slices or maps
+// with the original
+func (p Preferences) clone() Preferences {
+	p.Tags = slices.Clone(p.Tags)
+	if p.Settings != nil {
+		p.Settings = cloneJSON(map[string]any(p.Settings)).(map[string]any)
+	}
+	p.Notifications = slices.Clone(p.Notifications)
+	return p
+}
+
+// cloneJSON returns a deep copy of a decoded JSON value, copying every
+// nested object and array
+func cloneJSON(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		c := make(map[string]any, len(v))
+		for key, item := range v {
+			c[key] = cloneJSON(item)
+		}
+		return c
+	case []any:
+		c := make([]any, len(v))
+		for i, item := range v {
+			c[i] = cloneJSON(item)
+		}
+		return c
+	default:
+		return v
+	}
+}
@@ -0,0 +1,61 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryStoreConcurrentAccess(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryStore()
+
+	var wg sync.WaitGroup
+	for i := range 8 {
+		email := fmt.Sprintf("user%d@example.com", i)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			user := &User{ID: fmt.Sprint(i), Email: email, Version: 1,
+				Preferences: Preferences{Tags: []string{"a"}, Settings: Settings{"nested": map[string]any{"n": 0.0}}}}
+			assert.NoError(t, store.Create(ctx, user))
+			for range 50 {
+				_, err := store.Update(ctx, email, func(u *User) error {
+					u.Version++
+					u.Preferences.Tags = append(u.Preferences.Tags, "b")
+					u.Preferences.Settings["nested"].(map[string]any)["n"] = float64(u.Version)
+					return nil
+				})
+				assert.NoError(t, err)
+			}
+		}()
+		for range 2 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for range 50 {
+					if user, err := store.Get(ctx, email); err == nil {
+						user.Preferences.Settings["nested"].(map[string]any)["n"] = -1.0
+						user.Preferences.Tags[0] = "changed"
+					}
+					_, err := store.List(ctx)
+					assert.NoError(t, err)
+				}
+			}()
+		}
+	}
+	wg.Wait()
+
+	for i := range 8 {
+		user, err := store.Get(ctx, fmt.Sprintf("user%d@example.com", i))
+		if assert.NoError(t, err) {
+			assert.Equal(t, 51, user.Version)
+			assert.Len(t, user.Preferences.Tags, 51)
+			assert.Equal(t, "a", user.Preferences.Tags[0], "copies returned by Get don't share tags")
+			assert.Equal(t, 51.0, user.Preferences.Settings["nested"].(map[string]any)["n"], "nor nested settings")
+		}
+	}
+}
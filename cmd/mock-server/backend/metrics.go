@@ -0,0 +1,140 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// unmatchedRoute labels requests that matched no route, keeping the label
+// cardinality bounded
+const unmatchedRoute = "unmatched"
+
+// metrics holds the Prometheus collectors of a server. Each server has its
+// own registry so several servers, as in tests, don't collide.
+type metrics struct {
+	registry *prometheus.Registry
+	handler  http.Handler
+
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	inFlight        prometheus.Gauge
+	weatherCalls    prometheus.Counter
+	weatherErrors   *prometheus.CounterVec
+}
+
+func newMetrics(s *HTTPServer) *metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "mock_http_requests_total",
+			Help: "HTTP requests handled, by method, route and status.",
+		}, []string{"method", "route", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "mock_http_request_duration_seconds",
+			Help:    "HTTP request latencies, by method, route and status.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "mock_http_requests_in_flight",
+			Help: "HTTP requests being handled.",
+		}),
+		weatherCalls: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "mock_weather_upstream_requests_total",
+			Help: "Calls to the weather provider.",
+		}),
+		weatherErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "mock_weather_upstream_errors_total",
+			Help: "Failed calls to the weather provider, by reason: timeout, not_found or error.",
+		}, []string{"reason"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.requestDuration,
+		m.inFlight,
+		m.weatherCalls,
+		m.weatherErrors,
+	)
+	for _, state := range []string{"active", "deleted"} {
+		m.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name:        "mock_users",
+			Help:        "Users in the store, by state: active or soft-deleted.",
+			ConstLabels: prometheus.Labels{"state": state},
+		}, func() float64 {
+			active, deleted, err := s.users.Count(context.Background())
+			if err != nil {
+				return math.NaN()
+			}
+			if state == "active" {
+				return float64(active)
+			}
+			return float64(deleted)
+		}))
+	}
+	for _, state := range []string{breakerClosed, breakerOpen, breakerHalfOpen} {
+		m.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name:        "mock_weather_breaker_state",
+			Help:        "Weather circuit breaker state, 1 for the current one of closed, open and half-open.",
+			ConstLabels: prometheus.Labels{"state": state},
+		}, func() float64 {
+			if s.weatherBreaker.State() == state {
+				return 1
+			}
+			return 0
+		}))
+	}
+	m.handler = promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+	return m
+}
+
+// metricsMiddleware records the count and latency of every request by its
+// route pattern rather than its path, so /users/email/:email is a single
+// series
+func (s *HTTPServer) metricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		s.metrics.inFlight.Inc()
+		defer s.metrics.inFlight.Dec()
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		status := strconv.Itoa(c.Writer.Status())
+		s.metrics.requests.WithLabelValues(c.Request.Method, route, status).Inc()
+		s.metrics.requestDuration.WithLabelValues(c.Request.Method, route, status).Observe(time.Since(start).Seconds())
+	}
+}
+
+// observeWeatherCall records a call to the weather provider and its error,
+// if any
+func (m *metrics) observeWeatherCall(err error) {
+	m.weatherCalls.Inc()
+	switch {
+	case err == nil:
+	case isTimeout(err):
+		m.weatherErrors.WithLabelValues("timeout").Inc()
+	case errors.Is(err, errWeatherNotFound):
+		m.weatherErrors.WithLabelValues("not_found").Inc()
+	default:
+		m.weatherErrors.WithLabelValues("error").Inc()
+	}
+}
+
+// handleMetrics serves the metrics in the Prometheus exposition format
+func (s *HTTPServer) handleMetrics(c *gin.Context) {
+	s.metrics.handler.ServeHTTP(c.Writer, c.Request)
+}
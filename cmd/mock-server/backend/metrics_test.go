@@ -0,0 +1,39 @@
+package backend
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	s := newTestServer(t)
+	doRequest(s, http.MethodPost, "/users", `{"username":"alice","email":"alice@example.com"}`)
+	doRequest(s, http.MethodGet, "/users/email/alice@example.com", "")
+	doRequest(s, http.MethodGet, "/users/email/bob@example.com", "")
+	doRequest(s, http.MethodGet, "/nope", "")
+	s.metrics.observeWeatherCall(nil)
+	s.metrics.observeWeatherCall(errors.New("upstream down"))
+
+	w := doRequest(s, http.MethodGet, "/metrics", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	body := w.Body.String()
+	for _, want := range []string{
+		`mock_http_requests_total{method="POST",route="/users",status="201"} 1`,
+		`mock_http_requests_total{method="GET",route="/users/email/:email",status="200"} 1`,
+		`mock_http_requests_total{method="GET",route="/users/email/:email",status="404"} 1`,
+		`mock_http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`mock_http_request_duration_seconds_count{method="POST",route="/users",status="201"} 1`,
+		`mock_http_requests_in_flight 1`,
+		`mock_users{state="active"} 1`,
+		`mock_users{state="deleted"} 0`,
+		`mock_weather_upstream_requests_total 2`,
+		`mock_weather_upstream_errors_total{reason="error"} 1`,
+		`mock_weather_breaker_state{state="closed"} 1`,
+		`mock_weather_breaker_state{state="open"} 0`,
+	} {
+		assert.Contains(t, body, want)
+	}
+}
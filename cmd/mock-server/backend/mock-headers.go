@@ -0,0 +1,103 @@
+package backend
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// mockHeadersMiddleware lets a request force its response when
+// MockHeaders is enabled. X-Mock-Delay sleeps for a Go duration such as
+// 500ms before handling the request, capped by DelayConfig.Max like
+// ?delay=; X-Mock-Delay-Min and X-Mock-Delay-Max sleep for a random delay
+// in that range instead. X-Mock-Status skips the handler and answers with
+// that status and a generic body: an error for 4xx and 5xx statuses, the
+// status text otherwise. Responses forced this way are marked with
+// X-Mock-Injected. Without MockHeaders these headers are ignored.
+func (s *HTTPServer) mockHeadersMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg := s.config()
+		if !cfg.MockHeaders {
+			c.Next()
+			return
+		}
+
+		status := 0
+		if v := c.GetHeader("X-Mock-Status"); v != "" {
+			code, err := strconv.Atoi(v)
+			if err != nil || code < 100 || code > 599 {
+				respondError(c, http.StatusBadRequest, codeInvalidRequest, fmt.Sprintf("invalid X-Mock-Status %q, must be a status code from 100 to 599", v))
+				return
+			}
+			status = code
+		}
+		delay, err := s.mockDelay(c, cfg.Delay.Max)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+			return
+		}
+		if !sleepContext(c, delay) {
+			c.Abort()
+			return
+		}
+		if status == 0 {
+			c.Next()
+			return
+		}
+
+		c.Header("X-Mock-Injected", "true")
+		switch {
+		case !bodyAllowed(status):
+			c.AbortWithStatus(status)
+		case status >= http.StatusBadRequest:
+			respondError(c, status, codeInjected, fmt.Sprintf("mock: %d %s", status, http.StatusText(status)))
+		default:
+			c.Abort()
+			respond(c, status, gin.H{"message": http.StatusText(status)})
+		}
+	}
+}
+
+// bodyAllowed reports whether a response with status may have a body
+func bodyAllowed(status int) bool {
+	return status >= http.StatusOK && status != http.StatusNoContent && status != http.StatusNotModified
+}
+
+// mockDelay returns the delay a request asks for with X-Mock-Delay, or
+// draws it from X-Mock-Delay-Min to X-Mock-Delay-Max. A missing bound is
+// 0 for the minimum and the minimum for the maximum. Every delay is
+// capped by limit.
+func (s *HTTPServer) mockDelay(c *gin.Context, limit time.Duration) (time.Duration, error) {
+	if v := c.GetHeader("X-Mock-Delay"); v != "" {
+		d, err := parseRequestedDelay(v, limit)
+		if err != nil {
+			return 0, fmt.Errorf("X-Mock-Delay: %w", err)
+		}
+		return d, nil
+	}
+
+	minValue, maxValue := c.GetHeader("X-Mock-Delay-Min"), c.GetHeader("X-Mock-Delay-Max")
+	if minValue == "" && maxValue == "" {
+		return 0, nil
+	}
+	var latency Latency
+	var err error
+	if minValue != "" {
+		if latency.Min, err = parseRequestedDelay(minValue, limit); err != nil {
+			return 0, fmt.Errorf("X-Mock-Delay-Min: %w", err)
+		}
+	}
+	latency.Max = latency.Min
+	if maxValue != "" {
+		if latency.Max, err = parseRequestedDelay(maxValue, limit); err != nil {
+			return 0, fmt.Errorf("X-Mock-Delay-Max: %w", err)
+		}
+	}
+	if latency.Max < latency.Min {
+		return 0, fmt.Errorf("X-Mock-Delay-Max %s is less than X-Mock-Delay-Min %s", latency.Max, latency.Min)
+	}
+	return s.jitter.draw(latency), nil
+}
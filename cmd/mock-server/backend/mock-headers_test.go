@@ -0,0 +1,82 @@
+package backend
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMockHeaders(t *testing.T) {
+	mockRequest := func(s *HTTPServer, header, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/users", nil)
+		req.Header.Set(header, value)
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		s := newTestServer(t)
+		w := mockRequest(s, "X-Mock-Status", "503")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("X-Mock-Injected"))
+	})
+
+	t.Setenv("MOCK_HEADERS", "true")
+	s := newTestServer(t)
+
+	tests := []struct {
+		name   string
+		header string
+		value  string
+		status int
+		body   string
+	}{
+		{"error status", "X-Mock-Status", "503", http.StatusServiceUnavailable, `{"code":"injected","error":"mock: 503 Service Unavailable"}`},
+		{"success status", "X-Mock-Status", "202", http.StatusAccepted, `{"message":"Accepted"}`},
+		{"no content", "X-Mock-Status", "204", http.StatusNoContent, ""},
+		{"invalid status", "X-Mock-Status", "700", http.StatusBadRequest, `{"code":"invalid_request","error":"invalid X-Mock-Status \"700\", must be a status code from 100 to 599"}`},
+		{"invalid delay", "X-Mock-Delay", "soon", http.StatusBadRequest, `{"code":"invalid_request","error":"X-Mock-Delay: invalid delay \"soon\", must be a duration such as 500ms or 2s"}`},
+		{"delay over max", "X-Mock-Delay", "1h", http.StatusBadRequest, `{"code":"invalid_request","error":"X-Mock-Delay: delay 1h0m0s exceeds the maximum of 30s"}`},
+		{"invalid delay min", "X-Mock-Delay-Min", "soon", http.StatusBadRequest, `{"code":"invalid_request","error":"X-Mock-Delay-Min: invalid delay \"soon\", must be a duration such as 500ms or 2s"}`},
+		{"delay max over max", "X-Mock-Delay-Max", "1m", http.StatusBadRequest, `{"code":"invalid_request","error":"X-Mock-Delay-Max: delay 1m0s exceeds the maximum of 30s"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := mockRequest(s, tt.header, tt.value)
+			assert.Equal(t, tt.status, w.Code)
+			if tt.body == "" {
+				assert.Empty(t, w.Body.String())
+			} else {
+				assert.JSONEq(t, tt.body, w.Body.String())
+			}
+		})
+	}
+
+	t.Run("delay", func(t *testing.T) {
+		start := time.Now()
+		w := mockRequest(s, "X-Mock-Delay", "50ms")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+	})
+
+	t.Run("delay range", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/users", nil)
+		req.Header.Set("X-Mock-Delay-Min", "20ms")
+		req.Header.Set("X-Mock-Delay-Max", "40ms")
+		w := httptest.NewRecorder()
+		start := time.Now()
+		s.router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+
+		req.Header.Set("X-Mock-Delay-Min", "50ms")
+		w = httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.JSONEq(t, `{"code":"invalid_request","error":"X-Mock-Delay-Max 40ms is less than X-Mock-Delay-Min 50ms"}`, w.Body.String())
+	})
+}
@@ -0,0 +1,207 @@
+package backend
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	errNotificationExists   = errors.New("notification already exists")
+	errNotificationNotFound = errors.New("notification not found")
+)
+
+// findNotification returns the index of the notification with the given
+// type and channel, or -1 if there is none
+func findNotification(notifications []Notification, typ, channel string) int {
+	for i, n := range notifications {
+		if n.Type == typ && n.Channel == channel {
+			return i
+		}
+	}
+	return -1
+}
+
+// handleListNotifications returns only the notifications of a user. With
+// ?enabled=true just the enabled ones are returned.
+func (s *HTTPServer) handleListNotifications(c *gin.Context) {
+	user, err := s.getUser(c.Request.Context(), c.Param("email"))
+	if err != nil {
+		s.respondStoreError(c, err)
+		return
+	}
+
+	notifications := user.Preferences.Notifications
+	if notifications == nil {
+		notifications = []Notification{}
+	}
+	if c.Query("enabled") == "true" {
+		notifications = slices.DeleteFunc(notifications, func(n Notification) bool { return !n.Enabled })
+	}
+
+	respond(c, http.StatusOK, notifications)
+}
+
+// handleAddNotification appends a single notification to a user's
+// preferences. A notification with the same type and channel must not
+// already exist.
+func (s *HTTPServer) handleAddNotification(c *gin.Context) {
+	email := c.Param("email")
+
+	var notification Notification
+	if err := decodeStrict(c.Request.Body, &notification); err != nil {
+		respondValidationError(c, err)
+		return
+	}
+	errs := fieldErrors{}
+	validateNotification(errs, "", notification)
+	if err := errs.err(); err != nil {
+		respondValidationError(c, err)
+		return
+	}
+
+	user, err := s.updateUser(c.Request.Context(), email, func(u *User) error {
+		notifications := u.Preferences.Notifications
+		if findNotification(notifications, notification.Type, notification.Channel) >= 0 {
+			return errNotificationExists
+		}
+		u.Preferences.Notifications = append(notifications, notification)
+		u.touch(s.clock.Now())
+		return nil
+	})
+	switch {
+	case errors.Is(err, errNotificationExists):
+		respondError(c, http.StatusConflict, codeConflict, err.Error())
+		return
+	case err != nil:
+		s.respondStoreError(c, err)
+		return
+	}
+	s.publish(eventUserUpdated, user)
+
+	respond(c, http.StatusOK, user)
+}
+
+// mergeNotifications merges updates into notifications by type and
+// channel: matching entries are overwritten in place, new ones are
+// appended in payload order and the rest are kept
+func mergeNotifications(notifications, updates []Notification) []Notification {
+	for _, n := range updates {
+		if i := findNotification(notifications, n.Type, n.Channel); i >= 0 {
+			notifications[i] = n
+		} else {
+			notifications = append(notifications, n)
+		}
+	}
+	return notifications
+}
+
+// handleUpdateNotifications updates several notifications at once. By
+// default the payload is merged into the existing notifications by type
+// and channel, so entries not mentioned are left alone. With
+// ?replace=true the payload becomes the complete list instead, removing
+// everything not mentioned. Entries repeating a type and channel are
+// rejected in both modes.
+func (s *HTTPServer) handleUpdateNotifications(c *gin.Context) {
+	email := c.Param("email")
+	replace := c.Query("replace") == "true"
+
+	var notifications []Notification
+	if err := decodeStrict(c.Request.Body, &notifications); err != nil {
+		respondValidationError(c, err)
+		return
+	}
+	errs := fieldErrors{}
+	validateNotifications(errs, "", notifications)
+	if err := errs.err(); err != nil {
+		respondValidationError(c, err)
+		return
+	}
+
+	user, err := s.updateUser(c.Request.Context(), email, func(u *User) error {
+		existing := u.Preferences.Notifications
+		if replace || existing == nil {
+			existing = []Notification{}
+		}
+		u.Preferences.Notifications = mergeNotifications(existing, notifications)
+		u.touch(s.clock.Now())
+		return nil
+	})
+	if err != nil {
+		s.respondStoreError(c, err)
+		return
+	}
+	s.publish(eventUserUpdated, user)
+
+	respond(c, http.StatusOK, user)
+}
+
+// handleToggleNotification sets the enabled flag of the notification
+// matching the type and channel path parameters
+func (s *HTTPServer) handleToggleNotification(c *gin.Context) {
+	email := c.Param("email")
+	typ, channel := c.Param("type"), c.Param("channel")
+
+	var body struct {
+		Enabled *bool `json:"enabled"`
+	}
+	if err := decodeStrict(c.Request.Body, &body); err != nil {
+		respondValidationError(c, err)
+		return
+	}
+	if body.Enabled == nil {
+		respondValidationError(c, fieldErrors{"enabled": "enabled is required"})
+		return
+	}
+
+	user, err := s.updateUser(c.Request.Context(), email, func(u *User) error {
+		i := findNotification(u.Preferences.Notifications, typ, channel)
+		if i < 0 {
+			return errNotificationNotFound
+		}
+		u.Preferences.Notifications[i].Enabled = *body.Enabled
+		u.touch(s.clock.Now())
+		return nil
+	})
+	switch {
+	case errors.Is(err, errNotificationNotFound):
+		respondError(c, http.StatusNotFound, codeNotFound, err.Error())
+		return
+	case err != nil:
+		s.respondStoreError(c, err)
+		return
+	}
+	s.publish(eventUserUpdated, user)
+
+	respond(c, http.StatusOK, user)
+}
+
+// handleDeleteNotification removes the notification matching both the type
+// and channel path parameters exactly
+func (s *HTTPServer) handleDeleteNotification(c *gin.Context) {
+	email := c.Param("email")
+	typ, channel := c.Param("type"), c.Param("channel")
+
+	user, err := s.updateUser(c.Request.Context(), email, func(u *User) error {
+		i := findNotification(u.Preferences.Notifications, typ, channel)
+		if i < 0 {
+			return errNotificationNotFound
+		}
+		u.Preferences.Notifications = slices.Delete(u.Preferences.Notifications, i, i+1)
+		u.touch(s.clock.Now())
+		return nil
+	})
+	switch {
+	case errors.Is(err, errNotificationNotFound):
+		respondError(c, http.StatusNotFound, codeNotFound, err.Error())
+		return
+	case err != nil:
+		s.respondStoreError(c, err)
+		return
+	}
+	s.publish(eventUserUpdated, user)
+
+	c.Status(http.StatusNoContent)
+}
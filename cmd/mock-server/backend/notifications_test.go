@@ -0,0 +1,219 @@
+package backend
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateNotifications(t *testing.T) {
+	existing := `{"username":"alice","email":"alice@example.com","preferences":{"notifications":[` +
+		`{"type":"email","channel":"security","enabled":true},` +
+		`{"type":"push","channel":"system","enabled":true}]}}`
+	update := `[{"type":"push","channel":"system","enabled":false,"frequency":1},` +
+		`{"type":"sms","channel":"marketing","enabled":true}]`
+
+	tests := []struct {
+		name  string
+		query string
+		want  []Notification
+	}{
+		{
+			name: "merge",
+			want: []Notification{
+				{Type: "email", Channel: "security", Enabled: true},
+				{Type: "push", Channel: "system", Frequency: FrequencyDaily},
+				{Type: "sms", Channel: "marketing", Enabled: true},
+			},
+		},
+		{
+			name:  "replace",
+			query: "?replace=true",
+			want: []Notification{
+				{Type: "push", Channel: "system", Frequency: FrequencyDaily},
+				{Type: "sms", Channel: "marketing", Enabled: true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			assert.Equal(t, http.StatusCreated, doRequest(s, http.MethodPost, "/users", existing).Code)
+
+			w := doRequest(s, http.MethodPut, "/users/alice@example.com/notifications"+tt.query, update)
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			var user User
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &user))
+			assert.Equal(t, tt.want, user.Preferences.Notifications)
+		})
+	}
+}
+
+// createNotifiedUser creates alice with an enabled email:security and a
+// disabled push:system notification
+func createNotifiedUser(t *testing.T, s *HTTPServer) {
+	t.Helper()
+	w := doRequest(s, http.MethodPost, "/users", `{"username":"alice","email":"alice@example.com","preferences":{"notifications":[`+
+		`{"type":"email","channel":"security","enabled":true},`+
+		`{"type":"push","channel":"system","enabled":false}]}}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+}
+
+func TestDuplicateNotificationsRejected(t *testing.T) {
+	duplicates := `[{"type":"email","channel":"security","enabled":true},{"type":"email","channel":"security"}]`
+
+	tests := []struct {
+		name      string
+		method    string
+		path      string
+		body      string
+		wantField string
+	}{
+		{"create user", http.MethodPost, "/users",
+			`{"username":"bobby","email":"bobby@example.com","preferences":{"notifications":` + duplicates + `}}`,
+			"preferences.notifications[1]"},
+		{"replace preferences", http.MethodPut, "/users/alice@example.com/preferences",
+			`{"theme":"light","notifications":` + duplicates + `}`, "notifications[1]"},
+		{"merge preferences", http.MethodPut, "/users/alice@example.com/preferences?merge=true",
+			`{"notifications":` + duplicates + `}`, "notifications[1]"},
+		{"patch user", http.MethodPatch, "/users/alice@example.com",
+			`{"preferences":{"notifications":` + duplicates + `}}`, "preferences.notifications[1]"},
+		{"update notifications", http.MethodPut, "/users/alice@example.com/notifications", duplicates, "notifications[1]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			createNotifiedUser(t, s)
+
+			w := doRequest(s, tt.method, tt.path, tt.body)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			var body APIError
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			assert.Equal(t, codeValidationFailed, body.Code)
+			assert.Equal(t, ErrorDetails{
+				tt.wantField: "duplicate notification email:security, already at notifications[0]",
+			}, body.Details)
+		})
+	}
+}
+
+func TestAddNotification(t *testing.T) {
+	s := newTestServer(t)
+	createNotifiedUser(t, s)
+
+	w := doRequest(s, http.MethodPost, "/users/alice@example.com/notifications",
+		`{"type":"sms","channel":"marketing","enabled":true,"frequency":2}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []Notification{
+		{Type: "email", Channel: "security", Enabled: true},
+		{Type: "push", Channel: "system"},
+		{Type: "sms", Channel: "marketing", Enabled: true, Frequency: FrequencyWeekly},
+	}, decodeUser(t, w).Preferences.Notifications)
+
+	tests := []struct {
+		name       string
+		email      string
+		body       string
+		wantStatus int
+	}{
+		{"duplicate", "alice@example.com", `{"type":"push","channel":"system","enabled":true}`, http.StatusConflict},
+		{"invalid type", "alice@example.com", `{"type":"fax","channel":"system"}`, http.StatusBadRequest},
+		{"malformed", "alice@example.com", `{"type":`, http.StatusBadRequest},
+		{"unknown user", "bobby@example.com", `{"type":"push","channel":"marketing"}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := doRequest(s, http.MethodPost, "/users/"+tt.email+"/notifications", tt.body)
+			assert.Equal(t, tt.wantStatus, w.Code)
+		})
+	}
+	w = doRequest(s, http.MethodGet, "/users/alice@example.com/notifications", "")
+	var notifications []Notification
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &notifications))
+	assert.Len(t, notifications, 3, "rejected notifications are not added")
+}
+
+func TestDeleteNotification(t *testing.T) {
+	s := newTestServer(t)
+	createNotifiedUser(t, s)
+
+	w := doRequest(s, http.MethodDelete, "/users/alice@example.com/notifications/email/security", "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	w = doRequest(s, http.MethodGet, "/users/email/alice@example.com", "")
+	assert.Equal(t, []Notification{{Type: "push", Channel: "system"}}, decodeUser(t, w).Preferences.Notifications)
+
+	for name, path := range map[string]string{
+		"already deleted": "/users/alice@example.com/notifications/email/security",
+		"type only":       "/users/alice@example.com/notifications/push/security",
+		"channel only":    "/users/alice@example.com/notifications/email/system",
+		"unknown user":    "/users/bobby@example.com/notifications/push/system",
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, http.StatusNotFound, doRequest(s, http.MethodDelete, path, "").Code)
+		})
+	}
+}
+
+func TestListNotifications(t *testing.T) {
+	s := newTestServer(t)
+	createNotifiedUser(t, s)
+	doRequest(s, http.MethodPost, "/users", `{"username":"bobby","email":"bobby@example.com","preferences":{"notifications":[]}}`)
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		want       string
+	}{
+		{"all", "/users/alice@example.com/notifications", http.StatusOK,
+			`[{"type":"email","channel":"security","enabled":true,"frequency":0},` +
+				`{"type":"push","channel":"system","enabled":false,"frequency":0}]`},
+		{"enabled only", "/users/alice@example.com/notifications?enabled=true", http.StatusOK,
+			`[{"type":"email","channel":"security","enabled":true,"frequency":0}]`},
+		{"none", "/users/bobby@example.com/notifications", http.StatusOK, `[]`},
+		{"unknown user", "/users/carol@example.com/notifications", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := doRequest(s, http.MethodGet, tt.path, "")
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.want != "" {
+				assert.JSONEq(t, tt.want, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestToggleNotification(t *testing.T) {
+	s := newTestServer(t)
+	createNotifiedUser(t, s)
+
+	w := doRequest(s, http.MethodPatch, "/users/alice@example.com/notifications/push/system", `{"enabled":true}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []Notification{
+		{Type: "email", Channel: "security", Enabled: true},
+		{Type: "push", Channel: "system", Enabled: true},
+	}, decodeUser(t, w).Preferences.Notifications)
+
+	tests := []struct {
+		name       string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{"unknown pair", "/users/alice@example.com/notifications/sms/system", `{"enabled":true}`, http.StatusNotFound},
+		{"unknown user", "/users/bobby@example.com/notifications/push/system", `{"enabled":true}`, http.StatusNotFound},
+		{"malformed body", "/users/alice@example.com/notifications/push/system", `{"enabled":`, http.StatusBadRequest},
+		{"not a boolean", "/users/alice@example.com/notifications/push/system", `{"enabled":"yes"}`, http.StatusBadRequest},
+		{"missing enabled", "/users/alice@example.com/notifications/push/system", `{}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantStatus, doRequest(s, http.MethodPatch, tt.path, tt.body).Code)
+		})
+	}
+}
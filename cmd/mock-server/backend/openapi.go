@@ -0,0 +1,313 @@
+package backend
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3gen"
+	"github.com/gin-gonic/gin"
+)
+
+const openAPIPath = "/openapi.json"
+
+// apiOperation documents a route in the OpenAPI document. Request and
+// Response name a schema of apiSchemas, prefixed with [] for an array, or
+// are "object" for a free-form JSON object.
+type apiOperation struct {
+	Summary string
+	Tag     string
+	Query   []apiParam
+	Request string
+	// RequestType is the media type of a body that isn't JSON
+	RequestType string
+	Response    string
+	// ResponseType is the media type of a response that isn't JSON
+	ResponseType string
+	// Status is the success status, 200 by default
+	Status int
+}
+
+// apiParam is a query parameter of type string unless Type says otherwise
+type apiParam struct {
+	Name        string
+	Type        string
+	Description string
+}
+
+// userCount is the body of GET /users/count
+type userCount struct {
+	Count   int `json:"count"`
+	Deleted int `json:"deleted"`
+}
+
+// apiSchemas are the components of the OpenAPI document, generated from
+// the types' JSON encoding
+var apiSchemas = map[string]any{
+	"User":             User{},
+	"Preferences":      Preferences{},
+	"Notification":     Notification{},
+	"BulkCreateResult": bulkCreateResult{},
+	"UserCount":        userCount{},
+	"UserPage":         userPage{},
+	"Weather":          Weather{},
+	"Forecast":         Forecast{},
+	"AuditEntry":       auditEntry{},
+	"Snapshot":         storeSnapshot{},
+	"Error":            APIError{},
+}
+
+var (
+	pageParams = []apiParam{
+		{Name: "limit", Type: "integer", Description: "page size, at most 100 (default 20)"},
+		{Name: "offset", Type: "integer", Description: "number of items skipped"},
+	}
+	includeDeletedParam = apiParam{Name: "includeDeleted", Type: "boolean", Description: "include soft-deleted users"}
+	userFilterParams    = []apiParam{
+		{Name: "createdAfter", Description: "RFC 3339 timestamp the users were created after"},
+		{Name: "createdBefore", Description: "RFC 3339 timestamp the users were created before"},
+		{Name: "theme", Description: "theme of the users' preferences"},
+		{Name: "tag", Description: "tag the users' preferences have, repeatable to require several"},
+	}
+)
+
+// apiOperations documents every route, keyed by method and gin route
+// pattern without the API version like the registrations in routes.go.
+// TestOpenAPICoversRoutes keeps both in sync.
+var apiOperations = map[string]apiOperation{
+	"GET /users": {Summary: "List users ordered by creation time", Tag: "users",
+		Query: slices.Concat(pageParams, []apiParam{includeDeletedParam, {Name: "cursor",
+			Description: "nextCursor of the previous page, empty for the first; selects cursor pagination and a UserPage response"}}, userFilterParams),
+		Response: "[]User"},
+	"POST /users":        {Summary: "Create a user", Tag: "users", Request: "User", Response: "User", Status: http.StatusCreated},
+	"GET /users.csv":     {Summary: "Export the users as CSV", Tag: "users", ResponseType: "text/csv"},
+	"POST /users/bulk":   {Summary: "Create several users", Tag: "users", Request: "[]User", Response: "[]BulkCreateResult", Status: http.StatusCreated},
+	"POST /users/import": {Summary: "Import users from a CSV upload", Tag: "users", RequestType: "multipart/form-data", Response: "object"},
+	"GET /users/count":   {Summary: "Count active and soft-deleted users", Tag: "users", Response: "UserCount"},
+	"GET /users/search": {Summary: "Search users by username", Tag: "users", Query: append([]apiParam{
+		{Name: "q", Description: "case-insensitive part of the username"},
+		{Name: "limit", Type: "integer", Description: "maximum number of results"},
+	}, userFilterParams...), Response: "[]User"},
+	"GET /users/email/:email": {Summary: "Get a user by email", Tag: "users", Query: []apiParam{includeDeletedParam}, Response: "User"},
+	"GET /users/id/:id":       {Summary: "Get a user by ID", Tag: "users", Response: "User"},
+	"PATCH /users/:email":     {Summary: "Update a user with a JSON merge patch", Tag: "users", Request: "object", Response: "User"},
+	"DELETE /users/:email": {Summary: "Delete a user", Tag: "users", Query: []apiParam{
+		{Name: "soft", Type: "boolean", Description: "mark the user deleted instead of removing it"},
+		{Name: "return", Type: "boolean", Description: "respond with the deleted user instead of 204"},
+	}, Status: http.StatusNoContent},
+	"POST /users/:email/restore": {Summary: "Restore a soft-deleted user", Tag: "users", Response: "User"},
+	"PUT /users/:email/preferences": {Summary: "Replace or merge a user's preferences", Tag: "preferences", Query: []apiParam{
+		{Name: "merge", Type: "boolean", Description: "merge into the current preferences"},
+	}, Request: "Preferences", Response: "User"},
+	"POST /users/:email/preferences/reset": {Summary: "Reset a user's preferences to the defaults", Tag: "preferences", Response: "User"},
+	"GET /users/:email/notifications": {Summary: "List a user's notifications", Tag: "notifications", Query: []apiParam{
+		{Name: "enabled", Type: "boolean", Description: "only enabled or disabled notifications"},
+	}, Response: "[]Notification"},
+	"POST /users/:email/notifications": {Summary: "Add a notification", Tag: "notifications", Request: "Notification", Response: "User"},
+	"PUT /users/:email/notifications": {Summary: "Upsert or replace notifications", Tag: "notifications", Query: []apiParam{
+		{Name: "replace", Type: "boolean", Description: "replace every notification"},
+	}, Request: "[]Notification", Response: "User"},
+	"PATCH /users/:email/notifications/:type/:channel":  {Summary: "Toggle a notification", Tag: "notifications", Request: "object", Response: "User"},
+	"DELETE /users/:email/notifications/:type/:channel": {Summary: "Delete a notification", Tag: "notifications", Status: http.StatusNoContent},
+	"GET /users/:email/avatar":                          {Summary: "Get a user's avatar", Tag: "avatars", ResponseType: "image/png"},
+	"POST /users/:email/avatar":                         {Summary: "Upload a user's avatar", Tag: "avatars", RequestType: "multipart/form-data", Response: "object"},
+	"DELETE /users/:email/avatar":                       {Summary: "Delete a user's avatar", Tag: "avatars", Status: http.StatusNoContent},
+	"GET /weather": {Summary: "Current weather of a city", Tag: "weather", Query: []apiParam{
+		{Name: "city", Description: "adcode of the city"},
+	}, Response: "object"},
+	"GET /weather/forecast": {Summary: "Forecast of a city", Tag: "weather", Query: []apiParam{
+		{Name: "city", Description: "adcode of the city"},
+	}, Response: "Forecast"},
+	"GET /weather/batch": {Summary: "Current weather of several cities", Tag: "weather", Query: []apiParam{
+		{Name: "cities", Description: "comma separated adcodes"},
+	}, Response: "object"},
+	"GET /events":         {Summary: "Stream user changes as server-sent events", Tag: "events", ResponseType: "text/event-stream"},
+	"GET /ws":             {Summary: "Stream user changes over a WebSocket", Tag: "events", Status: http.StatusSwitchingProtocols},
+	"GET /audit":          {Summary: "List the audit log", Tag: "operations", Query: pageParams, Response: "[]AuditEntry"},
+	"GET /metrics":        {Summary: "Prometheus metrics", Tag: "operations", ResponseType: "text/plain"},
+	"GET /healthz":        {Summary: "Liveness check", Tag: "operations", Response: "object"},
+	"GET /readyz":         {Summary: "Readiness check", Tag: "operations", Response: "object"},
+	"GET " + openAPIPath:  {Summary: "This OpenAPI document", Tag: "operations", Response: "object"},
+	"GET /admin/snapshot": {Summary: "Export every user", Tag: "admin", Response: "Snapshot"},
+	"POST /admin/snapshot": {Summary: "Import users, replacing or merging with the current ones", Tag: "admin", Query: []apiParam{
+		{Name: "merge", Type: "boolean", Description: "keep the current users"},
+	}, Request: "Snapshot", Response: "object"},
+	"POST /admin/reset":    {Summary: "Empty the store and caches", Tag: "admin", Response: "object"},
+	"GET /admin/scenarios": {Summary: "List the stub scenario states", Tag: "admin", Response: "object"},
+	"POST /admin/scenarios/reset": {Summary: "Reset stub scenarios", Tag: "admin", Query: []apiParam{
+		{Name: "name", Description: "the scenario to reset, every scenario by default"},
+	}, Response: "object"},
+	"GET /debug/loglevel": {Summary: "Get the log level", Tag: "operations", Response: "object"},
+	"PUT /debug/loglevel": {Summary: "Change the log level", Tag: "operations", Request: "object", Response: "object"},
+}
+
+// handleOpenAPI serves the OpenAPI 3 document of the routes the server
+// has, see openAPIDocument
+func (s *HTTPServer) handleOpenAPI(c *gin.Context) {
+	doc, err := s.openAPIDocument()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, codeInternal, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, doc)
+}
+
+// openAPIDocument describes every route registered on the router with its
+// apiOperations entry. The runtime profiles and the explorer are left out;
+// routes without an entry are listed with their path parameters only.
+func (s *HTTPServer) openAPIDocument() (*openapi3.T, error) {
+	schemas, err := generateAPISchemas()
+	if err != nil {
+		return nil, err
+	}
+	doc := &openapi3.T{
+		OpenAPI: "3.0.3",
+		Info: &openapi3.Info{
+			Title:   "Mock Server API",
+			Version: "1.0.0",
+		},
+		Paths:      openapi3.NewPaths(),
+		Components: &openapi3.Components{Schemas: schemas},
+	}
+
+	routes := s.router.Routes()
+	versioned := map[string]bool{}
+	for _, route := range routes {
+		if version, rest := versionedRoute(route.Path); version != "" {
+			versioned[route.Method+" "+rest] = true
+		}
+	}
+	for _, route := range routes {
+		if undocumentedRoute(route.Path) {
+			continue
+		}
+		path, params := openAPIPathOf(route.Path)
+		item := doc.Paths.Value(path)
+		if item == nil {
+			item = &openapi3.PathItem{}
+			doc.Paths.Set(path, item)
+		}
+		_, rest := versionedRoute(route.Path)
+		key := route.Method + " " + rest
+		operation := newAPIOperation(apiOperations[key], params)
+		// The unprefixed aliases of versioned routes are deprecated
+		operation.Deprecated = rest == route.Path && versioned[key]
+		item.SetOperation(route.Method, operation)
+	}
+	return doc, nil
+}
+
+// generateAPISchemas generates the schemas of apiSchemas, with nested
+// types referencing their own component
+func generateAPISchemas() (openapi3.Schemas, error) {
+	names := make(map[reflect.Type]string, len(apiSchemas))
+	for name, v := range apiSchemas {
+		names[reflect.TypeOf(v)] = name
+	}
+	typeName := openapi3gen.CreateTypeNameGenerator(func(t reflect.Type) string {
+		if name, ok := names[t]; ok {
+			return name
+		}
+		r := []rune(t.Name())
+		r[0] = unicode.ToUpper(r[0])
+		return string(r)
+	})
+
+	schemas := openapi3.Schemas{}
+	for name, v := range apiSchemas {
+		// A generator caches the schemas of the types it has seen and would
+		// inline a type generated before as a top-level schema, so each
+		// gets its own
+		gen := openapi3gen.NewGenerator(
+			openapi3gen.CreateComponentSchemas(openapi3gen.ExportComponentSchemasOptions{ExportComponentSchemas: true}),
+			typeName,
+		)
+		ref, err := gen.NewSchemaRefForValue(v, schemas)
+		if err != nil {
+			return nil, fmt.Errorf("generate schema %s: %w", name, err)
+		}
+		if _, ok := schemas[name]; !ok {
+			schemas[name] = ref
+		}
+	}
+	return schemas, nil
+}
+
+// openAPIPathOf converts a gin route pattern to an OpenAPI path, returning
+// the names of its parameters
+func openAPIPathOf(route string) (string, []string) {
+	segments := strings.Split(route, "/")
+	var params []string
+	for i, seg := range segments {
+		if strings.HasPrefix(seg, ":") || strings.HasPrefix(seg, "*") {
+			params = append(params, seg[1:])
+			segments[i] = "{" + seg[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
+
+func newAPIOperation(op apiOperation, pathParams []string) *openapi3.Operation {
+	operation := &openapi3.Operation{
+		Summary:   op.Summary,
+		Responses: openapi3.NewResponses(),
+	}
+	if op.Tag != "" {
+		operation.Tags = []string{op.Tag}
+	}
+	for _, name := range pathParams {
+		operation.AddParameter(openapi3.NewPathParameter(name).WithSchema(openapi3.NewStringSchema()))
+	}
+	for _, p := range op.Query {
+		schema := openapi3.NewStringSchema()
+		if p.Type != "" {
+			schema = &openapi3.Schema{Type: &openapi3.Types{p.Type}}
+		}
+		param := openapi3.NewQueryParameter(p.Name).WithSchema(schema)
+		param.Description = p.Description
+		operation.AddParameter(param)
+	}
+
+	switch {
+	case op.RequestType != "":
+		operation.RequestBody = &openapi3.RequestBodyRef{Value: openapi3.NewRequestBody().
+			WithRequired(true).WithContent(openapi3.NewContentWithSchema(nil, []string{op.RequestType}))}
+	case op.Request != "":
+		operation.RequestBody = &openapi3.RequestBodyRef{Value: openapi3.NewRequestBody().
+			WithRequired(true).WithJSONSchemaRef(apiSchemaRef(op.Request))}
+	}
+
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := openapi3.NewResponse().WithDescription(http.StatusText(status))
+	switch {
+	case op.ResponseType != "":
+		success.WithContent(openapi3.NewContentWithSchema(nil, []string{op.ResponseType}))
+	case op.Response != "":
+		success.WithContent(openapi3.NewContentWithJSONSchemaRef(apiSchemaRef(op.Response)))
+	}
+	operation.AddResponse(status, success)
+	operation.AddResponse(0, openapi3.NewResponse().WithDescription("Error").
+		WithContent(openapi3.NewContentWithJSONSchemaRef(apiSchemaRef("Error"))))
+	return operation
+}
+
+// apiSchemaRef references the component schema name, []name for an array
+// of it, or a free-form object
+func apiSchemaRef(name string) *openapi3.SchemaRef {
+	if name == "object" {
+		return openapi3.NewObjectSchema().NewRef()
+	}
+	if item, ok := strings.CutPrefix(name, "[]"); ok {
+		schema := openapi3.NewArraySchema()
+		schema.Items = apiSchemaRef(item)
+		return schema.NewRef()
+	}
+	return openapi3.NewSchemaRef("#/components/schemas/"+name, nil)
+}
@@ -0,0 +1,63 @@
+package backend
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenAPIDocument(t *testing.T) {
+	s := newTestServer(t)
+	w := doRequest(s, http.MethodGet, openAPIPath, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	doc, err := openapi3.NewLoader().LoadFromData(w.Body.Bytes())
+	assert.NoError(t, err)
+	assert.NoError(t, doc.Validate(context.Background()))
+
+	create := doc.Paths.Find("/users").Post
+	if assert.NotNil(t, create) {
+		assert.Equal(t, "#/components/schemas/User", create.RequestBody.Value.Content.Get("application/json").Schema.Ref)
+		assert.NotNil(t, create.Responses.Status(http.StatusCreated))
+	}
+	toggle := doc.Paths.Find("/users/{email}/notifications/{type}/{channel}").Patch
+	if assert.NotNil(t, toggle) {
+		assert.NotNil(t, toggle.Parameters.GetByInAndName("path", "channel"))
+	}
+	if v1 := doc.Paths.Find("/v1/users"); assert.NotNil(t, v1) {
+		assert.False(t, v1.Get.Deprecated)
+		assert.Equal(t, create.Summary, v1.Post.Summary)
+	}
+	assert.True(t, create.Deprecated)
+	assert.False(t, doc.Paths.Find("/healthz").Get.Deprecated)
+	user := doc.Components.Schemas["User"].Value
+	assert.Contains(t, user.Properties, "createdAt")
+	assert.NotContains(t, user.Properties, "AvatarPath")
+	assert.Equal(t, "#/components/schemas/Preferences", user.Properties["preferences"].Ref)
+	assert.Contains(t, doc.Components.Schemas, "Notification")
+}
+
+// TestOpenAPICoversRoutes fails when a route is added without documenting
+// it in apiOperations, or an entry outlives its route
+func TestOpenAPICoversRoutes(t *testing.T) {
+	t.Setenv("ENABLE_ADMIN", "true")
+	t.Setenv("ENABLE_DOCS", "true")
+	s := newTestServer(t)
+
+	routes := map[string]bool{}
+	for _, route := range s.router.Routes() {
+		if undocumentedRoute(route.Path) {
+			continue
+		}
+		_, path := versionedRoute(route.Path)
+		key := route.Method + " " + path
+		routes[key] = true
+		assert.Contains(t, apiOperations, key, "route is not documented")
+	}
+	for key := range apiOperations {
+		assert.True(t, routes[key], "documented route %s is not registered", key)
+	}
+}
@@ -0,0 +1,116 @@
+package backend
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+// parsePagination reads the limit and offset query parameters. A missing
+// limit falls back to defaultPageLimit and larger values are capped at
+// maxPageLimit.
+func parsePagination(c *gin.Context) (limit, offset int, err error) {
+	limit = defaultPageLimit
+	if v := c.Query("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			return 0, 0, fmt.Errorf("invalid limit %q", v)
+		}
+	}
+	if limit > maxPageLimit {
+		limit = maxPageLimit
+	}
+
+	if v := c.Query("offset"); v != "" {
+		offset, err = strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			return 0, 0, fmt.Errorf("invalid offset %q", v)
+		}
+	}
+	return limit, offset, nil
+}
+
+// paginate returns the window of items selected by limit and offset
+func paginate[T any](items []T, limit, offset int) []T {
+	if offset >= len(items) {
+		return []T{}
+	}
+	end := min(offset+limit, len(items))
+	return items[offset:end]
+}
+
+// errInvalidCursor is returned for a cursor not issued by userPageAfter
+var errInvalidCursor = errors.New("invalid cursor")
+
+// userPage is a page of users returned with cursor pagination.
+// NextCursor continues after the last user, or is empty on the last page.
+type userPage struct {
+	XMLName    xml.Name `json:"-" xml:"users"`
+	Users      []*User  `json:"users" xml:"user"`
+	NextCursor string   `json:"nextCursor" xml:"nextCursor"`
+}
+
+// userPageAfter returns the first limit users following the user the
+// cursor points to, or the first users for an empty cursor. users must be
+// sorted by sortUsers. The cursor holds the creation time and ID of the
+// last user returned rather than a position, so pages stay consistent
+// while users are created or deleted between requests.
+func userPageAfter(users []*User, cursor string, limit int) (userPage, error) {
+	start := 0
+	if cursor != "" {
+		createdAt, id, err := decodeUserCursor(cursor)
+		if err != nil {
+			return userPage{}, err
+		}
+		start = slices.IndexFunc(users, func(u *User) bool {
+			c := u.CreatedAt.Compare(createdAt)
+			return c > 0 || c == 0 && u.ID > id
+		})
+		if start < 0 {
+			start = len(users)
+		}
+	}
+
+	page := userPage{Users: users[start:min(start+limit, len(users))]}
+	if page.Users == nil {
+		page.Users = []*User{}
+	}
+	if start+limit < len(users) {
+		page.NextCursor = encodeUserCursor(page.Users[len(page.Users)-1])
+	}
+	return page, nil
+}
+
+// encodeUserCursor returns the opaque cursor pointing to u
+func encodeUserCursor(u *User) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(u.CreatedAt.UnixNano(), 10) + "|" + u.ID))
+}
+
+// decodeUserCursor returns the creation time and ID held by a cursor
+func decodeUserCursor(cursor string) (time.Time, string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, "", errInvalidCursor
+	}
+	nanos, id, ok := strings.Cut(string(data), "|")
+	if !ok {
+		return time.Time{}, "", errInvalidCursor
+	}
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return time.Time{}, "", errInvalidCursor
+	}
+	return time.Unix(0, n), id, nil
+}
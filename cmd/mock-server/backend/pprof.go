@@ -0,0 +1,36 @@
+package backend
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// pprofPrefix is where the runtime profiles are served when EnablePprof
+// is set
+const pprofPrefix = "/debug/pprof"
+
+// registerPprof mounts the net/http/pprof handlers under pprofPrefix on the
+// server's router, so they pass through the same middleware as every
+// other route. Named profiles such as heap, goroutine and mutex are served
+// by /debug/pprof/:name.
+func (s *HTTPServer) registerPprof() {
+	g := s.router.Group(pprofPrefix)
+	g.GET("/", gin.WrapF(pprof.Index))
+	g.GET("/cmdline", gin.WrapF(pprof.Cmdline))
+	g.GET("/profile", liftWriteDeadline, gin.WrapF(pprof.Profile))
+	g.GET("/symbol", gin.WrapF(pprof.Symbol))
+	g.POST("/symbol", gin.WrapF(pprof.Symbol))
+	g.GET("/trace", liftWriteDeadline, gin.WrapF(pprof.Trace))
+	g.GET("/:name", liftWriteDeadline, func(c *gin.Context) {
+		pprof.Handler(c.Param("name")).ServeHTTP(c.Writer, c.Request)
+	})
+}
+
+// liftWriteDeadline removes the server's WriteTimeout for profiles that
+// sample for a requested number of seconds, which may well exceed it
+func liftWriteDeadline(c *gin.Context) {
+	_ = http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+}
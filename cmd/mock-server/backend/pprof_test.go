@@ -0,0 +1,31 @@
+package backend
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPprof(t *testing.T) {
+	s := newTestServer(t)
+	assert.Equal(t, http.StatusNotFound, doRequest(s, http.MethodGet, "/debug/pprof/", "").Code)
+
+	t.Setenv("ENABLE_PPROF", "true")
+	s = newTestServer(t)
+	tests := []struct {
+		path       string
+		wantStatus int
+	}{
+		{"/debug/pprof/", http.StatusOK},
+		{"/debug/pprof/cmdline", http.StatusOK},
+		{"/debug/pprof/heap?debug=1", http.StatusOK},
+		{"/debug/pprof/goroutine?debug=1", http.StatusOK},
+		{"/debug/pprof/nope", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			assert.Equal(t, tt.wantStatus, doRequest(s, http.MethodGet, tt.path, "").Code)
+		})
+	}
+}
@@ -0,0 +1,265 @@
+package backend
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Proxy modes
+const (
+	modeLive   = "live"
+	modeRecord = "record"
+	modeReplay = "replay"
+)
+
+const (
+	defaultCassetteDir = "cassettes"
+	upstreamTimeout    = 30 * time.Second
+	redactedValue      = "REDACTED"
+)
+
+// hopHeaders apply to a single connection and are not forwarded, as in
+// net/http/httputil
+var hopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Connection",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// recording is a request and the upstream's response to it, as written to
+// a file in the cassette directory
+type recording struct {
+	RecordedAt time.Time        `json:"recordedAt"`
+	Request    recordedRequest  `json:"request"`
+	Response   recordedResponse `json:"response"`
+}
+
+type recordedRequest struct {
+	Method  string      `json:"method"`
+	URL     string      `json:"url"`
+	Headers http.Header `json:"headers"`
+	recordedBody
+}
+
+type recordedResponse struct {
+	Status  int         `json:"status"`
+	Headers http.Header `json:"headers"`
+	recordedBody
+}
+
+// recordedBody is a body kept as text when it is valid UTF-8 and as
+// base64 otherwise
+type recordedBody struct {
+	Body         string `json:"body"`
+	BodyEncoding string `json:"bodyEncoding,omitempty"`
+}
+
+func newRecordedBody(body []byte) recordedBody {
+	if utf8.Valid(body) {
+		return recordedBody{Body: string(body)}
+	}
+	return recordedBody{Body: base64.StdEncoding.EncodeToString(body), BodyEncoding: "base64"}
+}
+
+func (b recordedBody) bytes() ([]byte, error) {
+	if b.BodyEncoding == "base64" {
+		return base64.StdEncoding.DecodeString(b.Body)
+	}
+	return []byte(b.Body), nil
+}
+
+// handleProxy answers the requests to routes the server doesn't have. In
+// live mode they are forwarded to UpstreamURL. Record mode forwards them
+// too and writes every exchange to CassetteDir, with the RedactHeaders
+// values replaced; replay mode serves the recorded response without
+// contacting the upstream and 404 for requests never recorded. Requests
+// are told apart by method, path, query and body. Responses are marked
+// with X-Mock-Mode.
+func (s *HTTPServer) handleProxy(c *gin.Context) {
+	cfg := s.config()
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		respondValidationError(c, err)
+		return
+	}
+	path := filepath.Join(cfg.CassetteDir, cassetteName(c.Request, body))
+	c.Header("X-Mock-Mode", cfg.Mode)
+
+	if cfg.Mode == modeReplay {
+		s.replay(c, path)
+		return
+	}
+
+	res, err := s.forward(c, cfg.UpstreamURL, body)
+	if err != nil {
+		s.logger.Warn("upstream request failed", zap.String("upstream", cfg.UpstreamURL), zap.Error(err))
+		respondError(c, http.StatusBadGateway, codeUpstream, "upstream request failed")
+		return
+	}
+	if cfg.Mode == modeRecord {
+		recordedURL := *c.Request.URL
+		recordedURL.RawQuery = redactQuery(recordedURL.RawQuery)
+		rec := recording{
+			RecordedAt: s.clock.Now(),
+			Request: recordedRequest{
+				Method:       c.Request.Method,
+				URL:          recordedURL.RequestURI(),
+				Headers:      redactHeaders(c.Request.Header, cfg.RedactHeaders),
+				recordedBody: newRecordedBody(body),
+			},
+			Response: res,
+		}
+		rec.Response.Headers = redactHeaders(res.Headers, cfg.RedactHeaders)
+		if err := writeRecording(path, rec); err != nil {
+			s.logger.Error("failed to record response", zap.String("path", path), zap.Error(err))
+		}
+	}
+	writeRecordedResponse(c, res)
+}
+
+// forward sends the request of c with body to upstream and returns the
+// response
+func (s *HTTPServer) forward(c *gin.Context, upstream string, body []byte) (recordedResponse, error) {
+	target, err := url.Parse(upstream)
+	if err != nil {
+		return recordedResponse{}, err
+	}
+	target = target.JoinPath(c.Request.URL.Path)
+	target.RawQuery = c.Request.URL.RawQuery
+
+	req, err := http.NewRequestWithContext(c.Request.Context(), c.Request.Method, target.String(), bytes.NewReader(body))
+	if err != nil {
+		return recordedResponse{}, err
+	}
+	req.Header = c.Request.Header.Clone()
+	for _, name := range hopHeaders {
+		req.Header.Del(name)
+	}
+	// Leave compression to the transport so recordings hold plain bodies
+	req.Header.Del("Accept-Encoding")
+	req.Header.Set("X-Forwarded-For", c.ClientIP())
+
+	res, err := s.upstream.Do(req)
+	if err != nil {
+		return recordedResponse{}, err
+	}
+	defer res.Body.Close()
+	resBody, err := io.ReadAll(res.Body)
+	if err != nil {
+		return recordedResponse{}, fmt.Errorf("read upstream response: %w", err)
+	}
+
+	headers := res.Header.Clone()
+	for _, name := range hopHeaders {
+		headers.Del(name)
+	}
+	headers.Del("Content-Length")
+	return recordedResponse{Status: res.StatusCode, Headers: headers, recordedBody: newRecordedBody(resBody)}, nil
+}
+
+// replay serves the response recorded in the file at path
+func (s *HTTPServer) replay(c *gin.Context, path string) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		respondError(c, http.StatusNotFound, codeNotFound, fmt.Sprintf("no recording for %s %s", c.Request.Method, c.Request.URL.RequestURI()))
+		return
+	}
+	var rec recording
+	if err == nil {
+		err = json.Unmarshal(data, &rec)
+	}
+	if err != nil {
+		s.logger.Error("failed to read recording", zap.String("path", path), zap.Error(err))
+		respondError(c, http.StatusInternalServerError, codeInternal, "failed to read recording")
+		return
+	}
+	writeRecordedResponse(c, rec.Response)
+}
+
+func writeRecordedResponse(c *gin.Context, res recordedResponse) {
+	body, err := res.bytes()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, codeInternal, "failed to read recording")
+		return
+	}
+	for name, values := range res.Headers {
+		for _, v := range values {
+			c.Writer.Header().Add(name, v)
+		}
+	}
+	c.Status(res.Status)
+	_, _ = c.Writer.Write(body)
+}
+
+// writeRecording writes rec to path, replacing an earlier recording of the
+// same request
+func writeRecording(path string, rec recording) error {
+	data, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// redactHeaders returns a copy of headers with the values of the names
+// listed replaced
+func redactHeaders(headers http.Header, names []string) http.Header {
+	redacted := headers.Clone()
+	for _, name := range names {
+		if values := redacted.Values(name); len(values) > 0 {
+			redacted[http.CanonicalHeaderKey(name)] = []string{redactedValue}
+		}
+	}
+	return redacted
+}
+
+// cassetteName names the recording of r with body: its method and path
+// for readability and a hash of the method, path, normalized query and
+// body telling requests apart
+func cassetteName(r *http.Request, body []byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n%s\n", r.Method, r.URL.Path, r.URL.Query().Encode())
+	h.Write(body)
+	sum := hex.EncodeToString(h.Sum(nil))[:16]
+
+	slug := strings.Trim(strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '.':
+			return r
+		}
+		return '_'
+	}, r.URL.Path), "_")
+	if len(slug) > 64 {
+		slug = slug[:64]
+	}
+	if slug == "" {
+		slug = "root"
+	}
+	return fmt.Sprintf("%s-%s-%s.json", strings.ToLower(r.Method), slug, sum)
+}
@@ -0,0 +1,111 @@
+package backend
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newUpstream(t *testing.T) (*httptest.Server, *int) {
+	calls := 0
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{
+			"method": r.Method,
+			"uri":    r.URL.RequestURI(),
+			"auth":   r.Header.Get("Authorization"),
+			"body":   string(body),
+		})
+	}))
+	t.Cleanup(upstream.Close)
+	return upstream, &calls
+}
+
+func proxyRequest(s *HTTPServer, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer upstream-token")
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+func TestProxyRecordAndReplay(t *testing.T) {
+	upstream, calls := newUpstream(t)
+	dir := t.TempDir()
+	t.Setenv("CASSETTE_DIR", dir)
+	t.Setenv("UPSTREAM_URL", upstream.URL+"/api")
+	t.Setenv("MODE", modeRecord)
+	s := newTestServer(t)
+
+	w := proxyRequest(s, http.MethodPost, "/orders?b=2&a=1", `{"item":"book"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, modeRecord, w.Header().Get("X-Mock-Mode"))
+	assert.JSONEq(t, `{"method":"POST","uri":"/api/orders?b=2&a=1","auth":"Bearer upstream-token","body":"{\"item\":\"book\"}"}`, w.Body.String())
+	assert.Equal(t, http.StatusOK, doRequest(s, http.MethodGet, "/users", "").Code, "built-in routes are not proxied")
+	assert.Equal(t, 1, *calls)
+
+	files, _ := filepath.Glob(filepath.Join(dir, "post-orders-*.json"))
+	if assert.Len(t, files, 1) {
+		data, _ := os.ReadFile(files[0])
+		var rec recording
+		assert.NoError(t, json.Unmarshal(data, &rec))
+		assert.Equal(t, redactedValue, rec.Request.Headers.Get("Authorization"))
+		assert.Equal(t, redactedValue, rec.Response.Headers.Get("Set-Cookie"))
+		assert.Equal(t, "/orders?b=2&a=1", rec.Request.URL)
+	}
+
+	upstream.Close()
+	t.Setenv("MODE", modeReplay)
+	s = newTestServer(t)
+
+	replayed := proxyRequest(s, http.MethodPost, "/orders?a=1&b=2", `{"item":"book"}`)
+	assert.Equal(t, http.StatusCreated, replayed.Code)
+	assert.Equal(t, modeReplay, replayed.Header().Get("X-Mock-Mode"))
+	assert.Equal(t, "application/json", replayed.Header().Get("Content-Type"))
+	assert.Equal(t, w.Body.String(), replayed.Body.String())
+
+	missed := proxyRequest(s, http.MethodPost, "/orders?a=1&b=2", `{"item":"pen"}`)
+	assert.Equal(t, http.StatusNotFound, missed.Code)
+	assert.JSONEq(t, `{"code":"not_found","error":"no recording for POST /orders?a=1&b=2"}`, missed.Body.String())
+}
+
+func TestProxyLive(t *testing.T) {
+	upstream, calls := newUpstream(t)
+	dir := t.TempDir()
+	t.Setenv("CASSETTE_DIR", dir)
+	t.Setenv("UPSTREAM_URL", upstream.URL)
+	s := newTestServer(t)
+
+	w := proxyRequest(s, http.MethodGet, "/inventory", "")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, modeLive, w.Header().Get("X-Mock-Mode"))
+	assert.Equal(t, 1, *calls)
+	files, _ := os.ReadDir(dir)
+	assert.Empty(t, files, "live mode records nothing")
+
+	upstream.Close()
+	assert.Equal(t, http.StatusBadGateway, proxyRequest(s, http.MethodGet, "/inventory", "").Code)
+}
+
+func TestCassetteName(t *testing.T) {
+	name := func(method, target, body string) string {
+		return cassetteName(httptest.NewRequest(method, target, nil), []byte(body))
+	}
+
+	assert.Regexp(t, `^get-users_42_orders-[0-9a-f]{16}\.json$`, name(http.MethodGet, "/users/42/orders", ""))
+	assert.Regexp(t, `^get-root-[0-9a-f]{16}\.json$`, name(http.MethodGet, "/", ""))
+	assert.Equal(t, name(http.MethodGet, "/x?a=1&b=2", ""), name(http.MethodGet, "/x?b=2&a=1", ""))
+	assert.NotEqual(t, name(http.MethodPost, "/x", "a"), name(http.MethodPost, "/x", "b"))
+	assert.NotEqual(t, name(http.MethodGet, "/x", ""), name(http.MethodDelete, "/x", ""))
+}
@@ -0,0 +1,197 @@
+package backend
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+)
+
+// Rate limit keys
+const (
+	rateLimitByIP     = "ip"
+	rateLimitByAPIKey = "api-key"
+)
+
+// RateLimit is a token bucket refilled at RPS tokens per second holding up
+// to Burst tokens. A non-positive RPS disables the limit.
+type RateLimit struct {
+	RPS   float64
+	Burst int
+}
+
+// RateLimitConfig holds the rate limiting settings
+type RateLimitConfig struct {
+	// Default applies to every route without an entry in Routes
+	Default RateLimit
+	// Routes overrides the limit per route, keyed by method and gin route
+	// pattern, e.g. "POST /users" or "GET /users/email/:email"
+	Routes map[string]RateLimit
+	// KeyBy selects what requests are counted by: "ip" or "api-key". The
+	// api-key mode uses the identity of the matched API key, or the raw
+	// X-API-Key header when keys aren't checked, and falls back to the
+	// client IP.
+	KeyBy string
+	// IdleTTL is how long the bucket of a key that sends no requests is kept
+	IdleTTL time.Duration
+}
+
+// parseRateLimitRoutes parses a comma separated list of
+// "METHOD /route=rps[:burst]" items. A missing burst defaults to rps
+// rounded up. Malformed items are dropped.
+func parseRateLimitRoutes(v string) map[string]RateLimit {
+	routes := map[string]RateLimit{}
+	for _, item := range splitList(v) {
+		route, spec, ok := strings.Cut(item, "=")
+		if !ok {
+			continue
+		}
+		rpsText, burstText, hasBurst := strings.Cut(spec, ":")
+		rps, err := strconv.ParseFloat(strings.TrimSpace(rpsText), 64)
+		if err != nil {
+			continue
+		}
+		limit := RateLimit{RPS: rps, Burst: defaultBurst(rps)}
+		if hasBurst {
+			if limit.Burst, err = strconv.Atoi(strings.TrimSpace(burstText)); err != nil {
+				continue
+			}
+		}
+		routes[strings.Join(strings.Fields(route), " ")] = limit
+	}
+	return routes
+}
+
+// defaultBurst returns the burst used when only a rate is configured
+func defaultBurst(rps float64) int {
+	return max(1, int(math.Ceil(rps)))
+}
+
+// rateLimiter keeps a token bucket per key. Buckets idle for longer than
+// the TTL are removed by a background sweeper until Close is called.
+type rateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*rateBucket
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+type rateBucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+func newRateLimiter(idleTTL time.Duration) *rateLimiter {
+	l := &rateLimiter{
+		buckets: make(map[string]*rateBucket),
+		done:    make(chan struct{}),
+	}
+	go l.sweepLoop(idleTTL)
+	return l
+}
+
+// Allow takes a token from the bucket of key, creating it with limit when
+// needed. When the bucket is empty it returns false and how long until a
+// token is available. Buckets pick up a changed limit on their next use.
+func (l *rateLimiter) Allow(key string, limit RateLimit, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &rateBucket{limiter: rate.NewLimiter(rate.Limit(limit.RPS), limit.Burst)}
+		l.buckets[key] = bucket
+	}
+	if bucket.limiter.Limit() != rate.Limit(limit.RPS) {
+		bucket.limiter.SetLimitAt(now, rate.Limit(limit.RPS))
+	}
+	if bucket.limiter.Burst() != limit.Burst {
+		bucket.limiter.SetBurstAt(now, limit.Burst)
+	}
+	bucket.lastSeen = now
+
+	r := bucket.limiter.ReserveN(now, 1)
+	if !r.OK() {
+		// The burst is zero, so no request may ever pass
+		return false, time.Second
+	}
+	if delay := r.DelayFrom(now); delay > 0 {
+		r.CancelAt(now)
+		return false, delay
+	}
+	return true, 0
+}
+
+// Close stops the background sweeper
+func (l *rateLimiter) Close() {
+	l.closeOnce.Do(func() { close(l.done) })
+}
+
+func (l *rateLimiter) sweepLoop(idleTTL time.Duration) {
+	if idleTTL <= 0 {
+		return
+	}
+	ticker := time.NewTicker(min(idleTTL, time.Minute))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.done:
+			return
+		case now := <-ticker.C:
+			l.sweep(now.Add(-idleTTL))
+		}
+	}
+}
+
+// sweep removes the buckets last used before cutoff
+func (l *rateLimiter) sweep(cutoff time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for key, bucket := range l.buckets {
+		if bucket.lastSeen.Before(cutoff) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// rateLimitMiddleware rejects requests over the configured rate with 429
+// and a Retry-After header holding the seconds until the next token. Each
+// route with its own limit has separate buckets.
+func (s *HTTPServer) rateLimitMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg := s.config().RateLimit
+		route := routeKey(c)
+		limit, ok := cfg.Routes[route]
+		if !ok {
+			limit, route = cfg.Default, ""
+		}
+		if limit.RPS <= 0 {
+			c.Next()
+			return
+		}
+
+		key := c.ClientIP()
+		if cfg.KeyBy == rateLimitByAPIKey {
+			if identity := c.GetString(apiKeyIdentityKey); identity != "" {
+				key = "identity:" + identity
+			} else if apiKey := c.GetHeader(apiKeyHeader); apiKey != "" {
+				key = "key:" + apiKey
+			}
+		}
+		allowed, retryAfter := s.rateLimiter.Allow(route+"|"+key, limit, time.Now())
+		if !allowed {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			respondError(c, http.StatusTooManyRequests, codeRateLimited, "rate limit exceeded")
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,38 @@
+package backend
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimit(t *testing.T) {
+	t.Setenv("RATE_LIMIT_RPS", "0.5")
+	t.Setenv("RATE_LIMIT_BURST", "2")
+	t.Setenv("RATE_LIMIT_ROUTES", "GET /users/email/:email=0.1:1")
+	s := newTestServer(t)
+
+	for i := 0; i < 2; i++ {
+		w := doRequest(s, http.MethodGet, "/users/count", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+	w := doRequest(s, http.MethodGet, "/users/count", "")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "2", w.Header().Get("Retry-After"))
+
+	// The route with its own limit has a separate bucket
+	w = doRequest(s, http.MethodGet, "/users/email/alice@example.com", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = doRequest(s, http.MethodGet, "/users/email/bob@example.com", "")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "10", w.Header().Get("Retry-After"))
+}
+
+func TestParseRateLimitRoutes(t *testing.T) {
+	routes := parseRateLimitRoutes("POST  /users=5:10, GET /weather=2.5, bogus, GET /x=abc")
+	assert.Equal(t, map[string]RateLimit{
+		"POST /users":  {RPS: 5, Burst: 10},
+		"GET /weather": {RPS: 2.5, Burst: 3},
+	}, routes)
+}
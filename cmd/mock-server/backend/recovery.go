@@ -0,0 +1,40 @@
+package backend
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// recoveryMiddleware turns panics in handlers into a JSON 500 response
+// carrying only the request ID, so clients never see the panic value or
+// stack. Both are logged instead. http.ErrAbortHandler is re-raised so
+// net/http can abort the response as intended.
+func (s *HTTPServer) recoveryMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			r := recover()
+			if r == nil {
+				return
+			}
+			if r == http.ErrAbortHandler {
+				panic(r)
+			}
+
+			s.requestLogger(c).Error("panic recovered", zap.Any("panic", r), zap.Stack("stack"))
+			if c.Writer.Written() {
+				// Too late for a clean error response
+				c.Abort()
+				return
+			}
+			c.Abort()
+			respond(c, http.StatusInternalServerError, APIError{
+				Error:   "internal server error",
+				Code:    codeInternal,
+				Details: ErrorDetails{"requestId": c.GetString(requestIDKey)},
+			})
+		}()
+		c.Next()
+	}
+}
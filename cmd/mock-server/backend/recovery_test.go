@@ -0,0 +1,27 @@
+package backend
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecoveryReturnsJSON(t *testing.T) {
+	s := newTestServer(t)
+	s.router.GET("/test/panic", func(*gin.Context) {
+		panic("secret database password")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/test/panic", nil)
+	req.Header.Set(requestIDHeader, "req-42")
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"error":"internal server error","code":"internal","details":{"requestId":"req-42"}}`, w.Body.String())
+	assert.NotContains(t, w.Body.String(), "secret")
+}
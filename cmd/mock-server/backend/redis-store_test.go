@@ -0,0 +1,116 @@
+package backend
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestRedisStore connects to REDIS_URL, or a local server by default,
+// and skips the test when Redis is not available. Keys are prefixed with
+// the test name and removed afterwards.
+func newTestRedisStore(t *testing.T) *redisStore {
+	t.Helper()
+	url := os.Getenv("REDIS_URL")
+	if url == "" {
+		url = defaultRedisURL
+	}
+	store, err := newRedisStore(url, "mock-server-test:"+t.Name()+":")
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	if err := store.Ping(ctx); err != nil {
+		store.Close()
+		t.Skipf("redis not available at %s: %v", url, err)
+	}
+	t.Cleanup(func() {
+		ctx := context.Background()
+		keys, _ := store.client.Keys(ctx, store.prefix+"*").Result()
+		if len(keys) > 0 {
+			store.client.Del(ctx, keys...)
+		}
+		store.Close()
+	})
+	return store
+}
+
+func TestRedisStore(t *testing.T) {
+	store := newTestRedisStore(t)
+	ctx := context.Background()
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	alice := &User{ID: "1", Username: "alice", Email: "alice@example.com", CreatedAt: created, AvatarPath: "/tmp/1.png",
+		Preferences: Preferences{Theme: "dark", Tags: []string{"beta"}}}
+	assert.NoError(t, store.Create(ctx, alice))
+	assert.ErrorIs(t, store.Create(ctx, alice), errUserExists)
+	assert.Equal(t, []error{nil, errUserExists}, store.CreateMany(ctx, []*User{
+		{ID: "2", Username: "bob", Email: "bob@example.com", CreatedAt: created.Add(time.Second)},
+		{ID: "3", Email: "ALICE@example.com"},
+	}))
+
+	got, err := store.Get(ctx, "Alice@Example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, alice, got)
+	got, err = store.GetByID(ctx, "2")
+	assert.NoError(t, err)
+	assert.Equal(t, "bob", got.Username)
+
+	updated, err := store.Update(ctx, "bob@example.com", func(u *User) error {
+		u.DeletedAt = &created
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.NotNil(t, updated.DeletedAt)
+	active, deleted, err := store.Count(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, active)
+	assert.Equal(t, 1, deleted)
+
+	list, err := store.List(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, list, 2) {
+		assert.Equal(t, "1", list[0].ID)
+		assert.Equal(t, "2", list[1].ID)
+	}
+
+	_, err = store.Delete(ctx, "bob@example.com")
+	assert.NoError(t, err)
+	_, err = store.GetByID(ctx, "2")
+	assert.ErrorIs(t, err, errUserNotFound)
+	_, err = store.Update(ctx, "bob@example.com", func(*User) error { return nil })
+	assert.ErrorIs(t, err, errUserNotFound)
+	removed, err := store.Replace(ctx, []*User{{ID: "4", Email: "carol@example.com"}})
+	assert.NoError(t, err)
+	if assert.Len(t, removed, 1) {
+		assert.Equal(t, alice, removed[0])
+	}
+	list, err = store.List(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, list, 1) {
+		assert.Equal(t, "carol@example.com", list[0].Email)
+	}
+	_, err = store.GetByID(ctx, "1")
+	assert.ErrorIs(t, err, errUserNotFound)
+
+	testScenarioStore(t, store)
+}
+
+func TestRedisStoreUnavailable(t *testing.T) {
+	t.Setenv("STORE_BACKEND", "redis")
+	t.Setenv("REDIS_URL", "redis://127.0.0.1:1/0")
+	s := newTestServer(t)
+	defer s.Stop()
+
+	w := doRequest(s, http.MethodGet, "/users/email/alice@example.com", "")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.JSONEq(t, `{"code":"unavailable","error":"user store unavailable"}`, w.Body.String())
+
+	w = doRequest(s, http.MethodGet, "/readyz", "")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), `"store":"unavailable"`)
+}
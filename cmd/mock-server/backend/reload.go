@@ -0,0 +1,121 @@
+package backend
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+// config returns the current configuration
+func (s *HTTPServer) config() Config {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cfg
+}
+
+// weatherProvider returns the current weather provider
+func (s *HTTPServer) weatherProvider() WeatherProvider {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.weather
+}
+
+// Reload re-reads the .env file, the configuration file and the
+// environment and applies the settings that are safe to change while
+// serving. As at startup, real environment variables win over the .env
+// file. The server sends itself a Reload on SIGHUP.
+//
+// Hot-reloadable settings:
+//
+//	DEFAULT_*, THEMES, MAX_TAGS, MAX_SETTINGS_*   apply to the next request
+//	WEATHER_*, WEATHER_API_KEY                    rebuild the weather provider,
+//	                                              clear the weather cache and
+//	                                              reset the circuit breaker
+//	SHUTDOWN_TIMEOUT, SHUTDOWN_DRAIN_DELAY        apply to the next Stop
+//	CORS_*                                        apply to the next request
+//	RATE_LIMIT_* except RATE_LIMIT_IDLE_TTL       apply to the next request
+//	DELAY_ROUTES, MAX_DELAY                       apply to the next request
+//	CHAOS_ERROR_RATE, CHAOS_EXEMPT                apply to the next request
+//	MOCK_HEADERS                                  applies to the next request
+//	files in STUB_DIR                             reread
+//	LOG_LEVEL                                     replaces the level set
+//	                                              through /debug/loglevel
+//	ACCESS_LOG, ACCESS_LOG_LEVEL                  apply to the next request
+//	BASIC_AUTH_*                                  apply to the next request
+//	API_KEYS, API_KEYS_*                          reread, apply to the next
+//	                                              request
+//	JWT_*                                         apply to the next request
+//	MAX_BODY_SIZE                                 applies to the next request
+//	GZIP, GZIP_MIN_SIZE                           apply to the next request
+//
+// Every other setting, such as LISTEN_NETWORK, the server timeouts, TLS_*,
+// LOG_FORMAT, AVATAR_DIR, MAX_AVATAR_SIZE, AUDIT_LOG_SIZE, ENABLE_PPROF and
+// ENABLE_DOCS, requires a restart and is left unchanged. Settings from the
+// configuration file follow the same rules as their environment variables.
+// If the new configuration is invalid, see Config.Validate, or the API keys
+// file or a stub file can't be read, nothing is applied and the error is
+// returned.
+func (s *HTTPServer) Reload() error {
+	if err := loadDotEnv(); err != nil {
+		s.logger.Debug("no .env file reloaded", zap.Error(err))
+	}
+	next, err := loadConfig(s.config().ConfigFile)
+	if err != nil {
+		return err
+	}
+	if err := next.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+	if err := next.APIKeys.load(); err != nil {
+		return err
+	}
+	weather, err := newWeatherProvider(next, s.logger)
+	if err != nil {
+		return err
+	}
+	if err := s.stubs.Reload(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	cfg := s.cfg
+	cfg.DefaultPreferences = next.DefaultPreferences
+	cfg.Themes = next.Themes
+	cfg.MaxTags = next.MaxTags
+	cfg.SettingsLimits = next.SettingsLimits
+	cfg.WeatherProvider = next.WeatherProvider
+	cfg.WeatherRequireKey = next.WeatherRequireKey
+	cfg.WeatherTimeout = next.WeatherTimeout
+	cfg.WeatherRetries = next.WeatherRetries
+	cfg.WeatherCacheTTL = next.WeatherCacheTTL
+	cfg.WeatherBreakerThreshold = next.WeatherBreakerThreshold
+	cfg.WeatherBreakerCooldown = next.WeatherBreakerCooldown
+	cfg.ShutdownTimeout = next.ShutdownTimeout
+	cfg.ShutdownDrainDelay = next.ShutdownDrainDelay
+	cfg.CORS = next.CORS
+	cfg.RateLimit.Default = next.RateLimit.Default
+	cfg.RateLimit.Routes = next.RateLimit.Routes
+	cfg.RateLimit.KeyBy = next.RateLimit.KeyBy
+	cfg.Delay = next.Delay
+	cfg.Chaos.ErrorRate = next.Chaos.ErrorRate
+	cfg.Chaos.ExemptPaths = next.Chaos.ExemptPaths
+	cfg.MockHeaders = next.MockHeaders
+	cfg.AccessLog = next.AccessLog
+	cfg.LogLevel = next.LogLevel
+	cfg.AccessLogLevel = next.AccessLogLevel
+	cfg.BasicAuth = next.BasicAuth
+	cfg.APIKeys = next.APIKeys
+	cfg.JWT = next.JWT
+	cfg.MaxBodySize = next.MaxBodySize
+	cfg.Gzip = next.Gzip
+	cfg.GzipMinSize = next.GzipMinSize
+	s.cfg = cfg
+	s.weather = weather
+	s.mu.Unlock()
+
+	s.weatherCache.Reset(cfg.WeatherCacheTTL)
+	s.weatherBreaker.Configure(cfg.WeatherBreakerThreshold, cfg.WeatherBreakerCooldown)
+	s.logLevel.SetLevel(cfg.LogLevel)
+	s.logger.Info("configuration reloaded")
+	return nil
+}
@@ -0,0 +1,57 @@
+package backend
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	requestIDHeader = "X-Request-ID"
+	// requestIDKey is the context key holding the request ID
+	requestIDKey = "requestID"
+
+	maxRequestIDLength = 128
+)
+
+// requestIDMiddleware tags every request with an ID taken from the
+// X-Request-ID header, or a new UUID when the header is missing or
+// unusable. The ID is stored in the context and echoed in the response.
+func requestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(requestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		}
+		c.Set(requestIDKey, id)
+		c.Header(requestIDHeader, id)
+		c.Next()
+	}
+}
+
+// validRequestID reports whether id is a non-empty string of at most
+// maxRequestIDLength printable ASCII characters, so it is safe to log
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// requestLogger returns the server logger annotated with the ID of the
+// request being handled and the identity of its API key, if any
+func (s *HTTPServer) requestLogger(c *gin.Context) *zap.Logger {
+	var fields []zap.Field
+	if id := c.GetString(requestIDKey); id != "" {
+		fields = append(fields, zap.String("requestId", id))
+	}
+	if identity := c.GetString(apiKeyIdentityKey); identity != "" {
+		fields = append(fields, zap.String("apiKey", identity))
+	}
+	return s.logger.With(fields...)
+}
@@ -0,0 +1,44 @@
+package backend
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestID(t *testing.T) {
+	s := newTestServer(t)
+
+	tests := []struct {
+		name   string
+		header string
+		want   string
+	}{
+		{"provided", "gateway-123", "gateway-123"},
+		{"absent", "", ""},
+		{"too long", strings.Repeat("x", maxRequestIDLength+1), ""},
+		{"not printable", "id with spaces", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/users/count", nil)
+			if tt.header != "" {
+				req.Header.Set(requestIDHeader, tt.header)
+			}
+			w := httptest.NewRecorder()
+			s.router.ServeHTTP(w, req)
+
+			got := w.Header().Get(requestIDHeader)
+			if tt.want != "" {
+				assert.Equal(t, tt.want, got)
+				return
+			}
+			_, err := uuid.Parse(got)
+			assert.NoError(t, err, "expected a generated UUID, got %q", got)
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package backend
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiVersions lists the versions of the API, oldest first. Each is served
+// under its own prefix, e.g. /v1/users.
+var apiVersions = []string{"v1"}
+
+// registerRoutes registers every route of the server. The API resources
+// are registered once per version in apiVersions and once more without a
+// prefix; the unprefixed paths are deprecated aliases of /v1, kept for one
+// release and answered with a Deprecation header. Operational routes such
+// as /healthz, /metrics and /admin are not versioned.
+func (s *HTTPServer) registerRoutes(cfg Config) {
+	s.registerV1(s.router.Group("/v1", strictAcceptMiddleware()))
+	s.registerV1(s.router.Group("", deprecatedRoute("/v1"), strictAcceptMiddleware()))
+
+	s.router.GET("/metrics", s.handleMetrics)
+	s.router.GET("/healthz", s.handleHealthz)
+	s.router.GET("/readyz", s.handleReadyz)
+	s.router.GET(openAPIPath, s.handleOpenAPI)
+	s.router.GET("/debug/loglevel", s.handleLogLevel)
+	if cfg.EnablePprof {
+		s.registerPprof()
+	}
+	if cfg.EnableAdmin {
+		s.registerAdmin()
+	}
+	if cfg.EnableDocs {
+		s.registerDocs()
+	}
+	if cfg.Mode != modeLive || cfg.UpstreamURL != "" {
+		s.router.NoRoute(s.handleProxy)
+	} else {
+		s.router.NoRoute(handleNoRoute)
+	}
+}
+
+// registerV1 registers the resources of version 1 of the API on r. A
+// later version gets its own registerV2, reusing the handlers that didn't
+// change.
+func (s *HTTPServer) registerV1(r gin.IRoutes) {
+	r.GET("/users", s.handleListUsers)
+	r.POST("/users", s.handleCreateUser)
+	r.GET("/users.csv", s.handleExportUsersCSV)
+	r.POST("/users/bulk", s.handleBulkCreateUsers)
+	r.POST("/users/import", s.handleImportUsersCSV)
+	r.GET("/users/count", s.handleCountUsers)
+	r.GET("/users/search", s.handleSearchUsers)
+	r.GET("/users/email/:email", s.handleGetUser)
+	r.GET("/users/id/:id", s.handleGetUserByID)
+	r.PATCH("/users/:email", s.handlePatchUser)
+	r.DELETE("/users/:email", s.handleDeleteUser)
+	r.POST("/users/:email/restore", s.handleRestoreUser)
+	r.PUT("/users/:email/preferences", s.handleUpdatePreferences)
+	r.POST("/users/:email/preferences/reset", s.handleResetPreferences)
+	r.GET("/users/:email/notifications", s.handleListNotifications)
+	r.POST("/users/:email/notifications", s.handleAddNotification)
+	r.PUT("/users/:email/notifications", s.handleUpdateNotifications)
+	r.PATCH("/users/:email/notifications/:type/:channel", s.handleToggleNotification)
+	r.DELETE("/users/:email/notifications/:type/:channel", s.handleDeleteNotification)
+	r.GET("/users/:email/avatar", s.handleGetAvatar)
+	r.POST("/users/:email/avatar", s.handleUpdateAvatar)
+	r.DELETE("/users/:email/avatar", s.handleDeleteAvatar)
+	r.GET("/weather", s.handleWeather)
+	r.GET("/weather/forecast", s.handleWeatherForecast)
+	r.GET("/weather/batch", s.handleWeatherBatch)
+	r.GET("/events", s.handleEvents)
+	r.GET("/ws", s.handleWebSocket)
+	r.GET("/audit", s.handleListAudit)
+}
+
+// registerAdmin registers the admin routes, which can read and replace the
+// whole store, and the log level change. They are only served with
+// EnableAdmin.
+func (s *HTTPServer) registerAdmin() {
+	s.router.GET(adminPrefix+"/snapshot", s.handleExportSnapshot)
+	s.router.POST(adminPrefix+"/snapshot", s.handleImportSnapshot)
+	s.router.GET(adminPrefix+"/scenarios", s.handleListScenarios)
+	s.router.POST(adminPrefix+"/scenarios/reset", s.handleResetScenarios)
+	s.router.POST(adminPrefix+"/reset", s.handleReset)
+	s.router.PUT("/debug/loglevel", s.handleLogLevel)
+}
+
+// deprecatedRoute marks the responses of a deprecated alias with the
+// Deprecation header and links the same path under successor, the prefix
+// of the version replacing it
+func deprecatedRoute(successor string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Deprecation", "true")
+		c.Header("Link", "<"+successor+c.Request.URL.Path+`>; rel="successor-version"`)
+		c.Next()
+	}
+}
+
+// versionedRoute splits the API version prefix off a route pattern,
+// returning the version, empty for unversioned routes, and the pattern
+// without it
+func versionedRoute(route string) (version, rest string) {
+	for _, v := range apiVersions {
+		if rest, ok := strings.CutPrefix(route, "/"+v+"/"); ok {
+			return v, "/" + rest
+		}
+	}
+	return "", route
+}
+
+// routeKey names the route of a request by method and pattern without
+// the API version, so that settings keyed by route such as DELAY_ROUTES
+// and RATE_LIMIT_ROUTES apply to every version and to the deprecated
+// aliases alike
+func routeKey(c *gin.Context) string {
+	_, route := versionedRoute(c.FullPath())
+	return c.Request.Method + " " + route
+}
@@ -0,0 +1,55 @@
+package backend
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVersionedRoutes(t *testing.T) {
+	s := newTestServer(t)
+
+	body := `{"username":"alice","email":"alice@example.com"}`
+	w := doRequest(s, http.MethodPost, "/v1/users", body)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Empty(t, w.Header().Get("Deprecation"))
+
+	w = doRequest(s, http.MethodGet, "/users/email/alice@example.com", "")
+	assert.Equal(t, http.StatusOK, w.Code, "the alias serves the same store")
+	assert.Equal(t, "true", w.Header().Get("Deprecation"))
+	assert.Equal(t, `</v1/users/email/alice@example.com>; rel="successor-version"`, w.Header().Get("Link"))
+
+	w = doRequest(s, http.MethodGet, "/healthz", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Deprecation"))
+	assert.Equal(t, http.StatusNotFound, doRequest(s, http.MethodGet, "/v1/healthz", "").Code)
+}
+
+func TestRouteSettingsApplyToVersions(t *testing.T) {
+	t.Setenv("DELAY_ROUTES", "GET /users/count=50ms")
+	s := newTestServer(t)
+
+	for _, path := range []string{"/users/count", "/v1/users/count"} {
+		start := time.Now()
+		assert.Equal(t, http.StatusOK, doRequest(s, http.MethodGet, path, "").Code)
+		assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond, path)
+	}
+}
+
+func TestVersionedRoute(t *testing.T) {
+	tests := []struct {
+		route, version, rest string
+	}{
+		{"/v1/users/:email", "v1", "/users/:email"},
+		{"/users/:email", "", "/users/:email"},
+		{"/v1", "", "/v1"},
+		{"/healthz", "", "/healthz"},
+	}
+	for _, tt := range tests {
+		version, rest := versionedRoute(tt.route)
+		assert.Equal(t, tt.version, version, tt.route)
+		assert.Equal(t, tt.rest, rest, tt.route)
+	}
+}
@@ -0,0 +1,40 @@
+package backend
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// scenarioStarted is the state every stub scenario begins in
+const scenarioStarted = "Started"
+
+// handleListScenarios reports the state of every scenario that left
+// scenarioStarted
+func (s *HTTPServer) handleListScenarios(c *gin.Context) {
+	scenarios, err := s.users.Scenarios(c.Request.Context())
+	if err != nil {
+		s.respondStoreError(c, err)
+		return
+	}
+	respond(c, http.StatusOK, gin.H{"scenarios": scenarios})
+}
+
+// handleResetScenarios returns the scenario named by ?name=, or every
+// scenario without it, to scenarioStarted and reports the states left
+func (s *HTTPServer) handleResetScenarios(c *gin.Context) {
+	ctx := c.Request.Context()
+	var err error
+	if name := c.Query("name"); name != "" {
+		err = s.users.SetScenarioState(ctx, name, scenarioStarted)
+	} else {
+		err = s.users.ResetScenarios(ctx)
+	}
+	if err != nil {
+		s.respondStoreError(c, err)
+		return
+	}
+	s.requestLogger(c).Info("reset scenarios", zap.String("name", c.Query("name")))
+	s.handleListScenarios(c)
+}
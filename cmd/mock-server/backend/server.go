@@ -0,0 +1,14 @@
+package backend
+
+// Server is a transport serving the mock backend
+type Server interface {
+	// Start binds addr and begins serving in the background
+	Start(addr string) error
+	// Stop shuts the server down, waiting for in-flight requests
+	Stop() error
+}
+
+var (
+	_ Server = (*HTTPServer)(nil)
+	_ Server = (*GRPCServer)(nil)
+)
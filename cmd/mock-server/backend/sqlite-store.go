@@ -0,0 +1,425 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// defaultSQLitePath is the database file of the sqlite store backend
+const defaultSQLitePath = "mock-server.db"
+
+// sqliteUser is the row of a user in the sqlite store. The slices and
+// maps of the preferences are stored as JSON columns.
+type sqliteUser struct {
+	Email         string     `gorm:"column:email; primaryKey"`
+	ID            string     `gorm:"column:id; uniqueIndex"`
+	Username      string     `gorm:"column:username"`
+	CreatedAt     time.Time  `gorm:"column:created_at; autoCreateTime:false; index"`
+	UpdatedAt     time.Time  `gorm:"column:updated_at; autoUpdateTime:false"`
+	Version       int        `gorm:"column:version"`
+	DeletedAt     *time.Time `gorm:"column:deleted_at"`
+	AvatarURL     string     `gorm:"column:avatar_url"`
+	AvatarPath    string     `gorm:"column:avatar_path"`
+	IsPublic      bool       `gorm:"column:is_public"`
+	ShowEmail     bool       `gorm:"column:show_email"`
+	Theme         string     `gorm:"column:theme"`
+	Tags          string     `gorm:"column:tags; type:text"`
+	Settings      string     `gorm:"column:settings; type:text"`
+	Notifications string     `gorm:"column:notifications; type:text"`
+}
+
+func (sqliteUser) TableName() string {
+	return "users"
+}
+
+// fromUser converts a user into its row. The creation time is stored in
+// UTC so the column sorts like the times it holds.
+func fromUser(user *User) (*sqliteUser, error) {
+	row := &sqliteUser{
+		Email:      normalizeEmail(user.Email),
+		ID:         user.ID,
+		Username:   user.Username,
+		CreatedAt:  user.CreatedAt.UTC(),
+		UpdatedAt:  user.UpdatedAt,
+		Version:    user.Version,
+		DeletedAt:  user.DeletedAt,
+		AvatarURL:  user.AvatarURL,
+		AvatarPath: user.AvatarPath,
+		IsPublic:   user.Preferences.IsPublic,
+		ShowEmail:  user.Preferences.ShowEmail,
+		Theme:      user.Preferences.Theme,
+	}
+	var err error
+	if row.Tags, err = marshalColumn(user.Preferences.Tags); err != nil {
+		return nil, err
+	}
+	if row.Settings, err = marshalColumn(user.Preferences.Settings); err != nil {
+		return nil, err
+	}
+	if row.Notifications, err = marshalColumn(user.Preferences.Notifications); err != nil {
+		return nil, err
+	}
+	return row, nil
+}
+
+// toUser converts the row back into a user
+func (r *sqliteUser) toUser() (*User, error) {
+	user := &User{
+		ID:         r.ID,
+		Username:   r.Username,
+		Email:      r.Email,
+		CreatedAt:  r.CreatedAt,
+		UpdatedAt:  r.UpdatedAt,
+		Version:    r.Version,
+		DeletedAt:  r.DeletedAt,
+		AvatarURL:  r.AvatarURL,
+		AvatarPath: r.AvatarPath,
+		Preferences: Preferences{
+			IsPublic:  r.IsPublic,
+			ShowEmail: r.ShowEmail,
+			Theme:     r.Theme,
+		},
+	}
+	err := errors.Join(
+		unmarshalColumn(r.Tags, &user.Preferences.Tags),
+		unmarshalColumn(r.Settings, &user.Preferences.Settings),
+		unmarshalColumn(r.Notifications, &user.Preferences.Notifications),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("decode user %s: %w", r.Email, err)
+	}
+	return user, nil
+}
+
+// marshalColumn encodes v for a JSON column
+func marshalColumn(v any) (string, error) {
+	data, err := json.Marshal(v)
+	return string(data), err
+}
+
+// unmarshalColumn decodes a JSON column into v, leaving v alone when the
+// column is empty
+func unmarshalColumn(column string, v any) error {
+	if column == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(column), v)
+}
+
+// sqliteStore is a Store keeping users in a SQLite database file, so they
+// survive restarts. Writes that read before they write run in a
+// transaction.
+type sqliteStore struct {
+	db *gorm.DB
+}
+
+var _ Store = (*sqliteStore)(nil)
+
+// NewSQLiteStore opens the SQLite database at path, creating the file and
+// the users table when they don't exist yet
+func NewSQLiteStore(path string) (Store, error) {
+	return newSQLiteStore(path)
+}
+
+func newSQLiteStore(path string) (*sqliteStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create database directory: %w", err)
+	}
+	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{Logger: gormlogger.Discard})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	// A single connection serializes the writers, which SQLite would
+	// otherwise reject with "database is locked"
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	sqlDB.SetMaxOpenConns(1)
+
+	if err := db.AutoMigrate(&sqliteUser{}, &sqliteScenario{}); err != nil {
+		_ = sqlDB.Close()
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
+	}
+	return &sqliteStore{db: db}, nil
+}
+
+// Close closes the database
+func (s *sqliteStore) Close() error {
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
+// Get implements Store.Get
+func (s *sqliteStore) Get(ctx context.Context, email string) (*User, error) {
+	return s.first(s.db.WithContext(ctx), "email = ?", normalizeEmail(email))
+}
+
+// GetByID implements Store.GetByID
+func (s *sqliteStore) GetByID(ctx context.Context, id string) (*User, error) {
+	return s.first(s.db.WithContext(ctx), "id = ?", id)
+}
+
+// Put implements Store.Put
+func (s *sqliteStore) Put(ctx context.Context, user *User) error {
+	row, err := fromUser(user)
+	if err != nil {
+		return err
+	}
+	return s.db.WithContext(ctx).Save(row).Error
+}
+
+// Create implements Store.Create
+func (s *sqliteStore) Create(ctx context.Context, user *User) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return create(tx, user)
+	})
+}
+
+// CreateMany implements Store.CreateMany. All users are created in one
+// transaction; if it fails as a whole, every user gets its error.
+func (s *sqliteStore) CreateMany(ctx context.Context, users []*User) []error {
+	errs := make([]error, len(users))
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for i, user := range users {
+			if err := create(tx, user); errors.Is(err, errUserExists) {
+				errs[i] = err
+			} else if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		for i := range errs {
+			errs[i] = err
+		}
+	}
+	return errs
+}
+
+// Update implements Store.Update, reading and writing the user in one
+// transaction
+func (s *sqliteStore) Update(ctx context.Context, email string, fn func(*User) error) (*User, error) {
+	var updated *User
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		user, err := s.first(tx, "email = ?", normalizeEmail(email))
+		if err != nil {
+			return err
+		}
+		if err := fn(user); err != nil {
+			return err
+		}
+		row, err := fromUser(user)
+		if err != nil {
+			return err
+		}
+		if err := tx.Save(row).Error; err != nil {
+			return err
+		}
+		updated = user
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// Delete implements Store.Delete
+func (s *sqliteStore) Delete(ctx context.Context, email string) (*User, error) {
+	var deleted *User
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		user, err := s.first(tx, "email = ?", normalizeEmail(email))
+		if err != nil {
+			return err
+		}
+		if err := tx.Delete(&sqliteUser{}, "email = ?", user.Email).Error; err != nil {
+			return err
+		}
+		deleted = user
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return deleted, nil
+}
+
+// List implements Store.List
+func (s *sqliteStore) List(ctx context.Context) ([]*User, error) {
+	var rows []*sqliteUser
+	if err := s.db.WithContext(ctx).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	users := make([]*User, 0, len(rows))
+	for _, row := range rows {
+		user, err := row.toUser()
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	sortUsers(users)
+	return users, nil
+}
+
+// ListAfter implements userPager.ListAfter
+func (s *sqliteStore) ListAfter(ctx context.Context, after *User, limit int) ([]*User, error) {
+	query := s.db.WithContext(ctx).Order("created_at, id").Limit(limit)
+	if after != nil {
+		createdAt := after.CreatedAt.UTC()
+		query = query.Where("created_at > ? OR (created_at = ? AND id > ?)", createdAt, createdAt, after.ID)
+	}
+	var rows []*sqliteUser
+	if err := query.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	users := make([]*User, 0, len(rows))
+	for _, row := range rows {
+		user, err := row.toUser()
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+// Count implements Store.Count
+func (s *sqliteStore) Count(ctx context.Context) (active, deleted int, err error) {
+	var counts struct {
+		Active  int
+		Deleted int
+	}
+	err = s.db.WithContext(ctx).Model(&sqliteUser{}).
+		Select("COUNT(*) - COUNT(deleted_at) AS active, COUNT(deleted_at) AS deleted").
+		Scan(&counts).Error
+	return counts.Active, counts.Deleted, err
+}
+
+// Replace implements Store.Replace in one transaction
+func (s *sqliteStore) Replace(ctx context.Context, users []*User) ([]*User, error) {
+	var removed []*User
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var rows []*sqliteUser
+		if err := tx.Find(&rows).Error; err != nil {
+			return err
+		}
+		removed = make([]*User, 0, len(rows))
+		for _, row := range rows {
+			user, err := row.toUser()
+			if err != nil {
+				return err
+			}
+			removed = append(removed, user)
+		}
+		if err := tx.Where("1 = 1").Delete(&sqliteUser{}).Error; err != nil {
+			return err
+		}
+		for _, user := range users {
+			row, err := fromUser(user)
+			if err != nil {
+				return err
+			}
+			if err := tx.Create(row).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortUsers(removed)
+	return removed, nil
+}
+
+// first returns the user matching the query, or errUserNotFound
+func (s *sqliteStore) first(db *gorm.DB, query string, args ...any) (*User, error) {
+	var row sqliteUser
+	if err := db.Where(query, args...).First(&row).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errUserNotFound
+		}
+		return nil, err
+	}
+	return row.toUser()
+}
+
+// create inserts user within tx unless its email is taken
+func create(tx *gorm.DB, user *User) error {
+	var n int64
+	if err := tx.Model(&sqliteUser{}).Where("email = ?", normalizeEmail(user.Email)).Count(&n).Error; err != nil {
+		return err
+	}
+	if n > 0 {
+		return errUserExists
+	}
+	row, err := fromUser(user)
+	if err != nil {
+		return err
+	}
+	return tx.Create(row).Error
+}
+
+// sqliteScenario is a row of the scenarios table, holding the scenarios
+// that left scenarioStarted
+type sqliteScenario struct {
+	Name  string `gorm:"primaryKey"`
+	State string `gorm:"not null"`
+}
+
+func (sqliteScenario) TableName() string { return "scenarios" }
+
+// ScenarioState implements Store.ScenarioState
+func (s *sqliteStore) ScenarioState(ctx context.Context, name string) (string, error) {
+	var row sqliteScenario
+	err := s.db.WithContext(ctx).Where("name = ?", name).Take(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return scenarioStarted, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return row.State, nil
+}
+
+// SetScenarioState implements Store.SetScenarioState
+func (s *sqliteStore) SetScenarioState(ctx context.Context, name, state string) error {
+	db := s.db.WithContext(ctx)
+	if state == scenarioStarted {
+		return db.Where("name = ?", name).Delete(&sqliteScenario{}).Error
+	}
+	return db.Save(&sqliteScenario{Name: name, State: state}).Error
+}
+
+// Scenarios implements Store.Scenarios
+func (s *sqliteStore) Scenarios(ctx context.Context) (map[string]string, error) {
+	var rows []sqliteScenario
+	if err := s.db.WithContext(ctx).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	scenarios := make(map[string]string, len(rows))
+	for _, row := range rows {
+		scenarios[row.Name] = row.State
+	}
+	return scenarios, nil
+}
+
+// ResetScenarios implements Store.ResetScenarios
+func (s *sqliteStore) ResetScenarios(ctx context.Context) error {
+	return s.db.WithContext(ctx).Where("1 = 1").Delete(&sqliteScenario{}).Error
+}
@@ -0,0 +1,171 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSQLiteStoreSurvivesRestart(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "users.db")
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	user := &User{
+		ID:        "1",
+		Username:  "alice",
+		Email:     "alice@example.com",
+		CreatedAt: created,
+		UpdatedAt: created,
+		Version:   1,
+		Preferences: Preferences{
+			ShowEmail:     true,
+			Theme:         "dark",
+			Tags:          []string{"beta"},
+			Settings:      map[string]any{"lang": "en"},
+			Notifications: []Notification{{Type: "email", Channel: "security", Enabled: true}},
+		},
+	}
+
+	store, err := newSQLiteStore(path)
+	assert.NoError(t, err)
+	assert.NoError(t, store.Create(ctx, user))
+	assert.ErrorIs(t, store.Create(ctx, user), errUserExists)
+	assert.NoError(t, store.Create(ctx, &User{ID: "2", Username: "bob", Email: "bob@example.com", CreatedAt: created.Add(time.Second)}))
+	_, err = store.Update(ctx, "bob@example.com", func(u *User) error {
+		deletedAt := created.Add(time.Minute)
+		u.DeletedAt = &deletedAt
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, store.Close())
+
+	store, err = newSQLiteStore(path)
+	assert.NoError(t, err)
+	defer store.Close()
+
+	got, err := store.Get(ctx, "Alice@Example.com")
+	assert.NoError(t, err)
+	assert.True(t, got.CreatedAt.Equal(created))
+	got.CreatedAt, got.UpdatedAt = created, created
+	assert.Equal(t, user, got)
+
+	byID, err := store.GetByID(ctx, "2")
+	assert.NoError(t, err)
+	assert.Equal(t, "bob", byID.Username)
+	assert.NotNil(t, byID.DeletedAt)
+
+	active, deleted, err := store.Count(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, active)
+	assert.Equal(t, 1, deleted)
+
+	list, err := store.List(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, list, 2) {
+		assert.Equal(t, "1", list[0].ID)
+		assert.Equal(t, "2", list[1].ID)
+	}
+
+	_, err = store.Delete(ctx, "bob@example.com")
+	assert.NoError(t, err)
+	_, err = store.Get(ctx, "bob@example.com")
+	assert.ErrorIs(t, err, errUserNotFound)
+	_, err = store.Delete(ctx, "bob@example.com")
+	assert.ErrorIs(t, err, errUserNotFound)
+}
+
+func TestSQLiteStoreCreateMany(t *testing.T) {
+	ctx := context.Background()
+	store, err := newSQLiteStore(filepath.Join(t.TempDir(), "users.db"))
+	assert.NoError(t, err)
+	defer store.Close()
+
+	errs := store.CreateMany(ctx, []*User{
+		{ID: "1", Email: "alice@example.com"},
+		{ID: "2", Email: "bob@example.com"},
+		{ID: "3", Email: "ALICE@example.com"},
+	})
+	assert.Equal(t, []error{nil, nil, errUserExists}, errs)
+	active, _, err := store.Count(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, active)
+	removed, err := store.Replace(ctx, []*User{{ID: "4", Email: "carol@example.com"}})
+	assert.NoError(t, err)
+	if assert.Len(t, removed, 2) {
+		assert.Equal(t, "alice@example.com", removed[0].Email)
+		assert.Equal(t, "bob@example.com", removed[1].Email)
+	}
+	list, err := store.List(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, list, 1) {
+		assert.Equal(t, "carol@example.com", list[0].Email)
+	}
+}
+
+func TestSQLiteStoreListAfter(t *testing.T) {
+	ctx := context.Background()
+	store, err := newSQLiteStore(filepath.Join(t.TempDir(), "users.db"))
+	assert.NoError(t, err)
+	defer store.Close()
+
+	created := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	users := []*User{
+		{ID: "a", Email: "a@example.com", CreatedAt: created},
+		{ID: "b", Email: "b@example.com", CreatedAt: created},
+		{ID: "c", Email: "c@example.com", CreatedAt: created.Add(500 * time.Millisecond)},
+		// Later than c although its local time sorts first
+		{ID: "d", Email: "d@example.com", CreatedAt: created.Add(time.Hour).In(time.FixedZone("", -5*3600))},
+		{ID: "e", Email: "e@example.com", CreatedAt: created.Add(2 * time.Hour)},
+	}
+	for _, user := range users {
+		assert.NoError(t, store.Create(ctx, user))
+	}
+
+	var got []string
+	var after *User
+	for range len(users) {
+		page, err := store.ListAfter(ctx, after, 2)
+		assert.NoError(t, err)
+		if len(page) == 0 {
+			break
+		}
+		for _, user := range page {
+			got = append(got, user.ID)
+		}
+		after = page[len(page)-1]
+	}
+	assert.Equal(t, []string{"a", "b", "c", "d", "e"}, got)
+}
+
+func TestSQLiteStoreScenarios(t *testing.T) {
+	store, err := newSQLiteStore(filepath.Join(t.TempDir(), "users.db"))
+	assert.NoError(t, err)
+	defer store.Close()
+	testScenarioStore(t, store)
+}
+
+func TestSQLiteStoreBackend(t *testing.T) {
+	t.Setenv("STORE_BACKEND", "sqlite")
+	t.Setenv("SQLITE_PATH", filepath.Join(t.TempDir(), "data", "users.db"))
+
+	s := newTestServer(t)
+	w := doRequest(s, http.MethodPost, "/users", `{"username":"alice","email":"alice@example.com"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var created User
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.NoError(t, s.Stop())
+
+	s = newTestServer(t)
+	defer s.Stop()
+	w = doRequest(s, http.MethodGet, "/users/email/alice@example.com", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var got User
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+	assert.Equal(t, created.ID, got.ID)
+	assert.Equal(t, created.Preferences, got.Preferences)
+}
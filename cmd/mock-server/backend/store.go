@@ -0,0 +1,223 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Store backends
+const (
+	storeMemory = "memory"
+	storeSQLite = "sqlite"
+	storeRedis  = "redis"
+	storeFile   = "file"
+)
+
+// storePingTimeout bounds the store check of /readyz
+const storePingTimeout = time.Second
+
+var (
+	errUserNotFound = errors.New("user not found")
+	errUserExists   = errors.New("email already exists")
+
+	errVersionMismatch = errors.New("version mismatch")
+
+	// errStoreUnavailable wraps the errors of a store backend that cannot
+	// be reached
+	errStoreUnavailable = errors.New("user store unavailable")
+)
+
+// Store persists users keyed by their normalized email, along with the
+// state of the stub scenarios. The in-memory store is the default; other
+// backends are plugged in with SetStore. Implementations must be safe for
+// concurrent use and must not share memory with the users passed in or
+// returned.
+type Store interface {
+	// Get returns the user with the given email, or errUserNotFound
+	Get(ctx context.Context, email string) (*User, error)
+	// GetByID returns the user with the given ID, or errUserNotFound
+	GetByID(ctx context.Context, id string) (*User, error)
+	// Put stores the user, replacing any user with the same email
+	Put(ctx context.Context, user *User) error
+	// Create stores the user unless its email is taken, in which case
+	// errUserExists is returned
+	Create(ctx context.Context, user *User) error
+	// CreateMany creates all users, returning one error per user
+	CreateMany(ctx context.Context, users []*User) []error
+	// Update applies fn to the stored user atomically and returns the
+	// result. If fn returns an error the user is left as is and the error
+	// is returned.
+	Update(ctx context.Context, email string, fn func(*User) error) (*User, error)
+	// Delete removes the user with the given email and returns it, or
+	// errUserNotFound
+	Delete(ctx context.Context, email string) (*User, error)
+	// List returns all users, soft-deleted ones included, ordered by
+	// creation time and then ID
+	List(ctx context.Context) ([]*User, error)
+	// Count returns the number of active and soft-deleted users
+	Count(ctx context.Context) (active, deleted int, err error)
+	// Replace atomically removes every user and stores the given ones,
+	// whose emails and IDs must be unique. It returns the users removed,
+	// ordered like List.
+	Replace(ctx context.Context, users []*User) ([]*User, error)
+
+	// ScenarioState returns the current state of the stub scenario name,
+	// scenarioStarted when it never moved
+	ScenarioState(ctx context.Context, name string) (string, error)
+	// SetScenarioState moves the scenario name to state
+	SetScenarioState(ctx context.Context, name, state string) error
+	// Scenarios returns the state of every scenario that left
+	// scenarioStarted
+	Scenarios(ctx context.Context) (map[string]string, error)
+	// ResetScenarios returns every scenario to scenarioStarted
+	ResetScenarios(ctx context.Context) error
+}
+
+// newStore opens the store backend selected by cfg.StoreBackend
+func newStore(cfg Config, logger *zap.Logger) (Store, error) {
+	switch cfg.StoreBackend {
+	case "", storeMemory:
+		return newMemoryStore(), nil
+	case storeSQLite:
+		return NewSQLiteStore(cfg.SQLitePath)
+	case storeRedis:
+		return NewRedisStore(cfg.RedisURL, cfg.RedisKeyPrefix)
+	case storeFile:
+		return newJSONFileStore(cfg.StoreFile, cfg.StoreFileInterval, logger)
+	default:
+		return nil, fmt.Errorf("unknown STORE_BACKEND %q", cfg.StoreBackend)
+	}
+}
+
+// storedUser is the JSON encoding of a user in the redis and file stores.
+// Unlike the API representation it keeps the avatar path.
+type storedUser struct {
+	User
+	AvatarPath string `json:"avatarPath,omitempty"`
+}
+
+func newStoredUser(user *User) storedUser {
+	return storedUser{User: *user, AvatarPath: user.AvatarPath}
+}
+
+// toUser returns the stored user
+func (u storedUser) toUser() *User {
+	user := u.User
+	user.AvatarPath = u.AvatarPath
+	return &user
+}
+
+// decodeStoredUser decodes a JSON encoded storedUser
+func decodeStoredUser(data []byte) (*User, error) {
+	var stored storedUser
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("decode user: %w", err)
+	}
+	return stored.toUser(), nil
+}
+
+// storePinger is implemented by stores with a remote backend, checked by
+// /readyz
+type storePinger interface {
+	Ping(ctx context.Context) error
+}
+
+// SetStore replaces the backend users are stored in. It must be called
+// before the server starts handling requests.
+func (s *HTTPServer) SetStore(store Store) {
+	s.users = store
+}
+
+// respondStoreError writes the response for an error returned by the
+// store: 404 for a missing user, 409 for a taken email, 503 when the
+// backend cannot be reached and 500 otherwise
+func (s *HTTPServer) respondStoreError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errUserNotFound):
+		respondError(c, http.StatusNotFound, codeNotFound, errUserNotFound.Error())
+	case errors.Is(err, errUserExists):
+		respondError(c, http.StatusConflict, codeConflict, errUserExists.Error())
+	case errors.Is(err, errStoreUnavailable):
+		s.requestLogger(c).Warn("user store unavailable", zap.Error(err))
+		respondError(c, http.StatusServiceUnavailable, codeUnavailable, errStoreUnavailable.Error())
+	default:
+		s.requestLogger(c).Error("user store failed", zap.Error(err))
+		respondError(c, http.StatusInternalServerError, codeInternal, "failed to access user store")
+	}
+}
+
+// closeStore releases the store's resources if it holds any, such as an
+// open database
+func (s *HTTPServer) closeStore() error {
+	if closer, ok := s.users.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// sortUsers orders users by creation time, with the ID as a tie breaker so
+// the order is stable across calls
+func sortUsers(users []*User) {
+	slices.SortFunc(users, compareUsers)
+}
+
+// compareUsers compares users in the order of sortUsers
+func compareUsers(a, b *User) int {
+	if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+		return c
+	}
+	return strings.Compare(a.ID, b.ID)
+}
+
+// userPager is implemented by stores that can read users a page at a
+// time, so exports don't hold every user in memory
+type userPager interface {
+	// ListAfter returns up to limit users, soft-deleted ones included,
+	// that follow after in the order of List, or the first ones when
+	// after is nil
+	ListAfter(ctx context.Context, after *User, limit int) ([]*User, error)
+}
+
+// listPages calls fn with the users of store, soft-deleted ones included,
+// at most pageSize at a time and in the order of List. Stores that are not
+// a userPager are listed at once and handed to fn in pages.
+func listPages(ctx context.Context, store Store, pageSize int, fn func([]*User) error) error {
+	pager, ok := store.(userPager)
+	if !ok {
+		users, err := store.List(ctx)
+		if err != nil {
+			return err
+		}
+		for page := range slices.Chunk(users, pageSize) {
+			if err := fn(page); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var after *User
+	for {
+		page, err := pager.ListAfter(ctx, after, pageSize)
+		if err != nil || len(page) == 0 {
+			return err
+		}
+		if err := fn(page); err != nil {
+			return err
+		}
+		if len(page) < pageSize {
+			return nil
+		}
+		after = page[len(page)-1]
+	}
+}
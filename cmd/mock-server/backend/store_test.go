@@ -0,0 +1,73 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// brokenStore fails List and Create and delegates everything else
+type brokenStore struct {
+	Store
+}
+
+var errStoreDown = errors.New("store down")
+
+func (brokenStore) List(context.Context) ([]*User, error) { return nil, errStoreDown }
+
+func (brokenStore) Create(context.Context, *User) error { return errStoreDown }
+
+func TestSetStore(t *testing.T) {
+	s := newTestServer(t)
+	store := newMemoryStore()
+	assert.NoError(t, store.Put(context.Background(), &User{ID: "1", Email: "alice@example.com", Username: "alice"}))
+	s.SetStore(brokenStore{Store: store})
+
+	w := doRequest(s, http.MethodGet, "/users/email/alice@example.com", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = doRequest(s, http.MethodGet, "/users", "")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.JSONEq(t, `{"code":"internal","error":"failed to access user store"}`, w.Body.String())
+
+	w = doRequest(s, http.MethodPost, "/users", `{"username":"bob","email":"bob@example.com"}`)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
+
+// testScenarioStore checks the scenario methods of store, which must have
+// no scenarios yet
+func testScenarioStore(t *testing.T, store Store) {
+	t.Helper()
+	ctx := context.Background()
+
+	state, err := store.ScenarioState(ctx, "order")
+	assert.NoError(t, err)
+	assert.Equal(t, scenarioStarted, state)
+
+	assert.NoError(t, store.SetScenarioState(ctx, "order", "Created"))
+	assert.NoError(t, store.SetScenarioState(ctx, "order", "Shipped"))
+	assert.NoError(t, store.SetScenarioState(ctx, "login", "Locked"))
+	state, err = store.ScenarioState(ctx, "order")
+	assert.NoError(t, err)
+	assert.Equal(t, "Shipped", state)
+	scenarios, err := store.Scenarios(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"order": "Shipped", "login": "Locked"}, scenarios)
+
+	assert.NoError(t, store.SetScenarioState(ctx, "login", scenarioStarted))
+	scenarios, err = store.Scenarios(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"order": "Shipped"}, scenarios)
+
+	assert.NoError(t, store.ResetScenarios(ctx))
+	scenarios, err = store.Scenarios(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, scenarios)
+}
+
+func TestMemoryStoreScenarios(t *testing.T) {
+	testScenarioStore(t, newMemoryStore())
+}
@@ -0,0 +1,416 @@
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+const defaultStubReloadInterval = 2 * time.Second
+
+// stubExtensions are the files of the stub directory that are loaded
+var stubExtensions = []string{".yaml", ".yml", ".json"}
+
+// stub is a canned response for the requests it matches. A stub file holds
+// one stub or a list of them, in YAML or JSON:
+//
+//	name: flaky-orders
+//	method: GET
+//	path: /orders/:id
+//	query: {expand: items}
+//	headers: {X-Tenant: acme}
+//	response:
+//	  status: 503
+//	  headers: {Retry-After: "5"}
+//	  body: {error: orders are down}
+//
+// Method is optional and matches any method when empty. Path segments
+// starting with ':' match any single segment and a trailing segment
+// starting with '*' matches the rest of the path, like gin routes. Every
+// header and query parameter listed must be present with exactly that
+// value. A string body is written as is, any other body as JSON. Status
+// defaults to 200.
+//
+// Stubs of the same scenario model a stateful backend, as in WireMock. A
+// stub with a requiredState only matches while its scenario is in that
+// state, and one with a newState moves the scenario there when it
+// answers. Every scenario begins in scenarioStarted:
+//
+//   - {scenario: order, requiredState: Started, path: /orders/1, response: {status: 404}}
+//   - {scenario: order, newState: Created, method: POST, path: /orders, response: {status: 201}}
+//   - {scenario: order, requiredState: Created, path: /orders/1, response: {body: {id: 1}}}
+type stub struct {
+	Name          string            `yaml:"name"`
+	Method        string            `yaml:"method"`
+	Path          string            `yaml:"path"`
+	Headers       map[string]string `yaml:"headers"`
+	Query         map[string]string `yaml:"query"`
+	Scenario      string            `yaml:"scenario"`
+	RequiredState string            `yaml:"requiredState"`
+	NewState      string            `yaml:"newState"`
+	Response      stubResponse      `yaml:"response"`
+
+	segments []string
+}
+
+// stubResponse is what a stub answers with
+type stubResponse struct {
+	Status  int               `yaml:"status"`
+	Headers map[string]string `yaml:"headers"`
+	Body    any               `yaml:"body"`
+}
+
+// compile checks st and prepares it for matching
+func (st *stub) compile() error {
+	st.Method = strings.ToUpper(st.Method)
+	if !strings.HasPrefix(st.Path, "/") {
+		return fmt.Errorf("path %q must start with /", st.Path)
+	}
+	st.segments = strings.Split(strings.Trim(st.Path, "/"), "/")
+	for i, seg := range st.segments {
+		if strings.HasPrefix(seg, "*") && i != len(st.segments)-1 {
+			return fmt.Errorf("wildcard %q must be the last segment of path %q", seg, st.Path)
+		}
+	}
+	if st.Scenario == "" && (st.RequiredState != "" || st.NewState != "") {
+		return errors.New("requiredState and newState require a scenario")
+	}
+	if st.Response.Status == 0 {
+		st.Response.Status = http.StatusOK
+	}
+	if st.Response.Status < 100 || st.Response.Status > 599 {
+		return fmt.Errorf("invalid status %d, must be from 100 to 599", st.Response.Status)
+	}
+	return nil
+}
+
+// matches reports whether r is answered by st
+func (st *stub) matches(r *http.Request) bool {
+	if st.Method != "" && st.Method != r.Method {
+		return false
+	}
+	if !st.matchPath(r.URL.Path) {
+		return false
+	}
+	for name, value := range st.Headers {
+		if r.Header.Get(name) != value {
+			return false
+		}
+	}
+	query := r.URL.Query()
+	for name, value := range st.Query {
+		if !query.Has(name) || query.Get(name) != value {
+			return false
+		}
+	}
+	return true
+}
+
+func (st *stub) matchPath(path string) bool {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	for i, seg := range st.segments {
+		if strings.HasPrefix(seg, "*") {
+			return true
+		}
+		if i >= len(parts) {
+			return false
+		}
+		if strings.HasPrefix(seg, ":") {
+			if parts[i] == "" {
+				return false
+			}
+			continue
+		}
+		if seg != parts[i] {
+			return false
+		}
+	}
+	return len(parts) == len(st.segments)
+}
+
+// respond writes the response of st
+func (st *stub) respond(c *gin.Context) {
+	for name, value := range st.Response.Headers {
+		c.Header(name, value)
+	}
+	status := st.Response.Status
+	switch body := st.Response.Body.(type) {
+	case nil:
+		c.Status(status)
+	case string:
+		contentType := st.Response.Headers["Content-Type"]
+		if contentType == "" {
+			contentType = "text/plain; charset=utf-8"
+		}
+		c.Data(status, contentType, []byte(body))
+	default:
+		c.JSON(status, body)
+	}
+}
+
+// stubSet holds the stubs loaded from a directory. Without a directory it
+// is empty and matches nothing.
+type stubSet struct {
+	dir    string
+	logger *zap.Logger
+
+	mu          sync.RWMutex
+	stubs       []*stub
+	fingerprint string
+
+	stop      chan struct{}
+	closeOnce sync.Once
+}
+
+// newStubSet loads the stubs in dir and, with a positive interval, checks
+// it for changes that often, reloading the stubs when a file was added,
+// removed or modified
+func newStubSet(dir string, interval time.Duration, logger *zap.Logger) (*stubSet, error) {
+	s := &stubSet{
+		dir:    dir,
+		logger: logger.Named("stubs"),
+		stop:   make(chan struct{}),
+	}
+	if dir == "" {
+		return s, nil
+	}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	if interval > 0 {
+		go s.watch(interval)
+	}
+	return s, nil
+}
+
+// Reload loads the stubs again. When a file is invalid the error is
+// returned and the current stubs are kept.
+func (s *stubSet) Reload() error {
+	if s.dir == "" {
+		return nil
+	}
+	fingerprint, err := stubFingerprint(s.dir)
+	if err != nil {
+		return err
+	}
+	stubs, err := loadStubs(s.dir)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.stubs = stubs
+	s.fingerprint = fingerprint
+	s.mu.Unlock()
+	s.logger.Info("stubs loaded", zap.String("dir", s.dir), zap.Int("stubs", len(stubs)))
+	return nil
+}
+
+// match returns the first stub matching r whose scenario, if any, is in
+// its required state, or nil. state looks up the current state of a
+// scenario.
+func (s *stubSet) match(r *http.Request, state func(scenario string) (string, error)) (*stub, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	states := map[string]string{}
+	for _, st := range s.stubs {
+		if !st.matches(r) {
+			continue
+		}
+		if st.RequiredState == "" {
+			return st, nil
+		}
+		current, ok := states[st.Scenario]
+		if !ok {
+			var err error
+			if current, err = state(st.Scenario); err != nil {
+				return nil, err
+			}
+			states[st.Scenario] = current
+		}
+		if current == st.RequiredState {
+			return st, nil
+		}
+	}
+	return nil, nil
+}
+
+// Close stops watching the directory
+func (s *stubSet) Close() {
+	s.closeOnce.Do(func() { close(s.stop) })
+}
+
+func (s *stubSet) watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+
+		fingerprint, err := stubFingerprint(s.dir)
+		if err != nil {
+			s.logger.Warn("failed to check stubs", zap.Error(err))
+			continue
+		}
+		s.mu.RLock()
+		changed := fingerprint != s.fingerprint
+		s.mu.RUnlock()
+		if !changed {
+			continue
+		}
+		if err := s.Reload(); err != nil {
+			s.logger.Error("failed to reload stubs, keeping the previous ones", zap.Error(err))
+			s.mu.Lock()
+			s.fingerprint = fingerprint
+			s.mu.Unlock()
+		}
+	}
+}
+
+// stubFiles lists the stub files in dir in name order
+func stubFiles(dir string) ([]os.DirEntry, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read stub directory: %w", err)
+	}
+	files := entries[:0]
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && isStubFile(entry.Name()) {
+			files = append(files, entry)
+		}
+	}
+	return files, nil
+}
+
+func isStubFile(name string) bool {
+	return slices.Contains(stubExtensions, strings.ToLower(filepath.Ext(name)))
+}
+
+// stubFingerprint summarizes the names, sizes and modification times of
+// the stub files in dir, changing whenever one of them does
+func stubFingerprint(dir string) (string, error) {
+	files, err := stubFiles(dir)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for _, file := range files {
+		info, err := file.Info()
+		if err != nil {
+			return "", fmt.Errorf("stat stub file: %w", err)
+		}
+		fmt.Fprintf(&b, "%s:%d:%d\n", file.Name(), info.Size(), info.ModTime().UnixNano())
+	}
+	return b.String(), nil
+}
+
+// loadStubs reads the stub files in dir in name order, so a stub in an
+// earlier file wins over a later one matching the same requests. All
+// invalid files are reported together.
+func loadStubs(dir string) ([]*stub, error) {
+	files, err := stubFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	var stubs []*stub
+	var errs []error
+	for _, file := range files {
+		loaded, err := readStubFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", file.Name(), err))
+			continue
+		}
+		stubs = append(stubs, loaded...)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, fmt.Errorf("invalid stubs:\n%w", err)
+	}
+	return stubs, nil
+}
+
+// readStubFile reads the stub or list of stubs in the file at path. Stubs
+// without a name are named after the file and their position in it.
+func readStubFile(path string) ([]*stub, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+
+	var stubs []*stub
+	if root := doc.Content[0]; root.Kind == yaml.SequenceNode {
+		err = root.Decode(&stubs)
+	} else {
+		st := &stub{}
+		err = root.Decode(st)
+		stubs = []*stub{st}
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i, st := range stubs {
+		if st == nil {
+			return nil, fmt.Errorf("stub %d is empty", i+1)
+		}
+		if st.Name == "" {
+			st.Name = fmt.Sprintf("%s#%d", filepath.Base(path), i+1)
+		}
+		if err := st.compile(); err != nil {
+			return nil, fmt.Errorf("stub %s: %w", st.Name, err)
+		}
+	}
+	return stubs, nil
+}
+
+// stubMiddleware answers requests matching a stub with its canned
+// response instead of the route's handler, marking them with X-Mock-Stub
+// set to the stub's name, and moves the stub's scenario to its new state.
+// Requests no stub matches, including those to routes the server doesn't
+// have, fall through to the built-in handlers.
+func (s *HTTPServer) stubMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		st, err := s.stubs.match(c.Request, func(scenario string) (string, error) {
+			return s.users.ScenarioState(ctx, scenario)
+		})
+		if err != nil {
+			s.respondStoreError(c, err)
+			c.Abort()
+			return
+		}
+		if st == nil {
+			c.Next()
+			return
+		}
+		if st.NewState != "" {
+			if err := s.users.SetScenarioState(ctx, st.Scenario, st.NewState); err != nil {
+				s.respondStoreError(c, err)
+				c.Abort()
+				return
+			}
+		}
+		c.Header("X-Mock-Stub", st.Name)
+		st.respond(c)
+		c.Abort()
+	}
+}
@@ -0,0 +1,152 @@
+package backend
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const ordersStubs = `
+- name: orders-down
+  method: GET
+  path: /orders/:id
+  headers: {X-Tenant: acme}
+  response:
+    status: 503
+    headers: {Retry-After: "5"}
+    body: {error: orders are down}
+- method: get
+  path: /orders/:id
+  response:
+    body: {id: 1, items: [a, b]}
+`
+
+func TestStubs(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "orders.yaml"), []byte(ordersStubs), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "users.json"),
+		[]byte(`{"path": "/users/count", "query": {"source": "stub"}, "response": {"body": "42"}}`), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("not a stub"), 0o644))
+	t.Setenv("STUB_DIR", dir)
+	s := newTestServer(t)
+
+	tests := []struct {
+		name   string
+		path   string
+		header string
+		status int
+		stub   string
+		body   string
+	}{
+		{"header matcher", "/orders/7", "acme", http.StatusServiceUnavailable, "orders-down", `{"error":"orders are down"}`},
+		{"first match", "/orders/7", "", http.StatusOK, "orders.yaml#2", `{"id":1,"items":["a","b"]}`},
+		{"path segments", "/orders/7/items", "", http.StatusNotFound, "", ""},
+		{"query matcher", "/users/count?source=stub", "", http.StatusOK, "users.json#1", ""},
+		{"falls through", "/users/count", "", http.StatusOK, "", `{"count":0,"deleted":0}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.header != "" {
+				req.Header.Set("X-Tenant", tt.header)
+			}
+			w := httptest.NewRecorder()
+			s.router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+			assert.Equal(t, tt.stub, w.Header().Get("X-Mock-Stub"))
+			if tt.body != "" {
+				assert.JSONEq(t, tt.body, w.Body.String())
+			}
+		})
+	}
+
+	w := doRequest(s, http.MethodGet, "/orders/7", "")
+	assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+	w = doRequest(s, http.MethodGet, "/users/count?source=stub", "")
+	assert.Equal(t, "42", w.Body.String())
+	assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+}
+
+func TestStubsReloadOnChange(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "ping.yaml")
+	assert.NoError(t, os.WriteFile(file, []byte("path: /ping\nresponse: {body: one}\n"), 0o644))
+	t.Setenv("STUB_DIR", dir)
+	t.Setenv("STUB_RELOAD_INTERVAL", "10ms")
+	s := newTestServer(t)
+	t.Cleanup(s.stubs.Close)
+
+	assert.Equal(t, "one", doRequest(s, http.MethodGet, "/ping", "").Body.String())
+
+	assert.NoError(t, os.WriteFile(file, []byte("path: /ping\nresponse: {status: 201, body: two}\n"), 0o644))
+	assert.Eventually(t, func() bool {
+		return doRequest(s, http.MethodGet, "/ping", "").Body.String() == "two"
+	}, time.Second, 10*time.Millisecond)
+
+	assert.NoError(t, os.WriteFile(file, []byte("path: ping\n"), 0o644))
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, "two", doRequest(s, http.MethodGet, "/ping", "").Body.String(), "invalid stubs keep the previous ones")
+
+	assert.NoError(t, os.Remove(file))
+	assert.Eventually(t, func() bool {
+		return doRequest(s, http.MethodGet, "/ping", "").Code == http.StatusNotFound
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestReadStubFileErrors(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		content string
+		err     string
+	}{
+		{"relative path", "path: orders", `stub bad.yaml#1: path "orders" must start with /`},
+		{"wildcard not last", "path: /files/*rest/meta", `stub bad.yaml#1: wildcard "*rest" must be the last segment of path "/files/*rest/meta"`},
+		{"status", "{path: /x, response: {status: 999}}", "stub bad.yaml#1: invalid status 999, must be from 100 to 599"},
+		{"state without scenario", "{path: /x, newState: Done}", "stub bad.yaml#1: requiredState and newState require a scenario"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, "bad.yaml")
+			assert.NoError(t, os.WriteFile(path, []byte(tt.content), 0o644))
+			_, err := readStubFile(path)
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+}
+
+const orderScenario = `
+- {scenario: order, requiredState: Started, path: /orders/1, response: {status: 404}}
+- {scenario: order, newState: Created, method: POST, path: /orders, response: {status: 201}}
+- {scenario: order, requiredState: Created, path: /orders/1, response: {body: {id: 1}}}
+`
+
+func TestStubScenario(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "order.yaml"), []byte(orderScenario), 0o644))
+	t.Setenv("STUB_DIR", dir)
+	t.Setenv("ENABLE_ADMIN", "true")
+	s := newTestServer(t)
+
+	assert.Equal(t, http.StatusNotFound, doRequest(s, http.MethodGet, "/orders/1", "").Code)
+	assert.Equal(t, http.StatusCreated, doRequest(s, http.MethodPost, "/orders", "").Code)
+	w := doRequest(s, http.MethodGet, "/orders/1", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"id":1}`, w.Body.String())
+
+	w = doRequest(s, http.MethodGet, "/admin/scenarios", "")
+	assert.JSONEq(t, `{"scenarios":{"order":"Created"}}`, w.Body.String())
+	w = doRequest(s, http.MethodPost, "/admin/scenarios/reset?name=order", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"scenarios":{}}`, w.Body.String())
+	assert.Equal(t, http.StatusNotFound, doRequest(s, http.MethodGet, "/orders/1", "").Code)
+
+	assert.Equal(t, http.StatusCreated, doRequest(s, http.MethodPost, "/orders", "").Code)
+	assert.JSONEq(t, `{"scenarios":{}}`, doRequest(s, http.MethodPost, "/admin/scenarios/reset", "").Body.String())
+}
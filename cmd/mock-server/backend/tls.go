@@ -0,0 +1,63 @@
+package backend
+
+import (
+	"crypto/tls"
+	"fmt"
+)
+
+// tlsVersions maps the accepted TLS_MIN_VERSION values
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// TLSConfig holds the settings for serving HTTPS. TLS is enabled when both
+// CertFile and KeyFile are set.
+type TLSConfig struct {
+	CertFile string
+	KeyFile  string
+	// MinVersion is the minimum TLS version, "1.0" to "1.3"
+	MinVersion string
+	// CipherSuites are the names of the allowed cipher suites as listed by
+	// tls.CipherSuites, e.g. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256. Empty
+	// means Go's defaults. TLS 1.3 suites are not configurable.
+	CipherSuites []string
+}
+
+// Enabled reports whether HTTPS should be served
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != "" && c.KeyFile != ""
+}
+
+// build returns the tls.Config for the settings
+func (c TLSConfig) build() (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if c.MinVersion != "" {
+		version, ok := tlsVersions[c.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("invalid TLS min version %q", c.MinVersion)
+		}
+		cfg.MinVersion = version
+	}
+
+	for _, name := range c.CipherSuites {
+		id, ok := cipherSuiteID(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown TLS cipher suite %q", name)
+		}
+		cfg.CipherSuites = append(cfg.CipherSuites, id)
+	}
+	return cfg, nil
+}
+
+// cipherSuiteID returns the ID of the secure cipher suite with the given name
+func cipherSuiteID(name string) (uint16, bool) {
+	for _, suite := range tls.CipherSuites() {
+		if suite.Name == name {
+			return suite.ID, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,23 @@
+package backend
+
+import (
+	"crypto/tls"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTLSConfigBuild(t *testing.T) {
+	cfg, err := TLSConfig{
+		MinVersion:   "1.3",
+		CipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"},
+	}.build()
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS13), cfg.MinVersion)
+	assert.Equal(t, []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256}, cfg.CipherSuites)
+
+	_, err = TLSConfig{MinVersion: "1.4"}.build()
+	assert.Error(t, err)
+	_, err = TLSConfig{CipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"}}.build()
+	assert.Error(t, err, "insecure suites are rejected")
+}
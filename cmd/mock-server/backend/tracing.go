@@ -0,0 +1,123 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+const (
+	tracerName         = "github.com/amoylab/unla/cmd/mock-server/backend"
+	defaultServiceName = "mock-server"
+)
+
+// tracing holds the tracer of a server. Without an OTLP endpoint it is
+// disabled: the tracer is a no-op and the middleware is skipped entirely.
+type tracing struct {
+	enabled    bool
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+	// shutdown flushes pending spans and stops the exporter
+	shutdown func(context.Context) error
+}
+
+// newTracing sets up span export to cfg.OTLPEndpoint over OTLP/HTTP. The
+// exporter and resource also honor the other standard OTEL_* variables,
+// such as OTEL_EXPORTER_OTLP_HEADERS and OTEL_SERVICE_NAME.
+func newTracing(cfg Config) (*tracing, error) {
+	t := &tracing{
+		tracer:     noop.NewTracerProvider().Tracer(tracerName),
+		propagator: propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}),
+		shutdown:   func(context.Context) error { return nil },
+	}
+	if cfg.OTLPEndpoint == "" {
+		return t, nil
+	}
+
+	ctx := context.Background()
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("create OTLP exporter: %w", err)
+	}
+	res, err := resource.New(ctx,
+		resource.WithAttributes(attribute.String("service.name", defaultServiceName)),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("create trace resource: %w", err)
+	}
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+
+	t.enabled = true
+	t.tracer = provider.Tracer(tracerName)
+	t.shutdown = provider.Shutdown
+	return t, nil
+}
+
+// tracingMiddleware starts a server span per request, continuing the
+// trace of an incoming W3C traceparent header. The span is named after
+// the route pattern and carries the response status.
+func (s *HTTPServer) tracingMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !s.tracing.enabled {
+			c.Next()
+			return
+		}
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		ctx := s.tracing.propagator.Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+		ctx, span := s.tracing.tracer.Start(ctx, c.Request.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", c.Request.Method),
+				attribute.String("http.route", route),
+				attribute.String("url.path", c.Request.URL.Path),
+				attribute.String("client.address", c.ClientIP()),
+				attribute.String("request.id", c.GetString(requestIDKey)),
+			),
+		)
+		defer span.End()
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		if status >= 500 {
+			span.SetStatus(codes.Error, "")
+		}
+	}
+}
+
+// startWeatherSpan starts a client span for a weather provider call, a
+// child of the request span in ctx
+func (s *HTTPServer) startWeatherSpan(ctx context.Context, operation, city string) (context.Context, trace.Span) {
+	return s.tracing.tracer.Start(ctx, "weather."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("weather.provider", s.config().WeatherProvider),
+			attribute.String("weather.city", city),
+		),
+	)
+}
+
+// endSpan records err, if any, on span and ends it
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
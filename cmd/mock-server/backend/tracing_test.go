@@ -0,0 +1,57 @@
+package backend
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestNewTracing(t *testing.T) {
+	s := newTestServer(t)
+	assert.False(t, s.tracing.enabled)
+
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://127.0.0.1:4318")
+	s = newTestServer(t)
+	assert.True(t, s.tracing.enabled)
+	assert.NoError(t, s.tracing.shutdown(context.Background()))
+}
+
+func TestTracing(t *testing.T) {
+	s := newTestServer(t)
+	s.SetWeatherProvider(StaticProvider{})
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	s.tracing = &tracing{
+		enabled:    true,
+		tracer:     provider.Tracer(tracerName),
+		propagator: propagation.TraceContext{},
+		shutdown:   provider.Shutdown,
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/weather?city=110101", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	spans := exporter.GetSpans()
+	if !assert.Len(t, spans, 2) {
+		return
+	}
+	weather, server := spans[0], spans[1]
+	assert.Equal(t, "GET /weather", server.Name)
+	assert.Equal(t, trace.SpanKindServer, server.SpanKind)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", server.SpanContext.TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", server.Parent.SpanID().String())
+
+	assert.Equal(t, "weather.current", weather.Name)
+	assert.Equal(t, trace.SpanKindClient, weather.SpanKind)
+	assert.Equal(t, server.SpanContext.SpanID(), weather.Parent.SpanID())
+}
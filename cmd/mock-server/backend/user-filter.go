@@ -0,0 +1,73 @@
+package backend
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// userFilter selects users by the query parameters shared by the user
+// list and search, all of which must match:
+//
+//	createdAfter, createdBefore  RFC 3339 timestamps, exclusive
+//	theme                        the theme of the preferences
+//	tag                          repeatable, the preferences have every tag
+type userFilter struct {
+	createdAfter  time.Time
+	createdBefore time.Time
+	theme         string
+	tags          []string
+}
+
+// parseUserFilter reads the filter query parameters, rejecting invalid
+// values
+func parseUserFilter(c *gin.Context) (userFilter, error) {
+	var f userFilter
+	for _, p := range []struct {
+		name string
+		dst  *time.Time
+	}{
+		{"createdAfter", &f.createdAfter},
+		{"createdBefore", &f.createdBefore},
+	} {
+		v := c.Query(p.name)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return userFilter{}, fmt.Errorf("invalid %s %q, must be an RFC 3339 timestamp such as 2024-01-01T00:00:00Z", p.name, v)
+		}
+		*p.dst = t
+	}
+	f.theme = strings.TrimSpace(c.Query("theme"))
+	f.tags = normalizeTags(c.QueryArray("tag"))
+	return f, nil
+}
+
+// match reports whether u is selected by the filter
+func (f userFilter) match(u *User) bool {
+	if !f.createdAfter.IsZero() && !u.CreatedAt.After(f.createdAfter) {
+		return false
+	}
+	if !f.createdBefore.IsZero() && !u.CreatedAt.Before(f.createdBefore) {
+		return false
+	}
+	if f.theme != "" && u.Preferences.Theme != f.theme {
+		return false
+	}
+	for _, tag := range f.tags {
+		if !slices.Contains(u.Preferences.Tags, tag) {
+			return false
+		}
+	}
+	return true
+}
+
+// apply removes the users not selected by the filter from users
+func (f userFilter) apply(users []*User) []*User {
+	return slices.DeleteFunc(users, func(u *User) bool { return !f.match(u) })
+}
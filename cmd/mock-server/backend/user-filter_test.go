@@ -0,0 +1,108 @@
+package backend
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListUsersCreatedRange(t *testing.T) {
+	s := newTestServer(t)
+	for i, name := range []string{"alice", "alfred", "bobby", "albert"} {
+		s.SetClock(fixedClock{deterministicTime.Add(time.Duration(i) * time.Hour)})
+		body := fmt.Sprintf(`{"username":%q,"email":"%s@example.com"}`, name, name)
+		assert.Equal(t, http.StatusCreated, doRequest(s, http.MethodPost, "/users", body).Code)
+	}
+
+	tests := []struct {
+		name  string
+		path  string
+		want  []string
+		total string
+	}{
+		{"after", "/users?createdAfter=2024-01-01T00:30:00Z", []string{"alfred", "bobby", "albert"}, "3"},
+		{"before", "/users?createdBefore=2024-01-01T02:00:00Z", []string{"alice", "alfred"}, "2"},
+		{"exclusive range", "/users?createdAfter=2024-01-01T00:00:00Z&createdBefore=2024-01-01T03:00:00Z", []string{"alfred", "bobby"}, "2"},
+		{"with offset", "/users?createdAfter=2024-01-01T00:30:00Z&limit=1&offset=1", []string{"bobby"}, "3"},
+		{"time zone", "/users?createdBefore=2024-01-01T03:00:00%2B02:00", []string{"alice"}, "1"},
+		{"search", "/users/search?q=al&createdAfter=2024-01-01T00:30:00Z", []string{"alfred", "albert"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := doRequest(s, http.MethodGet, tt.path, "")
+			assert.Equal(t, http.StatusOK, w.Code)
+			var users []User
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &users))
+			names := []string{}
+			for _, u := range users {
+				names = append(names, u.Username)
+			}
+			assert.Equal(t, tt.want, names)
+			assert.Equal(t, tt.total, w.Header().Get("X-Total-Count"))
+		})
+	}
+
+	t.Run("with cursor", func(t *testing.T) {
+		w := doRequest(s, http.MethodGet, "/users?createdAfter=2024-01-01T00:30:00Z&limit=2&cursor=", "")
+		var page userPage
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		assert.Len(t, page.Users, 2)
+		w = doRequest(s, http.MethodGet, "/users?createdAfter=2024-01-01T00:30:00Z&limit=2&cursor="+page.NextCursor, "")
+		page = userPage{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		if assert.Len(t, page.Users, 1) {
+			assert.Equal(t, "albert", page.Users[0].Username)
+		}
+		assert.Empty(t, page.NextCursor)
+	})
+
+	for _, path := range []string{"/users?createdAfter=yesterday", "/users/search?q=al&createdBefore=2024-01-01"} {
+		w := doRequest(s, http.MethodGet, path, "")
+		assert.Equal(t, http.StatusBadRequest, w.Code, path)
+		assert.Contains(t, w.Body.String(), "must be an RFC 3339 timestamp", path)
+	}
+}
+
+func TestListUsersByPreferences(t *testing.T) {
+	s := newTestServer(t)
+	for _, u := range []struct{ name, prefs string }{
+		{"alice", `{"theme":"dark","tags":["beta","staff"]}`},
+		{"bobby", `{"theme":"dark","tags":["beta"]}`},
+		{"carol", `{"theme":"light","tags":["beta","staff"]}`},
+		{"dave1", `{"theme":"dark"}`},
+	} {
+		body := fmt.Sprintf(`{"username":%q,"email":"%s@example.com"}`, u.name, u.name)
+		assert.Equal(t, http.StatusCreated, doRequest(s, http.MethodPost, "/users", body).Code)
+		assert.Equal(t, http.StatusOK, doRequest(s, http.MethodPut, "/users/"+u.name+"@example.com/preferences", u.prefs).Code)
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"theme", "?theme=dark", []string{"alice", "bobby", "dave1"}},
+		{"tag", "?tag=beta", []string{"alice", "bobby", "carol"}},
+		{"every tag", "?tag=beta&tag=staff", []string{"alice", "carol"}},
+		{"theme and tags", "?theme=dark&tag=staff&tag=beta", []string{"alice"}},
+		{"no match", "?theme=system", []string{}},
+		{"with pagination", "?tag=beta&limit=2&offset=1", []string{"bobby", "carol"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := doRequest(s, http.MethodGet, "/users"+tt.query, "")
+			assert.Equal(t, http.StatusOK, w.Code)
+			var users []User
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &users))
+			names := []string{}
+			for _, u := range users {
+				names = append(names, u.Username)
+			}
+			assert.Equal(t, tt.want, names)
+		})
+	}
+}
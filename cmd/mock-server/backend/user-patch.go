@@ -0,0 +1,89 @@
+package backend
+
+// userPatch is a partial update of a user. Nil fields are left untouched.
+type userPatch struct {
+	Username    *string           `json:"username"`
+	Preferences *preferencesPatch `json:"preferences"`
+}
+
+// preferencesPatch is a partial update of a user's preferences. Nil
+// fields, including nil slices and maps, are left untouched.
+type preferencesPatch struct {
+	IsPublic      *bool          `json:"isPublic"`
+	ShowEmail     *bool          `json:"showEmail"`
+	Theme         *string        `json:"theme"`
+	Tags          []string       `json:"tags"`
+	Settings      map[string]any `json:"settings"`
+	Notifications []Notification `json:"notifications"`
+}
+
+// normalize cleans up the fields present in the patch before validation
+func (p *userPatch) normalize() {
+	if p.Preferences != nil {
+		p.Preferences.normalize()
+	}
+}
+
+// validate checks the fields present in the patch against the limits in cfg
+func (p *userPatch) validate(cfg Config) error {
+	errs := fieldErrors{}
+	if p.Username != nil {
+		errs.add("username", validateUsername(*p.Username))
+	}
+	if p.Preferences != nil {
+		p.Preferences.validate(errs, "preferences.", cfg)
+	}
+	return errs.err()
+}
+
+// apply merges the fields present in the patch into user
+func (p *userPatch) apply(user *User) {
+	if p.Username != nil {
+		user.Username = *p.Username
+	}
+	if p.Preferences != nil {
+		p.Preferences.apply(&user.Preferences)
+	}
+}
+
+// normalize cleans up the fields present in the patch before validation
+func (p *preferencesPatch) normalize() {
+	p.Tags = normalizeTags(p.Tags)
+}
+
+// validate records the problems with the fields present in the patch in
+// errs, using prefix for the field paths, e.g. "preferences."
+func (p *preferencesPatch) validate(errs fieldErrors, prefix string, cfg Config) {
+	if p.Theme != nil {
+		errs.add(prefix+"theme", validateTheme(*p.Theme, cfg.Themes))
+	}
+	if p.Tags != nil {
+		errs.add(prefix+"tags", validateTags(p.Tags, cfg.MaxTags))
+	}
+	if p.Settings != nil {
+		errs.add(prefix+"settings", validateSettings(p.Settings, cfg.SettingsLimits))
+	}
+	validateNotifications(errs, prefix, p.Notifications)
+}
+
+// apply merges the fields present in the patch into prefs
+func (p *preferencesPatch) apply(prefs *Preferences) {
+	if p.IsPublic != nil {
+		prefs.IsPublic = *p.IsPublic
+	}
+	if p.ShowEmail != nil {
+		prefs.ShowEmail = *p.ShowEmail
+	}
+	if p.Theme != nil {
+		prefs.Theme = *p.Theme
+	}
+	if p.Tags != nil {
+		prefs.Tags = p.Tags
+	}
+	if p.Settings != nil {
+		prefs.Settings = p.Settings
+	}
+	if p.Notifications != nil {
+		prefs.Notifications = p.Notifications
+	}
+}
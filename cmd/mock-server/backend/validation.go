@@ -0,0 +1,197 @@
+package backend
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const (
+	minUsernameLength = 3
+	maxUsernameLength = 32
+)
+
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// userIDPattern matches the IDs of IDGenerator, lowercase UUIDs. IDs also
+// name avatar files, so anything else could point outside AvatarDir.
+var userIDPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+var (
+	notificationTypes    = []string{"email", "push", "sms"}
+	notificationChannels = []string{"marketing", "system", "security"}
+)
+
+// Notification frequencies. They are kept as plain numbers on the wire
+// because the gateway tool definitions send frequency as a number.
+const (
+	FrequencyRealtime = 0
+	FrequencyDaily    = 1
+	FrequencyWeekly   = 2
+	FrequencyMonthly  = 3
+)
+
+// normalizeEmail lowercases and trims email so lookups are case-insensitive
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// validateEmail checks that email is a bare address such as
+// "alice@example.com". Display-name forms like "Alice <alice@example.com>"
+// are rejected because the email is used as a lookup key.
+func validateEmail(email string) error {
+	if email == "" {
+		return errors.New("email is required")
+	}
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return fmt.Errorf("invalid email %q", email)
+	}
+	return nil
+}
+
+// validateUsername checks that username is between minUsernameLength and
+// maxUsernameLength characters made of letters, digits, '_', '.' and '-'
+func validateUsername(username string) error {
+	if len(username) < minUsernameLength || len(username) > maxUsernameLength {
+		return fmt.Errorf("username must be between %d and %d characters", minUsernameLength, maxUsernameLength)
+	}
+	if !usernamePattern.MatchString(username) {
+		return fmt.Errorf("username %q may only contain letters, digits, '_', '.' and '-'", username)
+	}
+	return nil
+}
+
+// validateUserID checks that id has the format of generated IDs
+func validateUserID(id string) error {
+	if !userIDPattern.MatchString(id) {
+		return fmt.Errorf("invalid id %q, must be a lowercase UUID", id)
+	}
+	return nil
+}
+
+// validateAvatarURL checks that rawURL is an absolute http or https URL
+func validateAvatarURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid avatar url %q, must be an http or https URL", rawURL)
+	}
+	return nil
+}
+
+// validateTheme checks that theme is one of the allowed themes
+func validateTheme(theme string, allowed []string) error {
+	if !slices.Contains(allowed, theme) {
+		return fmt.Errorf("invalid theme %q, must be one of %s", theme, strings.Join(allowed, ", "))
+	}
+	return nil
+}
+
+// normalizeTags trims the tags and drops empty and duplicate ones, keeping
+// the first occurrence of each. A nil slice stays nil.
+func normalizeTags(tags []string) []string {
+	if tags == nil {
+		return nil
+	}
+	seen := make(map[string]bool, len(tags))
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	return normalized
+}
+
+// validateTags checks that there are at most limit tags
+func validateTags(tags []string, limit int) error {
+	if len(tags) > limit {
+		return fmt.Errorf("at most %d tags are allowed, got %d", limit, len(tags))
+	}
+	return nil
+}
+
+// validateSettings checks that settings stay within limits
+func validateSettings(settings map[string]any, limits SettingsLimits) error {
+	if len(settings) > limits.MaxKeys {
+		return fmt.Errorf("at most %d settings keys are allowed, got %d", limits.MaxKeys, len(settings))
+	}
+	if depth := jsonDepth(settings); depth > limits.MaxDepth {
+		return fmt.Errorf("settings may be nested at most %d levels deep, got %d", limits.MaxDepth, depth)
+	}
+	encoded, err := json.Marshal(settings)
+	if err != nil {
+		return err
+	}
+	if len(encoded) > limits.MaxBytes {
+		return fmt.Errorf("settings may be at most %d bytes, got %d", limits.MaxBytes, len(encoded))
+	}
+	return nil
+}
+
+// jsonDepth returns the nesting depth of a decoded JSON value. Scalars have
+// depth 0 and every enclosing object or array adds one level.
+func jsonDepth(v any) int {
+	depth := 0
+	switch v := v.(type) {
+	case map[string]any:
+		for _, item := range v {
+			depth = max(depth, jsonDepth(item))
+		}
+	case []any:
+		for _, item := range v {
+			depth = max(depth, jsonDepth(item))
+		}
+	default:
+		return 0
+	}
+	return depth + 1
+}
+
+// validateNotification records the problems with n in errs, using prefix
+// for the field paths, e.g. "notifications[0]."
+func validateNotification(errs fieldErrors, prefix string, n Notification) {
+	if !slices.Contains(notificationTypes, n.Type) {
+		errs[prefix+"type"] = fmt.Sprintf("invalid notification type %q, must be one of %s",
+			n.Type, strings.Join(notificationTypes, ", "))
+	}
+	if !slices.Contains(notificationChannels, n.Channel) {
+		errs[prefix+"channel"] = fmt.Sprintf("invalid notification channel %q, must be one of %s",
+			n.Channel, strings.Join(notificationChannels, ", "))
+	}
+	if n.Frequency != math.Trunc(n.Frequency) || n.Frequency < FrequencyRealtime || n.Frequency > FrequencyMonthly {
+		errs[prefix+"frequency"] = fmt.Sprintf("invalid notification frequency %v, must be 0 (realtime), 1 (daily), 2 (weekly) or 3 (monthly)",
+			n.Frequency)
+	}
+}
+
+// validateNotifications records the problems with every notification in
+// errs, using prefix for the field paths, e.g. "preferences.". A type and
+// channel may only appear once.
+func validateNotifications(errs fieldErrors, prefix string, notifications []Notification) {
+	for i, n := range notifications {
+		field := fmt.Sprintf("%snotifications[%d]", prefix, i)
+		validateNotification(errs, field+".", n)
+		if first := findNotification(notifications[:i], n.Type, n.Channel); first >= 0 {
+			errs[field] = fmt.Sprintf("duplicate notification %s:%s, already at notifications[%d]", n.Type, n.Channel, first)
+		}
+	}
+}
+
+// decodeStrict decodes a single JSON value from r into v and rejects
+// fields that v does not declare
+func decodeStrict(r io.Reader, v any) error {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
@@ -0,0 +1,150 @@
+package backend
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateEmail(t *testing.T) {
+	tests := []struct {
+		name    string
+		email   string
+		wantErr bool
+	}{
+		{name: "valid", email: "alice@example.com"},
+		{name: "valid with subdomain and plus", email: "alice+test@mail.example.com"},
+		{name: "empty", email: "", wantErr: true},
+		{name: "missing at", email: "not-an-email", wantErr: true},
+		{name: "missing local part", email: "@example.com", wantErr: true},
+		{name: "missing domain", email: "alice@", wantErr: true},
+		{name: "display name", email: "Alice <alice@example.com>", wantErr: true},
+		{name: "surrounding spaces", email: " alice@example.com ", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateEmail(tt.email)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateUsername(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		wantErr  bool
+	}{
+		{name: "empty", username: "", wantErr: true},
+		{name: "too short", username: "ab", wantErr: true},
+		{name: "minimum length", username: "abc"},
+		{name: "maximum length", username: strings.Repeat("a", 32)},
+		{name: "too long", username: strings.Repeat("a", 33), wantErr: true},
+		{name: "allowed punctuation", username: "alice_b.c-d"},
+		{name: "space", username: "alice b", wantErr: true},
+		{name: "at sign", username: "alice@home", wantErr: true},
+		{name: "non ascii", username: "alicé", wantErr: true},
+		{name: "slash", username: "../etc", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateUsername(tt.username)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateNotification(t *testing.T) {
+	tests := []struct {
+		name         string
+		notification Notification
+		wantFields   []string
+	}{
+		{name: "valid", notification: Notification{Type: "email", Channel: "security"}},
+		{name: "unknown type", notification: Notification{Type: "emial", Channel: "system"}, wantFields: []string{"type"}},
+		{name: "unknown channel", notification: Notification{Type: "sms", Channel: "promo"}, wantFields: []string{"channel"}},
+		{name: "empty", notification: Notification{}, wantFields: []string{"type", "channel"}},
+		{name: "wrong case", notification: Notification{Type: "Push", Channel: "System"}, wantFields: []string{"type", "channel"}},
+		{name: "monthly frequency", notification: Notification{Type: "push", Channel: "system", Frequency: FrequencyMonthly}},
+		{name: "negative frequency", notification: Notification{Type: "push", Channel: "system", Frequency: -1}, wantFields: []string{"frequency"}},
+		{name: "frequency above monthly", notification: Notification{Type: "push", Channel: "system", Frequency: 4}, wantFields: []string{"frequency"}},
+		{name: "fractional frequency", notification: Notification{Type: "push", Channel: "system", Frequency: 1.5}, wantFields: []string{"frequency"}},
+		{name: "far out of range frequency", notification: Notification{Type: "push", Channel: "system", Frequency: 7.5}, wantFields: []string{"frequency"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := fieldErrors{}
+			validateNotification(errs, "", tt.notification)
+			assert.Len(t, errs, len(tt.wantFields))
+			for _, field := range tt.wantFields {
+				assert.Contains(t, errs[field], "invalid notification "+field)
+			}
+		})
+	}
+}
+
+func TestNormalizeTags(t *testing.T) {
+	tests := []struct {
+		name string
+		tags []string
+		want []string
+	}{
+		{name: "nil", tags: nil, want: nil},
+		{name: "dedup keeps first seen order", tags: []string{"b", "a", "b", "c", "a"}, want: []string{"b", "a", "c"}},
+		{name: "trim and drop empty", tags: []string{" a ", "", "  ", "a", "b "}, want: []string{"a", "b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, normalizeTags(tt.tags))
+		})
+	}
+}
+
+func TestValidateTags(t *testing.T) {
+	tags := make([]string, defaultMaxTags+1)
+	for i := range tags {
+		tags[i] = strings.Repeat("t", i+1)
+	}
+
+	assert.NoError(t, validateTags(tags[:defaultMaxTags], defaultMaxTags))
+	assert.Error(t, validateTags(tags, defaultMaxTags))
+}
+
+func TestValidateSettings(t *testing.T) {
+	limits := SettingsLimits{MaxKeys: 2, MaxBytes: 32, MaxDepth: 2}
+	tests := []struct {
+		name     string
+		settings map[string]any
+		wantErr  bool
+	}{
+		{name: "nil", settings: nil},
+		{name: "within limits", settings: map[string]any{"a": 1, "b": map[string]any{"c": true}}},
+		{name: "too many keys", settings: map[string]any{"a": 1, "b": 2, "c": 3}, wantErr: true},
+		{name: "too deep", settings: map[string]any{"a": []any{map[string]any{"b": 1}}}, wantErr: true},
+		{name: "too large", settings: map[string]any{"a": strings.Repeat("x", 32)}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateSettings(tt.settings, limits)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	amapWeatherURL      = "https://restapi.amap.com/v3/weather/weatherInfo"
+	weatherRetryBackoff = 100 * time.Millisecond
+)
+
+// AmapProvider fetches weather from the Amap weather API
+type AmapProvider struct {
+	// URL is the weatherInfo endpoint, amapWeatherURL when empty
+	URL string
+	// Key is the Amap API key
+	Key string
+	// Client sends the upstream requests, http.DefaultClient when nil
+	Client *http.Client
+	// Retries is the number of retries of failed upstream calls
+	Retries int
+}
+
+// amapResponse is the envelope of Amap weather responses. Lives is set for
+// current conditions and Forecasts for the "all" extension.
+type amapResponse struct {
+	Status    string     `json:"status"`
+	Info      string     `json:"info"`
+	Lives     []Weather  `json:"lives"`
+	Forecasts []Forecast `json:"forecasts"`
+}
+
+// Current implements WeatherProvider
+func (p *AmapProvider) Current(ctx context.Context, city string) (Weather, error) {
+	var result amapResponse
+	if err := p.get(ctx, url.Values{"city": {city}}, &result); err != nil {
+		return Weather{}, err
+	}
+	if len(result.Lives) == 0 {
+		return Weather{}, errWeatherNotFound
+	}
+	return result.Lives[0], nil
+}
+
+// Forecast implements WeatherProvider using the "all" extension, which
+// Amap limits to a few days
+func (p *AmapProvider) Forecast(ctx context.Context, city string, days int) (Forecast, error) {
+	var result amapResponse
+	if err := p.get(ctx, url.Values{"city": {city}, "extensions": {"all"}}, &result); err != nil {
+		return Forecast{}, err
+	}
+	if len(result.Forecasts) == 0 {
+		return Forecast{}, errWeatherNotFound
+	}
+	forecast := result.Forecasts[0]
+	if len(forecast.Casts) > days {
+		forecast.Casts = forecast.Casts[:days]
+	}
+	return forecast, nil
+}
+
+// get queries the weatherInfo endpoint and decodes a successful response
+// into v
+func (p *AmapProvider) get(ctx context.Context, query url.Values, v any) error {
+	endpoint := p.URL
+	if endpoint == "" {
+		endpoint = amapWeatherURL
+	}
+	query.Set("key", p.Key)
+
+	resp, err := p.getWithRetry(ctx, endpoint+"?"+query.Encode())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("amap: unexpected status %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("amap: %w", err)
+	}
+	var envelope amapResponse
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return fmt.Errorf("amap: %w", err)
+	}
+	if envelope.Status != "1" {
+		return fmt.Errorf("amap: %s", envelope.Info)
+	}
+	return json.Unmarshal(body, v)
+}
+
+// getWithRetry sends a GET to url. Network errors and 5xx responses are
+// retried up to Retries times with exponential backoff and jitter; 4xx
+// responses are returned as is. Retrying stops as soon as ctx is done. The
+// last 5xx response is returned once retries are exhausted.
+func (p *AmapProvider) getWithRetry(ctx context.Context, url string) (*http.Response, error) {
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.Do(req)
+		retryable := err != nil || resp.StatusCode >= http.StatusInternalServerError
+		if !retryable || attempt >= p.Retries || ctx.Err() != nil {
+			return resp, err
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+
+		// Wait half the exponential backoff plus a random share of the rest
+		backoff := weatherRetryBackoff << attempt
+		timer := time.NewTimer(backoff/2 + rand.N(backoff/2))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package backend
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+// staticCities names the cities known to StaticProvider by adcode
+var staticCities = map[string][2]string{
+	"110000": {"北京", "北京市"},
+	"110101": {"北京", "东城区"},
+	"310000": {"上海", "上海市"},
+	"440100": {"广东", "广州市"},
+	"440300": {"广东", "深圳市"},
+}
+
+// StaticProvider serves canned weather without any network access or API
+// key. The same city always gets the same data, so it suits CI and tests.
+type StaticProvider struct{}
+
+// Forecast implements WeatherProvider with days of generated forecast
+// starting at deterministicTime
+func (StaticProvider) Forecast(_ context.Context, city string, days int) (Forecast, error) {
+	names := staticCityNames(city)
+	forecast := Forecast{
+		Province:   names[0],
+		City:       names[1],
+		Adcode:     city,
+		ReportTime: deterministicTime.Format("2006-01-02 15:04:05"),
+		Casts:      make([]DailyForecast, days),
+	}
+	conditions := []string{"晴", "多云", "阴", "小雨"}
+	for i := range forecast.Casts {
+		day := deterministicTime.AddDate(0, 0, i)
+		forecast.Casts[i] = DailyForecast{
+			Date:         day.Format("2006-01-02"),
+			Week:         strconv.Itoa(isoWeekday(day)),
+			DayWeather:   conditions[i%len(conditions)],
+			NightWeather: conditions[(i+1)%len(conditions)],
+			DayTemp:      strconv.Itoa(25 + i%3),
+			NightTemp:    strconv.Itoa(15 + i%3),
+			DayWind:      "南",
+			NightWind:    "南",
+			DayPower:     "1-3",
+			NightPower:   "1-3",
+		}
+	}
+	return forecast, nil
+}
+
+// isoWeekday returns the ISO weekday of t, 1 for Monday to 7 for Sunday,
+// as used by Amap
+func isoWeekday(t time.Time) int {
+	if t.Weekday() == time.Sunday {
+		return 7
+	}
+	return int(t.Weekday())
+}
+
+// staticCityNames returns the province and city name of an adcode, using
+// the adcode itself as the city name when it isn't known
+func staticCityNames(city string) [2]string {
+	if names, ok := staticCities[city]; ok {
+		return names
+	}
+	return [2]string{"", city}
+}
+
+// Current implements WeatherProvider
+func (StaticProvider) Current(_ context.Context, city string) (Weather, error) {
+	names := staticCityNames(city)
+	return Weather{
+		Province:         names[0],
+		City:             names[1],
+		Adcode:           city,
+		Weather:          "晴",
+		Temperature:      "25",
+		WindDirection:    "南",
+		WindPower:        "≤3",
+		Humidity:         "40",
+		ReportTime:       deterministicTime.Format("2006-01-02 15:04:05"),
+		TemperatureFloat: "25.0",
+		HumidityFloat:    "40.0",
+	}, nil
+}
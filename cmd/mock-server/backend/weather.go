@@ -0,0 +1,397 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultForecastDays = 3
+	maxForecastDays     = 7
+
+	weatherBatchWorkers   = 4
+	maxWeatherBatchCities = 50
+)
+
+// defaultWeatherCity is the adcode used when no city is given
+const defaultWeatherCity = "110101"
+
+var errWeatherNotFound = errors.New("no weather data for city")
+
+var adcodePattern = regexp.MustCompile(`^[0-9]{6}$`)
+
+// weatherCityNames maps the city names accepted in place of an adcode
+var weatherCityNames = map[string]string{
+	"北京":        "110000",
+	"北京市":       "110000",
+	"beijing":   "110000",
+	"东城区":       "110101",
+	"上海":        "310000",
+	"上海市":       "310000",
+	"shanghai":  "310000",
+	"广州":        "440100",
+	"广州市":       "440100",
+	"guangzhou": "440100",
+	"深圳":        "440300",
+	"深圳市":       "440300",
+	"shenzhen":  "440300",
+}
+
+// normalizeCity returns the adcode of city, which is either a six digit
+// adcode or one of the names in weatherCityNames, ignoring case and
+// surrounding spaces
+func normalizeCity(city string) (string, error) {
+	city = strings.TrimSpace(city)
+	if adcodePattern.MatchString(city) {
+		return city, nil
+	}
+	if code, ok := weatherCityNames[strings.ToLower(city)]; ok {
+		return code, nil
+	}
+	return "", fmt.Errorf("invalid city %q, must be a 6-digit adcode or a known city name", city)
+}
+
+// Weather holds the current conditions of a city. The fields and their
+// JSON names follow the "lives" entries of the Amap weather API.
+type Weather struct {
+	Province         string `json:"province"`
+	City             string `json:"city"`
+	Adcode           string `json:"adcode"`
+	Weather          string `json:"weather"`
+	Temperature      string `json:"temperature"`
+	WindDirection    string `json:"winddirection"`
+	WindPower        string `json:"windpower"`
+	Humidity         string `json:"humidity"`
+	ReportTime       string `json:"reporttime"`
+	TemperatureFloat string `json:"temperature_float"`
+	HumidityFloat    string `json:"humidity_float"`
+}
+
+// Forecast is a multi-day forecast of a city. The fields and their JSON
+// names follow the "forecasts" entries of the Amap weather API.
+type Forecast struct {
+	Province   string          `json:"province"`
+	City       string          `json:"city"`
+	Adcode     string          `json:"adcode"`
+	ReportTime string          `json:"reporttime"`
+	Casts      []DailyForecast `json:"casts"`
+}
+
+// DailyForecast is the forecast of a single day
+type DailyForecast struct {
+	Date         string `json:"date"`
+	Week         string `json:"week"`
+	DayWeather   string `json:"dayweather"`
+	NightWeather string `json:"nightweather"`
+	DayTemp      string `json:"daytemp"`
+	NightTemp    string `json:"nighttemp"`
+	DayWind      string `json:"daywind"`
+	NightWind    string `json:"nightwind"`
+	DayPower     string `json:"daypower"`
+	NightPower   string `json:"nightpower"`
+}
+
+// WeatherProvider is a source of weather data
+type WeatherProvider interface {
+	// Current returns the current weather of the city with the given
+	// adcode, or errWeatherNotFound when the city is unknown
+	Current(ctx context.Context, city string) (Weather, error)
+	// Forecast returns up to days days of forecast starting today for the
+	// city with the given adcode, or errWeatherNotFound when the city is
+	// unknown
+	Forecast(ctx context.Context, city string, days int) (Forecast, error)
+}
+
+// weatherCache keeps weather by city code for a fixed TTL. Expired entries
+// are replaced on the next lookup of the same city.
+type weatherCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]weatherCacheEntry
+}
+
+type weatherCacheEntry struct {
+	weather   Weather
+	expiresAt time.Time
+}
+
+func newWeatherCache(ttl time.Duration) *weatherCache {
+	return &weatherCache{
+		ttl:     ttl,
+		entries: make(map[string]weatherCacheEntry),
+	}
+}
+
+// Get returns the cached weather for city if it hasn't expired
+func (c *weatherCache) Get(city string) (Weather, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[city]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return Weather{}, false
+	}
+	return entry.weather, true
+}
+
+// Put caches the weather for city. A non-positive TTL disables caching.
+func (c *weatherCache) Put(city string, weather Weather) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.ttl <= 0 {
+		return
+	}
+	c.entries[city] = weatherCacheEntry{weather: weather, expiresAt: time.Now().Add(c.ttl)}
+}
+
+// Reset drops every cached entry and caches with ttl from now on
+func (c *weatherCache) Reset(ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.ttl = ttl
+	c.entries = make(map[string]weatherCacheEntry)
+}
+
+// Clear drops every cached entry and returns how many there were
+func (c *weatherCache) Clear() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := len(c.entries)
+	c.entries = make(map[string]weatherCacheEntry)
+	return n
+}
+
+// isTimeout reports whether err is a deadline or client timeout error
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout())
+}
+
+// currentWeather returns the current weather of city from the cache,
+// falling back to the provider
+func (s *HTTPServer) currentWeather(ctx context.Context, city string) (Weather, error) {
+	if weather, ok := s.weatherCache.Get(city); ok {
+		return weather, nil
+	}
+	var weather Weather
+	err := s.callWeather(ctx, "current", city, func(ctx context.Context) (err error) {
+		weather, err = s.weatherProvider().Current(ctx, city)
+		return err
+	})
+	if err != nil {
+		return Weather{}, err
+	}
+	s.weatherCache.Put(city, weather)
+	return weather, nil
+}
+
+// callWeather runs a provider call through the weather circuit breaker,
+// tracing it as a child span of the request. Unknown cities and calls
+// cancelled by the client don't count as failures.
+func (s *HTTPServer) callWeather(ctx context.Context, operation, city string, call func(context.Context) error) error {
+	token, err := s.weatherBreaker.Allow()
+	if err != nil {
+		return err
+	}
+	spanCtx, span := s.startWeatherSpan(ctx, operation, city)
+	err = call(spanCtx)
+	endSpan(span, err)
+	s.metrics.observeWeatherCall(err)
+	ignored := errors.Is(err, errWeatherNotFound) || errors.Is(ctx.Err(), context.Canceled)
+	s.weatherBreaker.Done(token, err != nil, ignored)
+	return err
+}
+
+// handleWeather returns the current weather of a city in the shape of the
+// Amap weather API, whichever provider is configured. Results are cached
+// for WeatherCacheTTL. Provider calls are bound to the request context; a
+// timeout is reported as 504.
+func (s *HTTPServer) handleWeather(c *gin.Context) {
+	city, err := normalizeCity(c.DefaultQuery("city", defaultWeatherCity))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+
+	weather, err := s.currentWeather(c.Request.Context(), city)
+	if err != nil {
+		respondWeatherError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{
+		"status":   "1",
+		"count":    "1",
+		"info":     "OK",
+		"infocode": "10000",
+		"lives":    []Weather{weather},
+	})
+}
+
+// handleWeatherForecast returns the forecast of a city for the next days
+// days, 1 to maxForecastDays and defaultForecastDays when omitted. The
+// response is a Forecast:
+//
+//	{
+//	  "province": "北京", "city": "东城区", "adcode": "110101",
+//	  "reporttime": "2024-01-01 00:00:00",
+//	  "casts": [{"date": "2024-01-01", "week": "1", "dayweather": "晴", ...}]
+//	}
+//
+// casts holds one entry per day in date order and may be shorter than
+// days when the provider has fewer days available.
+func (s *HTTPServer) handleWeatherForecast(c *gin.Context) {
+	city, err := normalizeCity(c.DefaultQuery("city", defaultWeatherCity))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, codeInvalidRequest, err.Error())
+		return
+	}
+	days := defaultForecastDays
+	if v, ok := c.GetQuery("days"); ok {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxForecastDays {
+			respondError(c, http.StatusBadRequest, codeInvalidRequest, fmt.Sprintf("days must be between 1 and %d", maxForecastDays))
+			return
+		}
+		days = n
+	}
+
+	ctx := c.Request.Context()
+	var forecast Forecast
+	err = s.callWeather(ctx, "forecast", city, func(ctx context.Context) (err error) {
+		forecast, err = s.weatherProvider().Forecast(ctx, city, days)
+		return err
+	})
+	if err != nil {
+		respondWeatherError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, forecast)
+}
+
+// weatherBatchResult is the outcome of one city of a batch lookup
+type weatherBatchResult struct {
+	Status  int      `json:"status"`
+	Weather *Weather `json:"weather,omitempty"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// handleWeatherBatch returns the current weather of several cities given
+// as ?cities=110101,310000. Results are keyed by the cities as given, which
+// may also be names known to normalizeCity. The cities are looked up
+// concurrently by at most weatherBatchWorkers workers and the response
+// maps each city to its own result, so one failing city doesn't fail the
+// others. Lookups still pending when the client goes away are cancelled.
+func (s *HTTPServer) handleWeatherBatch(c *gin.Context) {
+	cities := slices.Compact(slices.Sorted(slices.Values(splitList(c.Query("cities")))))
+	if len(cities) == 0 {
+		respondError(c, http.StatusBadRequest, codeInvalidRequest, "missing cities")
+		return
+	}
+	if len(cities) > maxWeatherBatchCities {
+		respondError(c, http.StatusBadRequest, codeInvalidRequest, fmt.Sprintf("at most %d cities are allowed", maxWeatherBatchCities))
+		return
+	}
+
+	ctx := c.Request.Context()
+	results := make([]weatherBatchResult, len(cities))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range min(weatherBatchWorkers, len(cities)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				city, err := normalizeCity(cities[i])
+				if err != nil {
+					results[i] = weatherBatchResult{Status: http.StatusBadRequest, Error: err.Error()}
+					continue
+				}
+				weather, err := s.currentWeather(ctx, city)
+				if err != nil {
+					status, _, msg := weatherErrorStatus(err)
+					results[i] = weatherBatchResult{Status: status, Error: msg}
+					continue
+				}
+				results[i] = weatherBatchResult{Status: http.StatusOK, Weather: &weather}
+			}
+		}()
+	}
+	for i := range cities {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	byCity := make(map[string]weatherBatchResult, len(cities))
+	for i, city := range cities {
+		byCity[city] = results[i]
+	}
+	respond(c, http.StatusOK, byCity)
+}
+
+// weatherErrorStatus maps a WeatherProvider error to an HTTP status, error
+// code and client message: 404 for unknown cities, 503 while the circuit
+// breaker is open, 504 for timeouts and 500 otherwise
+func weatherErrorStatus(err error) (int, string, string) {
+	switch {
+	case errors.Is(err, errWeatherNotFound):
+		return http.StatusNotFound, codeNotFound, err.Error()
+	case errors.Is(err, errCircuitOpen):
+		return http.StatusServiceUnavailable, codeUnavailable, "weather upstream unavailable, circuit open"
+	case isTimeout(err):
+		return http.StatusGatewayTimeout, codeUpstreamTimeout, "weather upstream timed out"
+	default:
+		return http.StatusInternalServerError, codeUpstream, "Failed to fetch weather data"
+	}
+}
+
+// respondWeatherError reports a WeatherProvider error
+func respondWeatherError(c *gin.Context, err error) {
+	status, code, msg := weatherErrorStatus(err)
+	respondError(c, status, code, msg)
+}
+
+// newWeatherProvider returns the provider selected by cfg.WeatherProvider.
+// Without WEATHER_API_KEY the amap provider falls back to StaticProvider
+// with a warning, unless cfg.WeatherRequireKey is set.
+func newWeatherProvider(cfg Config, logger *zap.Logger) (WeatherProvider, error) {
+	switch cfg.WeatherProvider {
+	case "static":
+		return StaticProvider{}, nil
+	case "amap":
+		// 获取天气 API Key
+		weatherAPIKey := os.Getenv("WEATHER_API_KEY") // 从环境变量获取
+		if weatherAPIKey == "" {
+			if cfg.WeatherRequireKey {
+				return nil, errors.New("WEATHER_API_KEY not set in environment")
+			}
+			logger.Warn("WEATHER_API_KEY not set in environment, serving static weather data")
+			return StaticProvider{}, nil
+		}
+		return &AmapProvider{
+			Key:     weatherAPIKey,
+			Client:  &http.Client{Timeout: cfg.WeatherTimeout},
+			Retries: cfg.WeatherRetries,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown WEATHER_PROVIDER %q", cfg.WeatherProvider)
+	}
+}
@@ -0,0 +1,183 @@
+package backend
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// roundTripFunc lets a plain function act as an http.RoundTripper
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestWeatherUpstreamTimeout(t *testing.T) {
+	t.Setenv("WEATHER_TIMEOUT", "50ms")
+	t.Setenv("WEATHER_RETRIES", "0")
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer upstream.Close()
+
+	s := newTestServer(t)
+	s.weatherProvider().(*AmapProvider).URL = upstream.URL
+
+	w := doRequest(s, http.MethodGet, "/weather", "")
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.Contains(t, w.Body.String(), "timed out")
+}
+
+func TestWeatherIsCached(t *testing.T) {
+	s := newTestServer(t)
+	var calls atomic.Int32
+	s.weatherProvider().(*AmapProvider).Client = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		calls.Add(1)
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(`{"status":"1","lives":[{"city":"东城区"}]}`)),
+		}, nil
+	})}
+
+	for range 2 {
+		w := doRequest(s, http.MethodGet, "/weather?city=110101", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"status":"1"`)
+	}
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestWeatherRetriesServerErrors(t *testing.T) {
+	s := newTestServer(t)
+	var calls atomic.Int32
+	s.weatherProvider().(*AmapProvider).Client = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		status, body := http.StatusOK, `{"status":"1","lives":[{"city":"东城区"}]}`
+		switch calls.Add(1) {
+		case 1:
+			return nil, errors.New("connection reset")
+		case 2:
+			status, body = http.StatusBadGateway, `{}`
+		}
+		return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})}
+
+	w := doRequest(s, http.MethodGet, "/weather", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"city":"东城区"`)
+	assert.Equal(t, int32(3), calls.Load())
+}
+
+func TestWeatherWithoutAPIKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("WEATHER_API_KEY", "")
+	s := NewHTTPServer()
+
+	assert.IsType(t, StaticProvider{}, s.weatherProvider())
+	w := doRequest(s, http.MethodGet, "/weather", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestStaticWeatherProvider(t *testing.T) {
+	t.Setenv("WEATHER_PROVIDER", "static")
+	s := newTestServer(t)
+
+	w := doRequest(s, http.MethodGet, "/weather?city=310000", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"city":"上海市"`)
+	assert.Contains(t, w.Body.String(), `"adcode":"310000"`)
+}
+
+func TestWeatherForecast(t *testing.T) {
+	t.Setenv("WEATHER_PROVIDER", "static")
+	s := newTestServer(t)
+
+	w := doRequest(s, http.MethodGet, "/weather/forecast?city=110101&days=5", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var forecast Forecast
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &forecast))
+	assert.Equal(t, "110101", forecast.Adcode)
+	assert.Len(t, forecast.Casts, 5)
+	assert.Equal(t, "2024-01-01", forecast.Casts[0].Date)
+
+	for _, days := range []string{"0", "8", "two"} {
+		w = doRequest(s, http.MethodGet, "/weather/forecast?days="+days, "")
+		assert.Equal(t, http.StatusBadRequest, w.Code, days)
+	}
+}
+
+func TestWeatherBatch(t *testing.T) {
+	s := newTestServer(t)
+	s.weatherProvider().(*AmapProvider).Client = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		body := `{"status":"1","lives":[]}`
+		if city := req.URL.Query().Get("city"); city != "999999" {
+			body = `{"status":"1","lives":[{"adcode":"` + city + `"}]}`
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})}
+
+	w := doRequest(s, http.MethodGet, "/weather/batch?cities=110101,310000,999999,110101", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var results map[string]weatherBatchResult
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &results))
+	assert.Len(t, results, 3)
+	assert.Equal(t, "310000", results["310000"].Weather.Adcode)
+	assert.Equal(t, http.StatusNotFound, results["999999"].Status)
+	assert.Nil(t, results["999999"].Weather)
+}
+
+func TestNormalizeCity(t *testing.T) {
+	tests := []struct {
+		name    string
+		city    string
+		want    string
+		wantErr bool
+	}{
+		{name: "adcode", city: "110101", want: "110101"},
+		{name: "adcode with spaces", city: " 310000 ", want: "310000"},
+		{name: "chinese name", city: "上海", want: "310000"},
+		{name: "pinyin name any case", city: "BeiJing", want: "110000"},
+		{name: "short code", city: "11010", wantErr: true},
+		{name: "unknown name", city: "atlantis", wantErr: true},
+		{name: "query injection", city: "110101&key=stolen", wantErr: true},
+		{name: "path injection", city: "../../etc/passwd", wantErr: true},
+		{name: "empty", city: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normalizeCity(tt.city)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestWeatherRejectsInvalidCity(t *testing.T) {
+	t.Setenv("WEATHER_PROVIDER", "static")
+	s := newTestServer(t)
+
+	w := doRequest(s, http.MethodGet, "/weather?city=110101%26extensions%3Dall", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = doRequest(s, http.MethodGet, "/weather", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"adcode":"110101"`)
+}
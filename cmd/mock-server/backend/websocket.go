@@ -0,0 +1,107 @@
+package backend
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+const (
+	// wsWriteWait bounds writing a single frame
+	wsWriteWait = 10 * time.Second
+	// wsPongWait is how long the connection may stay silent, pongs included,
+	// before it is considered dead
+	wsPongWait = 60 * time.Second
+	// wsPingInterval must be shorter than wsPongWait
+	wsPingInterval = wsPongWait * 9 / 10
+)
+
+// wsUpgrader accepts connections from any origin since the mock server is
+// used behind arbitrary gateways and test pages
+var wsUpgrader = websocket.Upgrader{
+	CheckOrigin: func(*http.Request) bool { return true },
+}
+
+// wsMessage is a frame received from a WebSocket client
+type wsMessage struct {
+	messageType int
+	data        []byte
+}
+
+// handleWebSocket upgrades the connection and pushes user events to the
+// client as JSON text frames. Every message the client sends is echoed
+// back with the same frame type. The server pings every wsPingInterval
+// and drops connections that don't answer within wsPongWait. A close
+// frame from the client is answered and ends the connection.
+func (s *HTTPServer) handleWebSocket(c *gin.Context) {
+	conn, err := wsUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// Upgrade has already replied with an HTTP error
+		return
+	}
+	defer conn.Close()
+
+	events, unsubscribe := s.events.Subscribe()
+	defer unsubscribe()
+
+	echoes := make(chan wsMessage, eventBufferSize)
+	done, quit := make(chan struct{}), make(chan struct{})
+	defer close(quit)
+	logger := s.requestLogger(c)
+	go readWebSocket(conn, logger, echoes, done, quit)
+
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+
+	for {
+		var err error
+		select {
+		case <-done:
+			return
+		case evt := <-events:
+			_ = conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			err = conn.WriteJSON(evt)
+		case msg := <-echoes:
+			_ = conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			err = conn.WriteMessage(msg.messageType, msg.data)
+		case <-ping.C:
+			err = conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait))
+		}
+		if err != nil {
+			logger.Debug("websocket write failed", zap.Error(err))
+			return
+		}
+	}
+}
+
+// readWebSocket forwards client messages to echoes until the connection
+// fails or is closed, then closes done. It also gives up once quit is
+// closed. gorilla/websocket allows a single concurrent reader and writer,
+// so replies are left to the caller.
+func readWebSocket(conn *websocket.Conn, logger *zap.Logger, echoes chan<- wsMessage, done chan<- struct{}, quit <-chan struct{}) {
+	defer close(done)
+
+	_ = conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	for {
+		messageType, data, err := conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				logger.Debug("websocket closed", zap.Error(err))
+			}
+			return
+		}
+		_ = conn.SetReadDeadline(time.Now().Add(wsPongWait))
+		select {
+		case echoes <- wsMessage{messageType: messageType, data: data}:
+		case <-quit:
+			return
+		}
+	}
+}
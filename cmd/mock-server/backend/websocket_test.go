@@ -0,0 +1,40 @@
+package backend
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebSocketEchoAndEvents(t *testing.T) {
+	s := newTestServer(t)
+	ts := httptest.NewServer(s.router)
+	defer ts.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/ws", nil)
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	assert.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("hello")))
+	messageType, data, err := conn.ReadMessage()
+	assert.NoError(t, err)
+	assert.Equal(t, websocket.TextMessage, messageType)
+	assert.Equal(t, "hello", string(data))
+
+	w := doRequest(s, http.MethodPost, "/users", `{"username":"alice","email":"alice@example.com"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var evt userEvent
+	assert.NoError(t, conn.ReadJSON(&evt))
+	assert.Equal(t, eventUserCreated, evt.Type)
+	assert.Equal(t, "alice@example.com", evt.User.Email)
+
+	err = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	assert.NoError(t, err)
+	_, _, err = conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseNormalClosure))
+}
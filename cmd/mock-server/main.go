@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 
 	"go.uber.org/zap"
@@ -16,22 +17,26 @@ import (
 )
 
 var (
-	addr    string
-	sseAddr string
-	logger  *zap.Logger
+	addrs      []string
+	sseAddr    string
+	grpcAddr   string
+	configFile string
+	logger     *zap.Logger
 )
 
 func init() {
 	// Initialize logger
 	var err error
-	logger, err = zap.NewProduction()
+	logger, _, err = backend.NewLogger(backend.ConfigFromEnv())
 	if err != nil {
 		panic(err)
 	}
 
 	rootCmd.AddCommand(versionCmd)
-	rootCmd.PersistentFlags().StringVarP(&addr, "addr", "a", ":5236", "Address to listen on")
+	rootCmd.PersistentFlags().StringSliceVarP(&addrs, "addr", "a", []string{":5236"}, "Addresses to listen on, repeated or comma separated")
 	rootCmd.PersistentFlags().StringVarP(&sseAddr, "sse-addr", "s", ":5237", "Address to listen on for SSE")
+	rootCmd.PersistentFlags().StringVarP(&grpcAddr, "grpc-addr", "g", "", "Address to listen on for gRPC, disabled when empty")
+	rootCmd.PersistentFlags().StringVarP(&configFile, "config", "c", "", "YAML configuration file, defaults to $CONFIG_FILE")
 }
 
 var (
@@ -48,23 +53,52 @@ var (
 		Short: "Mock Backend Server",
 		Long:  `Mock Backend Server provide mock servers for testing`,
 		Run: func(cmd *cobra.Command, args []string) {
-			StartMockServer(addr)
+			cfg, err := backend.LoadConfig(configFile)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			// Addresses on the command line take precedence over the file
+			if cmd.Flags().Changed("addr") || len(cfg.Addrs) == 0 {
+				cfg.Addrs = addrs
+			}
+			if err := cfg.Validate(); err != nil {
+				fmt.Fprintln(os.Stderr, "invalid configuration:", err)
+				os.Exit(1)
+			}
+			StartMockServer(cfg, cfg.Addrs)
 		},
 	}
 )
 
-func StartMockServer(addr string) {
+func StartMockServer(cfg backend.Config, addrs []string) {
 	// Create a context that will be canceled on OS signals
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
 	// Create error channel to collect errors from all servers
-	errChan := make(chan error, 3)
-
-	// Start all servers with context
-	go startHTTPServer(ctx, addr, errChan)
+	errChan := make(chan error, 4)
+
+	// The gRPC server shares the HTTP server's user store
+	httpServer := backend.NewHTTPServerWithConfig(cfg)
+
+	// Start all servers with context. The HTTP and gRPC servers shut down
+	// gracefully, so they are waited for before returning.
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		startHTTPServer(ctx, httpServer, addrs, errChan)
+	}()
+	if grpcAddr != "" {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			startGRPCServer(ctx, backend.NewGRPCServer(httpServer), grpcAddr, errChan)
+		}()
+	}
 	go startStdioServer(ctx, errChan)
-	go startSSEServer(ctx, addr, errChan)
+	go startSSEServer(ctx, sseAddr, errChan)
 
 	// Wait for either context cancellation or error
 	select {
@@ -76,17 +110,27 @@ func StartMockServer(addr string) {
 	}
 
 	// Wait for all servers to shutdown
-	<-ctx.Done()
+	wg.Wait()
 	logger.Info("All servers stopped")
 }
 
-func startHTTPServer(_ context.Context, addr string, errChan chan<- error) {
-	httpServer := backend.NewHTTPServer()
-	if err := httpServer.Start(addr); err != nil {
+func startHTTPServer(ctx context.Context, httpServer *backend.HTTPServer, addrs []string, errChan chan<- error) {
+	if err := httpServer.RunContext(ctx, addrs...); err != nil {
 		errChan <- fmt.Errorf("HTTP server error: %w", err)
 	}
 }
 
+func startGRPCServer(ctx context.Context, grpcServer *backend.GRPCServer, addr string, errChan chan<- error) {
+	if err := grpcServer.Start(addr); err != nil {
+		errChan <- fmt.Errorf("gRPC server error: %w", err)
+		return
+	}
+	<-ctx.Done()
+	if err := grpcServer.Stop(); err != nil {
+		logger.Error("failed to stop gRPC server", zap.Error(err))
+	}
+}
+
 func startStdioServer(_ context.Context, errChan chan<- error) {
 	mcpServer := backend.NewMCPServer()
 